@@ -0,0 +1,462 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	builtins["gzip"] = (*interp).builtinGzip
+	builtins["gunzip"] = (*interp).builtinGunzip
+	nativeModule("archive", map[string]builtin{
+		"zip":     (*interp).archiveZip,
+		"unzip":   (*interp).archiveUnzip,
+		"ziplist": (*interp).archiveZipList,
+		"zipread": (*interp).archiveZipRead,
+		"tar":     (*interp).archiveTar,
+		"untar":   (*interp).archiveUntar,
+		"gzip":    (*interp).builtinGzip,
+		"gunzip":  (*interp).builtinGunzip,
+	})
+}
+
+// archiveFiles calls fn for each regular file and directory in paths,
+// walking directories recursively. The name passed to fn is the file's
+// path with slashes and without a leading slash, drive, or "..", as stored
+// in an archive.
+func archiveFiles(paths []string, fn func(name, path string, fi fs.FileInfo) error) error {
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, fi fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() && !fi.IsDir() {
+				return nil
+			}
+			name := strings.TrimLeft(filepath.ToSlash(filepath.Clean(path)[len(filepath.VolumeName(path)):]), "/")
+			for strings.HasPrefix(name, "../") {
+				name = name[len("../"):]
+			}
+			if name == "" || name == "." || name == ".." {
+				return nil
+			}
+			return fn(name, path, fi)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractPath returns the path of the archive entry name under dir, or an
+// error if it would be outside dir.
+func extractPath(dir, name string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("entry %q is outside the destination", name)
+	}
+	return p, nil
+}
+
+func extractFile(path string, mode fs.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeZip(out string, paths []string) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	err = archiveFiles(paths, func(name, path string, fi fs.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		return copyFile(w, path)
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openZip opens the zip archive v, which is either the name of a file or
+// the bytes of an archive. The archive must be closed with the returned
+// function.
+func openZip(v value) (*zip.Reader, func() error, error) {
+	if v.typ == vbytes {
+		b := v.v.([]byte)
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		return zr, func() error { return nil }, err
+	}
+	zr, err := zip.OpenReader(v.v.(string))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &zr.Reader, zr.Close, nil
+}
+
+func extractZip(zr *zip.Reader, dir string) ([]string, error) {
+	var names []string
+	for _, zf := range zr.File {
+		path, err := extractPath(dir, zf.Name)
+		if err != nil {
+			return names, err
+		}
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return names, err
+			}
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return names, err
+		}
+		err = extractFile(path, zf.Mode(), r)
+		r.Close()
+		if err != nil {
+			return names, err
+		}
+		names = append(names, zf.Name)
+	}
+	return names, nil
+}
+
+// writeTar writes a tar archive of paths to out, compressed with gzip if
+// out ends in .gz or .tgz.
+func writeTar(out string, paths []string) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = f
+	var gw *gzip.Writer
+	if strings.HasSuffix(out, ".gz") || strings.HasSuffix(out, ".tgz") {
+		gw = gzip.NewWriter(f)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	err = archiveFiles(paths, func(name, path string, fi fs.FileInfo) error {
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil || fi.IsDir() {
+			return err
+		}
+		return copyFile(tw, path)
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil && gw != nil {
+		err = gw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// extractTar extracts a tar archive, which may be compressed with gzip.
+func extractTar(file, dir string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	tr := tar.NewReader(r)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+		path, err := extractPath(dir, hdr.Name)
+		if err != nil {
+			return names, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return names, err
+			}
+		case tar.TypeReg:
+			if err := extractFile(path, hdr.FileInfo().Mode(), tr); err != nil {
+				return names, err
+			}
+			names = append(names, hdr.Name)
+		}
+	}
+}
+
+// archiveArgs checks the arguments of a builtin that takes a file name and
+// an array of paths.
+func (interp *interp) archiveArgs(fn string, args []value) (string, []string, bool) {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != varray {
+		interp.err = fmt.Errorf("%v expects a file name and an array of paths", fn)
+		return "", nil, false
+	}
+	var paths []string
+	for _, e := range args[1].entries() {
+		if e.v.typ != vstring {
+			interp.err = fmt.Errorf("%v expects an array of paths", fn)
+			return "", nil, false
+		}
+		paths = append(paths, e.v.v.(string))
+	}
+	if !interp.allowed(fn) {
+		return "", nil, false
+	}
+	return args[0].v.(string), paths, true
+}
+
+// archiveExtract checks the arguments of a builtin that extracts an
+// archive, calls extract, and returns the names of the extracted files.
+func (interp *interp) archiveExtract(fn string, args []value, extract func(file, dir string) ([]string, error)) value {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vstring {
+		interp.err = fmt.Errorf("%v expects an archive and a directory", fn)
+		return value{}
+	}
+	if !interp.allowed(fn) {
+		return value{}
+	}
+	names, err := extract(args[0].v.(string), args[1].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("%v: %v", fn, err))
+	}
+	return stringArray(names)
+}
+
+// archiveZipArgs checks the arguments of a builtin that takes a zip
+// archive, as a file name or bytes, and a string, and opens the archive.
+// Reading the archive from a file isn't allowed in the sandbox. If it
+// can't be opened, the value returned with false is an error value.
+func (interp *interp) archiveZipArgs(fn, usage string, args []value) (*zip.Reader, func() error, value, bool) {
+	if len(args) != 2 || args[0].typ != vstring && args[0].typ != vbytes || args[1].typ != vstring {
+		interp.err = fmt.Errorf("%v expects %v", fn, usage)
+		return nil, nil, value{}, false
+	}
+	if args[0].typ == vstring && !interp.allowed(fn) {
+		return nil, nil, value{}, false
+	}
+	zr, closeZip, err := openZip(args[0])
+	if err != nil {
+		return nil, nil, interp.failure(fmt.Errorf("%v: %v", fn, err)), false
+	}
+	return zr, closeZip, value{}, true
+}
+
+// archiveZip creates a zip file containing the given files and
+// directories.
+func (interp *interp) archiveZip(args []value) value {
+	out, paths, ok := interp.archiveArgs("archive.zip", args)
+	if !ok {
+		return value{}
+	}
+	if err := writeZip(out, paths); err != nil {
+		return interp.failure(fmt.Errorf("archive.zip: %v", err))
+	}
+	return value{}
+}
+
+// archiveUnzip extracts a zip file, or a zip archive in bytes, into a
+// directory, and returns the names of the files extracted.
+func (interp *interp) archiveUnzip(args []value) value {
+	zr, closeZip, v, ok := interp.archiveZipArgs("archive.unzip", "an archive and a directory", args)
+	if !ok {
+		return v
+	}
+	defer closeZip()
+	if !interp.allowed("archive.unzip") {
+		return value{}
+	}
+	names, err := extractZip(zr, args[1].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("archive.unzip: %v", err))
+	}
+	return stringArray(names)
+}
+
+// archiveZipList returns the names of the entries in a zip file, or a zip
+// archive in bytes, in the order they are stored. The names of directories
+// end in a slash.
+func (interp *interp) archiveZipList(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring && args[0].typ != vbytes {
+		interp.err = fmt.Errorf("archive.ziplist expects an archive")
+		return value{}
+	}
+	if args[0].typ == vstring && !interp.allowed("archive.ziplist") {
+		return value{}
+	}
+	zr, closeZip, err := openZip(args[0])
+	if err != nil {
+		return interp.failure(fmt.Errorf("archive.ziplist: %v", err))
+	}
+	defer closeZip()
+	names := make([]string, len(zr.File))
+	for i, zf := range zr.File {
+		names[i] = zf.Name
+	}
+	return stringArray(names)
+}
+
+// archiveZipRead returns the contents of the file with a name in a zip
+// file, or a zip archive in bytes, as bytes. If there is no such file, the
+// result is an error value.
+func (interp *interp) archiveZipRead(args []value) value {
+	zr, closeZip, v, ok := interp.archiveZipArgs("archive.zipread", "an archive and a name", args)
+	if !ok {
+		return v
+	}
+	defer closeZip()
+	f, err := zr.Open(args[1].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("archive.zipread: %v", err))
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return interp.failure(fmt.Errorf("archive.zipread: %v", err))
+	}
+	return value{typ: vbytes, v: b}
+}
+
+// archiveTar creates a tar file containing the given files and
+// directories. It is compressed with gzip if its name ends in .gz or .tgz.
+func (interp *interp) archiveTar(args []value) value {
+	out, paths, ok := interp.archiveArgs("archive.tar", args)
+	if !ok {
+		return value{}
+	}
+	if err := writeTar(out, paths); err != nil {
+		return interp.failure(fmt.Errorf("archive.tar: %v", err))
+	}
+	return value{}
+}
+
+// archiveUntar extracts a tar file, compressed with gzip or not, into a
+// directory, and returns the names of the files extracted.
+func (interp *interp) archiveUntar(args []value) value {
+	return interp.archiveExtract("archive.untar", args, extractTar)
+}
+
+// builtinGzip compresses a string or bytes in the gzip format, returning
+// the same type. It is also archive.gzip.
+func (interp *interp) builtinGzip(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("gzip expects a string or bytes")
+		return value{}
+	}
+	b, ok := interp.binaryArg("gzip", args)
+	if !ok {
+		return value{}
+	}
+	return binaryLike(args[0], gzipBytes(b))
+}
+
+// builtinGunzip decompresses a string or bytes compressed in the gzip
+// format, returning the same type. It is also archive.gunzip. Input that
+// isn't valid gzip results in an error value.
+func (interp *interp) builtinGunzip(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("gunzip expects a string or bytes")
+		return value{}
+	}
+	b, ok := interp.binaryArg("gunzip", args)
+	if !ok {
+		return value{}
+	}
+	b, err := gunzipBytes(b)
+	if err != nil {
+		return interp.failure(fmt.Errorf("gunzip: %v", err))
+	}
+	return binaryLike(args[0], b)
+}
+
+// binaryLike returns b as a string if v is one, and otherwise as bytes.
+func binaryLike(v value, b []byte) value {
+	if v.typ == vstring {
+		return value{typ: vstring, v: string(b)}
+	}
+	return value{typ: vbytes, v: b}
+}
+
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func gunzipBytes(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
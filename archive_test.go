@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestGzip(t *testing.T) {
+	// gzip and archive.gzip both return the type they are given.
+	wantOutput(t, `
+		z = gzip("hello");
+		println(typeof(z), gunzip(z), archive.gunzip(z));
+		zb = archive.gzip(bytes("hello"));
+		println(typeof(zb), gunzip(zb) == bytes("hello"), gunzip(archive.gzip("x")));
+		println(iserror(gunzip("not gzip")), iserror(archive.gunzip("not gzip")));
+	`, "string hello hello\nbytes true x\ntrue true\n")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	builtins["assert"] = (*interp).builtinAssert
+}
+
+// builtinAssert fails unless its first argument is true, with an optional
+// message. Called directly, assert is evaluated by evalAssert instead,
+// which also reports where the assertion is and what it asserted.
+func (interp *interp) builtinAssert(args []value) value {
+	msg, ok := interp.assertArgs(args)
+	if !ok && interp.err == nil {
+		interp.err = fmt.Errorf("assertion failed%v", msg)
+	}
+	return value{typ: vnil}
+}
+
+// assertArgs reports whether the condition of an assertion with args
+// holds, along with its message, if any, as a suffix for an error.
+func (interp *interp) assertArgs(args []value) (string, bool) {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vbool || len(args) == 2 && args[1].typ != vstring {
+		interp.err = fmt.Errorf("assert expects a bool and an optional message")
+		return "", false
+	}
+	if len(args) == 2 {
+		return ": " + args[1].v.(string), args[0].v.(bool)
+	}
+	return "", args[0].v.(bool)
+}
+
+// evalAssert evaluates the call nod of assert, which fails with an error
+// giving the position of the call and the expression that was false.
+func (interp *interp) evalAssert(nod *node) value {
+	if interp.report != nil {
+		interp.report.called("assert", true, len(interp.calls)+1)
+	}
+	var args []value
+	for _, a := range nod.list[1:] {
+		args = append(args, interp.evalRvalue(a))
+		if interp.err != nil {
+			return value{}
+		}
+	}
+	msg, ok := interp.assertArgs(args)
+	if !ok && interp.err == nil {
+		interp.err = fmt.Errorf("%v: assertion failed: %v%v", nod.list[0].pos, exprString(nod.list[1]), msg)
+	}
+	if interp.err != nil {
+		return value{}
+	}
+	return value{typ: vnil}
+}
+
+// exprString returns the source of the expression x, as near as can be
+// recovered from its syntax tree.
+func exprString(x *node) string {
+	list := func(open string, xs []*node, close string) string {
+		s := make([]string, len(xs))
+		for i, e := range xs {
+			s[i] = exprString(e)
+		}
+		return open + strings.Join(s, ", ") + close
+	}
+	switch x.kind {
+	case kident, knumlit, kstringlit:
+		return x.value.text
+	case kunaryexpr:
+		return x.value.text + exprString(x.list[0])
+	case kbinaryexpr:
+		return exprString(x.list[0]) + " " + x.value.text + " " + exprString(x.list[1])
+	case kparenexpr:
+		return "(" + exprString(x.list[0]) + ")"
+	case kcallexpr:
+		return exprString(x.list[0]) + list("(", x.list[1:], ")")
+	case kindexexpr:
+		return exprString(x.list[0]) + "[" + exprString(x.list[1]) + "]"
+	case kselectorexpr:
+		return exprString(x.list[0]) + "." + x.list[1].value.text
+	case kkvexpr:
+		return exprString(x.list[0]) + ": " + exprString(x.list[1])
+	case karraylit:
+		return list("[", x.list, "]")
+	case kmaplit:
+		return list("{", x.list, "}")
+	case ksetlit:
+		return list("#{", x.list, "}")
+	case kfunclit:
+		return "func literal"
+	}
+	return x.kind.String()
+}
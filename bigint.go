@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"text/scanner"
+)
+
+// Numbers are integers of any size. Those that fit in an int are vnums,
+// and larger ones are vbigs, holding a *big.Int that is never modified.
+// An operation on numbers whose result would overflow an int produces a
+// vbig, unless -overflow says otherwise, and one whose result fits
+// produces a vnum, so each number has exactly one representation.
+
+// An overflowMode is what arithmetic on numbers does when its result
+// doesn't fit in an int, as chosen by the -overflow flag.
+type overflowMode int
+
+const (
+	overflowBig   overflowMode = iota // promote the result to a vbig
+	overflowWrap                      // wrap around, as Go does
+	overflowError                     // fail with errOverflow
+)
+
+var overflowModes = map[string]overflowMode{
+	"big":   overflowBig,
+	"wrap":  overflowWrap,
+	"error": overflowError,
+}
+
+// A moduloMode is how / and % on numbers round when an operand is
+// negative, as chosen by the -modulo flag. Either way, a == b*(a/b) + a%b.
+type moduloMode int
+
+const (
+	// The quotient is rounded toward zero, so the remainder has the sign
+	// of the dividend, as in Go.
+	moduloTruncated moduloMode = iota
+	// The remainder is never negative.
+	moduloEuclidean
+)
+
+var moduloModes = map[string]moduloMode{
+	"truncated": moduloTruncated,
+	"euclidean": moduloEuclidean,
+}
+
+// Arithmetic fails with these errors, which are reported at the
+// operation that caused them.
+var (
+	errOverflow     = errors.New("integer overflow")
+	errDivideByZero = errors.New("division by zero")
+)
+
+// arith applies the arithmetic operator op to numbers l and r that fit in
+// ints, handling a result that doesn't as interp.overflow says.
+func (interp *interp) arith(op ttype, l, r value) value {
+	a, b := l.v.(int), r.v.(int)
+	switch {
+	case op == tpow && b < 0:
+		interp.err = fmt.Errorf("negative exponent %v", b)
+		return value{}
+	case (op == tquo || op == trem) && b == 0:
+		interp.err = errDivideByZero
+		return value{}
+	}
+	c, ok := intOp(op, a, b)
+	if ok && interp.modulo == moduloEuclidean && (op == tquo || op == trem) {
+		q, r := a/b, a%b
+		if r < 0 {
+			if b > 0 {
+				q, r = q-1, r+b
+			} else {
+				q, r = q+1, r-b
+			}
+		}
+		if c = q; op == trem {
+			c = r
+		}
+	}
+	if ok {
+		return value{typ: vnum, v: c}
+	}
+	switch interp.overflow {
+	case overflowWrap:
+		return value{typ: vnum, v: c}
+	case overflowError:
+		interp.err = errOverflow
+		return value{}
+	}
+	return interp.bigOp(op, l, r)
+}
+
+// An arithError is an arithmetic error at the position of the operation
+// that caused it.
+type arithError struct {
+	pos scanner.Position
+	err error
+}
+
+func (e *arithError) Error() string { return fmt.Sprintf("%v: %v", e.pos, e.err) }
+func (e *arithError) Unwrap() error { return e.err }
+
+// arithErrorAt adds the position of the operation nod to interp.err if
+// the operation failed, so that it can be found.
+func (interp *interp) arithErrorAt(nod *node) {
+	if interp.err == errOverflow || interp.err == errDivideByZero {
+		interp.err = &arithError{nod.pos, interp.err}
+	}
+}
+
+// numLit returns the value of the number literal text. With
+// -overflow=error, an integer literal too large for an int overflows
+// instead of becoming a big int.
+func (interp *interp) numLit(text string) value {
+	v, err := parseLiteral(text)
+	if err == nil && v.typ == vbig && interp.overflow == overflowError {
+		err = errOverflow
+	}
+	interp.err = err
+	return v
+}
+
+// parseNum returns the number written in base 10 as s.
+func parseNum(s string) (value, error) {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		return value{typ: vnum, v: n}, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		if x, ok := new(big.Int).SetString(s, 10); ok {
+			return value{typ: vbig, v: x}, nil
+		}
+	}
+	return value{}, err
+}
+
+// isInteger reports whether v is a number of either representation.
+func isInteger(v value) bool {
+	return v.typ == vnum || v.typ == vbig
+}
+
+// toBig returns the number v as a big.Int, which must not be modified.
+func toBig(v value) *big.Int {
+	if v.typ == vbig {
+		return v.v.(*big.Int)
+	}
+	return big.NewInt(int64(v.v.(int)))
+}
+
+// normBig returns x as a vnum if it fits in an int.
+func normBig(x *big.Int) value {
+	if x.IsInt64() && x.Int64() >= math.MinInt && x.Int64() <= math.MaxInt {
+		return value{typ: vnum, v: int(x.Int64())}
+	}
+	return value{typ: vbig, v: x}
+}
+
+// addInt, subInt, and mulInt return the result of an operation on a and
+// b, wrapped around if it didn't fit in an int, and whether it did.
+func addInt(a, b int) (int, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+
+func subInt(a, b int) (int, bool) {
+	c := a - b
+	return c, (c < a) == (b > 0)
+}
+
+func mulInt(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	return c, c/b == a && !(a == -1 && b == math.MinInt) && !(b == -1 && a == math.MinInt)
+}
+
+// intOp applies the arithmetic operator op to numbers a and b that fit in
+// ints, and reports whether the result does too. ok is true for
+// operators it doesn't handle, which can't overflow.
+func intOp(op ttype, a, b int) (c int, ok bool) {
+	switch op {
+	case tquo:
+		if a == math.MinInt && b == -1 {
+			return a, false
+		}
+		return a / b, true
+	case trem:
+		return a % b, true
+	case tplus:
+		return addInt(a, b)
+	case tsub:
+		return subInt(a, b)
+	case tmul:
+		return mulInt(a, b)
+	case tpow:
+		n, err := ipow(a, b)
+		return n, err == nil
+	}
+	return 0, true
+}
+
+// bigOp applies op to numbers l and r, either of which may be a vbig.
+func (interp *interp) bigOp(op ttype, l, r value) value {
+	x, y := toBig(l), toBig(r)
+	z := new(big.Int)
+	switch op {
+	case tplus:
+		z.Add(x, y)
+	case tsub:
+		z.Sub(x, y)
+	case tmul:
+		z.Mul(x, y)
+	case tquo, trem:
+		if y.Sign() == 0 {
+			interp.err = errDivideByZero
+			return value{}
+		}
+		// big.Int's Div and Mod are Euclidean.
+		switch {
+		case op == tquo && interp.modulo == moduloEuclidean:
+			z.Div(x, y)
+		case op == tquo:
+			z.Quo(x, y)
+		case interp.modulo == moduloEuclidean:
+			z.Mod(x, y)
+		default:
+			z.Rem(x, y)
+		}
+	case tpow:
+		if y.Sign() < 0 {
+			interp.err = fmt.Errorf("negative exponent %v", y)
+			return value{}
+		}
+		if !y.IsInt64() || y.Int64() > math.MaxInt32 {
+			interp.err = fmt.Errorf("exponent %v too large", y)
+			return value{}
+		}
+		z.Exp(x, y, nil)
+	case teql, tneq, tlss, tleq, tgtr, tgeq:
+		return value{typ: vbool, v: compared(op, x.Cmp(y))}
+	default:
+		interp.err = fmt.Errorf("invalid op %v", op)
+		return value{}
+	}
+	return normBig(z)
+}
+
+// compared returns the result of the comparison operator op on operands
+// whose comparison returned c.
+func compared(op ttype, c int) bool {
+	switch op {
+	case teql:
+		return c == 0
+	case tneq:
+		return c != 0
+	case tlss:
+		return c < 0
+	case tleq:
+		return c <= 0
+	case tgtr:
+		return c > 0
+	}
+	return c >= 0
+}
+
+// negate returns -v for a number, decimal, or float v. Only negating the
+// most negative int overflows.
+func (interp *interp) negate(v value) value {
+	if v.typ == vfloat {
+		return value{typ: vfloat, v: -v.v.(float64)}
+	}
+	if v.typ == vdecimal {
+		d := v.v.(*decimal)
+		return value{typ: vdecimal, v: &decimal{new(big.Int).Neg(d.unscaled), d.scale}}
+	}
+	if v.typ == vnum && v.v.(int) != math.MinInt {
+		return value{typ: vnum, v: -v.v.(int)}
+	}
+	if v.typ == vnum {
+		switch interp.overflow {
+		case overflowWrap:
+			return v
+		case overflowError:
+			interp.err = errOverflow
+			return value{}
+		}
+	}
+	return normBig(new(big.Int).Neg(toBig(v)))
+}
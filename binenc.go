@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+func init() {
+	builtins["b64encode"] = (*interp).builtinB64Encode
+	builtins["b64decode"] = (*interp).builtinB64Decode
+	builtins["hexencode"] = (*interp).builtinHexEncode
+	builtins["hexdecode"] = (*interp).builtinHexDecode
+}
+
+// base64Encodings are the names of the variants of base64 that b64encode
+// and b64decode accept: the standard and URL-safe alphabets, with padding
+// or without it.
+var base64Encodings = map[string]*base64.Encoding{
+	"std":    base64.StdEncoding,
+	"url":    base64.URLEncoding,
+	"rawstd": base64.RawStdEncoding,
+	"rawurl": base64.RawURLEncoding,
+}
+
+// binaryArg returns the bytes of args[0], which is either bytes or a
+// string, whose UTF-8 encoding is used.
+func (interp *interp) binaryArg(fn string, args []value) ([]byte, bool) {
+	if len(args) < 1 {
+		interp.err = fmt.Errorf("%v expects a string or bytes", fn)
+		return nil, false
+	}
+	switch args[0].typ {
+	case vbytes:
+		return args[0].v.([]byte), true
+	case vstring:
+		return []byte(args[0].v.(string)), true
+	}
+	interp.err = fmt.Errorf("%v expects a string or bytes", fn)
+	return nil, false
+}
+
+// base64Arg returns the variant of base64 named by the optional argument
+// at i of args, which defaults to std.
+func (interp *interp) base64Arg(fn string, args []value, i int) (*base64.Encoding, bool) {
+	name := "std"
+	if len(args) > i {
+		if args[i].typ != vstring {
+			interp.err = fmt.Errorf("%v expects the name of a base64 variant", fn)
+			return nil, false
+		}
+		name = args[i].v.(string)
+	}
+	enc, ok := base64Encodings[name]
+	if !ok {
+		interp.err = fmt.Errorf("%v: unknown base64 variant %v", fn, name)
+	}
+	return enc, ok
+}
+
+// builtinB64Encode returns the base64 encoding of a string or bytes, in
+// an optional variant.
+func (interp *interp) builtinB64Encode(args []value) value {
+	if len(args) > 2 {
+		interp.err = fmt.Errorf("b64encode expects a string or bytes and an optional variant")
+		return value{}
+	}
+	b, ok := interp.binaryArg("b64encode", args)
+	if !ok {
+		return value{}
+	}
+	enc, ok := interp.base64Arg("b64encode", args, 1)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: enc.EncodeToString(b)}
+}
+
+// builtinB64Decode returns the bytes encoded by a base64 string in an
+// optional variant. A string that isn't valid in the variant results in
+// an error value.
+func (interp *interp) builtinB64Decode(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("b64decode expects a string and an optional variant")
+		return value{}
+	}
+	enc, ok := interp.base64Arg("b64decode", args, 1)
+	if !ok {
+		return value{}
+	}
+	b, err := enc.DecodeString(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("b64decode: %v", err))
+	}
+	return value{typ: vbytes, v: b}
+}
+
+// builtinHexEncode returns the lowercase hexadecimal encoding of a string
+// or bytes.
+func (interp *interp) builtinHexEncode(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("hexencode expects a string or bytes")
+		return value{}
+	}
+	b, ok := interp.binaryArg("hexencode", args)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: hex.EncodeToString(b)}
+}
+
+// builtinHexDecode returns the bytes encoded by a hexadecimal string, in
+// either case. A string that isn't valid hexadecimal results in an error
+// value.
+func (interp *interp) builtinHexDecode(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("hexdecode expects a string")
+		return value{}
+	}
+	b, err := hex.DecodeString(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("hexdecode: %v", err))
+	}
+	return value{typ: vbytes, v: b}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	nativeModule("bitset", map[string]builtin{
+		"new":   (*interp).bitsetNew,
+		"set":   bitsetUpdate("set", (*bitset).set),
+		"clear": bitsetUpdate("clear", (*bitset).clear),
+		"test":  (*interp).bitsetTest,
+		"count": (*interp).bitsetCount,
+		"and":   bitsetCombine("and", func(a, b uint64) uint64 { return a & b }),
+		"or":    bitsetCombine("or", func(a, b uint64) uint64 { return a | b }),
+		"xor":   bitsetCombine("xor", func(a, b uint64) uint64 { return a ^ b }),
+		"list":  (*interp).bitsetList,
+	})
+}
+
+// A bitset is a set of non-negative integers, stored as one bit per
+// integer up to the largest member.
+type bitset struct {
+	words []uint64
+}
+
+// maxBit bounds the members of a bitset, since a bitset takes memory in
+// proportion to its largest member.
+const maxBit = 1<<31 - 1
+
+func (b *bitset) set(i int) error {
+	if i > maxBit {
+		return fmt.Errorf("%v is too large for a bitset, whose members are at most %v", i, maxBit)
+	}
+	if n := i/64 + 1 - len(b.words); n > 0 {
+		b.words = append(b.words, make([]uint64, n)...)
+	}
+	b.words[i/64] |= 1 << uint(i%64)
+	return nil
+}
+
+func (b *bitset) clear(i int) error {
+	if i/64 < len(b.words) {
+		b.words[i/64] &^= 1 << uint(i%64)
+	}
+	// Trim zero words so that equal sets compare equal.
+	for len(b.words) > 0 && b.words[len(b.words)-1] == 0 {
+		b.words = b.words[:len(b.words)-1]
+	}
+	return nil
+}
+
+func (b *bitset) test(i int) bool {
+	return i/64 < len(b.words) && b.words[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (b *bitset) count() int {
+	n := 0
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// each calls fn with each member of b in increasing order.
+func (b *bitset) each(fn func(int)) {
+	for i, w := range b.words {
+		for w != 0 {
+			fn(i*64 + bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+}
+
+func (b *bitset) String() string {
+	var sb strings.Builder
+	sb.WriteString("bitset{")
+	first := true
+	b.each(func(i int) {
+		if !first {
+			sb.WriteString(",")
+		}
+		first = false
+		sb.WriteString(strconv.Itoa(i))
+	})
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (interp *interp) bitset(fn string, v value) *bitset {
+	b, ok := v.v.(*bitset)
+	if !ok || v.typ != vbitset {
+		interp.err = fmt.Errorf("%v expects a bitset", fn)
+		return nil
+	}
+	return b
+}
+
+// bitArgs checks the arguments of a builtin taking a bitset and a bit.
+func (interp *interp) bitArgs(fn string, args []value) (*bitset, int) {
+	if len(args) != 2 || args[1].typ != vnum || args[1].v.(int) < 0 {
+		interp.err = fmt.Errorf("%v expects a bitset and a non-negative integer", fn)
+		return nil, 0
+	}
+	return interp.bitset(fn, args[0]), args[1].v.(int)
+}
+
+// bitsetNew returns a bitset containing the numbers in an optional array.
+func (interp *interp) bitsetNew(args []value) value {
+	if len(args) > 1 || len(args) == 1 && args[0].typ != varray {
+		interp.err = fmt.Errorf("bitset.new expects an optional array of non-negative integers")
+		return value{}
+	}
+	b := new(bitset)
+	if len(args) == 1 {
+		for _, e := range args[0].entries() {
+			if e.v.typ != vnum || e.v.v.(int) < 0 {
+				interp.err = fmt.Errorf("bitset.new expects an optional array of non-negative integers")
+				return value{}
+			}
+			if err := b.set(e.v.v.(int)); err != nil {
+				interp.err = fmt.Errorf("bitset.new: %v", err)
+				return value{}
+			}
+		}
+	}
+	return value{typ: vbitset, v: b}
+}
+
+// bitsetUpdate returns a builtin that modifies a bitset in place.
+func bitsetUpdate(name string, update func(*bitset, int) error) builtin {
+	return func(interp *interp, args []value) value {
+		b, i := interp.bitArgs("bitset."+name, args)
+		if b == nil {
+			return value{}
+		}
+		if err := update(b, i); err != nil {
+			interp.err = fmt.Errorf("bitset.%v: %v", name, err)
+		}
+		return value{}
+	}
+}
+
+func (interp *interp) bitsetTest(args []value) value {
+	b, i := interp.bitArgs("bitset.test", args)
+	if b == nil {
+		return value{}
+	}
+	return value{typ: vbool, v: b.test(i)}
+}
+
+func (interp *interp) bitsetCount(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("bitset.count expects a bitset")
+		return value{}
+	}
+	b := interp.bitset("bitset.count", args[0])
+	if b == nil {
+		return value{}
+	}
+	return value{typ: vnum, v: b.count()}
+}
+
+// bitsetCombine returns a builtin that returns a new bitset whose words
+// are op applied to the words of two bitsets.
+func bitsetCombine(name string, op func(a, b uint64) uint64) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) != 2 {
+			interp.err = fmt.Errorf("bitset.%v expects two bitsets", name)
+			return value{}
+		}
+		a, b := interp.bitset("bitset."+name, args[0]), interp.bitset("bitset."+name, args[1])
+		if a == nil || b == nil {
+			return value{}
+		}
+		r := &bitset{words: make([]uint64, max(len(a.words), len(b.words)))}
+		for i := range r.words {
+			var x, y uint64
+			if i < len(a.words) {
+				x = a.words[i]
+			}
+			if i < len(b.words) {
+				y = b.words[i]
+			}
+			r.words[i] = op(x, y)
+		}
+		for len(r.words) > 0 && r.words[len(r.words)-1] == 0 {
+			r.words = r.words[:len(r.words)-1]
+		}
+		return value{typ: vbitset, v: r}
+	}
+}
+
+// bitsetList returns an array of the members of a bitset in increasing
+// order.
+func (interp *interp) bitsetList(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("bitset.list expects a bitset")
+		return value{}
+	}
+	b := interp.bitset("bitset.list", args[0])
+	if b == nil {
+		return value{}
+	}
+	r := new(array)
+	b.each(func(i int) {
+		r.entries = append(r.entries, struct{ k, v value }{value{typ: vnum, v: len(r.entries)}, value{typ: vnum, v: i}})
+	})
+	return value{typ: varray, v: r}
+}
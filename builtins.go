@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// A builtin is a function implemented in Go.
+type builtin func(interp *interp, args []value) value
+
+// A builtinFunc is the value of a builtin. Go functions can't be
+// compared, so it is identified by the name the builtin was registered
+// with, qualified by its module's name if it has one.
+type builtinFunc struct {
+	name string
+	fn   builtin
+}
+
+// builtins maps names to functions that are visible in any scope in which
+// the name isn't otherwise bound.
+var builtins = make(map[string]builtin)
+
+// constants maps names to values that are visible in the same way as
+// builtins.
+var constants = make(map[string]value)
+
+// natives maps names to modules implemented in Go, which are visible in
+// the same way as builtins. Modules that are only available in some builds
+// register themselves from their own files.
+var natives = make(map[string]*module)
+
+func init() {
+	builtins["reload"] = (*interp).builtinReload
+	nativeModule("resource", map[string]builtin{
+		"read":      (*interp).resourceRead,
+		"readbytes": (*interp).resourceReadBytes,
+	})
+	nativeModule("task", map[string]builtin{
+		"run":   (*interp).taskRun,
+		"start": (*interp).taskStart,
+		"wait":  (*interp).taskWait,
+	})
+}
+
+// allowed reports whether the builtin fn may be called, and sets an error
+// if the interpreter is sandboxed.
+func (interp *interp) allowed(fn string) bool {
+	if interp.sandbox {
+		interp.err = fmt.Errorf("%v is not allowed in the sandbox", fn)
+		return false
+	}
+	return true
+}
+
+// nativeModule registers an initialized module exporting fns.
+func nativeModule(name string, fns map[string]builtin) {
+	m := &module{
+		name:    name,
+		env:     newEnv(nil),
+		exports: make(map[string]bool),
+		state:   loaded,
+	}
+	for k, fn := range fns {
+		m.env.m[k] = value{typ: vfunc, v: &builtinFunc{name + "." + k, fn}}
+		m.exports[k] = true
+	}
+	natives[name] = m
+}
+
+func (interp *interp) builtinReload(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("reload expects a module name")
+		return value{}
+	}
+	ok, err := interp.reload(args[0].v.(string))
+	if err != nil {
+		interp.err = err
+		return value{}
+	}
+	return value{typ: vbool, v: ok}
+}
+
+// resourceRead returns the contents of a resource bundled with the program
+// as a string. When the program isn't packed, resources are read from the
+// directory of the program.
+func (interp *interp) resourceRead(args []value) value {
+	b, ok := interp.resource("resource.read", args)
+	if !ok {
+		return b
+	}
+	return value{typ: vstring, v: string(b.v.([]byte))}
+}
+
+// resourceReadBytes is like resourceRead, but returns the contents as bytes.
+func (interp *interp) resourceReadBytes(args []value) value {
+	b, _ := interp.resource("resource.readbytes", args)
+	return b
+}
+
+// resource returns the contents of the resource named by args as bytes,
+// and whether it could be read. If it couldn't, the result is an error
+// value, or the call failed.
+func (interp *interp) resource(fn string, args []value) (value, bool) {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("%v expects a resource name", fn)
+		return value{}, false
+	}
+	name := args[0].v.(string)
+	if packed != nil {
+		b, ok := packed.Resources[filepath.ToSlash(filepath.Clean(name))]
+		if !ok {
+			return interp.failure(fmt.Errorf("no resource named %v", name)), false
+		}
+		return value{typ: vbytes, v: b}, true
+	}
+	// Resources bundled into a packed program are part of it, but those
+	// read from files next to the script aren't allowed in the sandbox.
+	if !interp.allowed(fn) {
+		return value{}, false
+	}
+	b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(interp.main), name))
+	if err != nil {
+		return interp.failure(err), false
+	}
+	return value{typ: vbytes, v: b}, true
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func init() {
+	builtins["bytes"] = (*interp).builtinBytes
+	builtins["decode"] = (*interp).builtinDecode
+	builtins["slice"] = (*interp).builtinSlice
+}
+
+// Bytes are immutable sequences of numbers from 0 through 255, for binary
+// data. They can be indexed, sliced, concatenated with +, and compared
+// with == like strings, and iterating over them yields each byte.
+
+func bytesString(b []byte) string {
+	return fmt.Sprintf("b%q", b)
+}
+
+// encodings are the names of the encodings bytes and decode convert
+// between, each with its encoder and decoder. Encoders fail on characters
+// the encoding can't represent, and decoders on invalid input.
+var encodings = map[string]struct {
+	encode func(string) ([]byte, error)
+	decode func([]byte) (string, error)
+}{
+	"utf-8": {
+		func(s string) ([]byte, error) { return []byte(s), nil },
+		func(b []byte) (string, error) {
+			if !utf8.Valid(b) {
+				return "", fmt.Errorf("invalid utf-8")
+			}
+			return string(b), nil
+		},
+	},
+	"latin1": {
+		func(s string) ([]byte, error) { return encodeByte(s, 0xff) },
+		func(b []byte) (string, error) {
+			rs := make([]rune, len(b))
+			for i, c := range b {
+				rs[i] = rune(c)
+			}
+			return string(rs), nil
+		},
+	},
+	"ascii": {
+		func(s string) ([]byte, error) { return encodeByte(s, 0x7f) },
+		func(b []byte) (string, error) {
+			for _, c := range b {
+				if c > 0x7f {
+					return "", fmt.Errorf("invalid ascii byte %#x", c)
+				}
+			}
+			return string(b), nil
+		},
+	},
+	"utf-16le": {
+		func(s string) ([]byte, error) { return encodeUTF16(s, false), nil },
+		func(b []byte) (string, error) { return decodeUTF16(b, false) },
+	},
+	"utf-16be": {
+		func(s string) ([]byte, error) { return encodeUTF16(s, true), nil },
+		func(b []byte) (string, error) { return decodeUTF16(b, true) },
+	},
+}
+
+// encodeByte encodes each character of s as a single byte, failing if
+// one is greater than max.
+func encodeByte(s string, max rune) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > max {
+			return nil, fmt.Errorf("cannot encode %q", r)
+		}
+		b = append(b, byte(r))
+	}
+	return b, nil
+}
+
+func encodeUTF16(s string, bigEndian bool) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return b
+}
+
+func decodeUTF16(b []byte, bigEndian bool) (string, error) {
+	if len(b)%2 != 0 {
+		return "", fmt.Errorf("odd number of bytes in utf-16")
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			u[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// encodingArg returns the encoding named by the optional argument at i of
+// args, which defaults to utf-8.
+func (interp *interp) encodingArg(fn string, args []value, i int) (string, bool) {
+	name := "utf-8"
+	if len(args) > i {
+		if args[i].typ != vstring {
+			interp.err = fmt.Errorf("%v expects the name of an encoding", fn)
+			return "", false
+		}
+		name = strings.ToLower(args[i].v.(string))
+	}
+	if _, ok := encodings[name]; !ok {
+		interp.err = fmt.Errorf("%v: unknown encoding %v", fn, name)
+		return "", false
+	}
+	return name, true
+}
+
+// builtinBytes returns the bytes of a string in an optional encoding, or
+// the bytes whose values are in an array of numbers. A string with
+// characters the encoding can't represent results in an error value.
+func (interp *interp) builtinBytes(args []value) value {
+	if len(args) == 1 && args[0].typ == varray {
+		b := make([]byte, 0, len(args[0].entries()))
+		for _, e := range args[0].entries() {
+			if e.v.typ != vnum || e.v.v.(int) < 0 || e.v.v.(int) > 255 {
+				interp.err = fmt.Errorf("bytes expects an array of numbers from 0 through 255")
+				return value{}
+			}
+			b = append(b, byte(e.v.v.(int)))
+		}
+		return value{typ: vbytes, v: b}
+	}
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("bytes expects a string and an optional encoding, or an array")
+		return value{}
+	}
+	enc, ok := interp.encodingArg("bytes", args, 1)
+	if !ok {
+		return value{}
+	}
+	b, err := encodings[enc].encode(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("bytes: %v", err))
+	}
+	return value{typ: vbytes, v: b}
+}
+
+// builtinDecode returns the string encoded by bytes in an optional
+// encoding. Bytes that aren't valid in the encoding result in an error
+// value.
+func (interp *interp) builtinDecode(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vbytes {
+		interp.err = fmt.Errorf("decode expects bytes and an optional encoding")
+		return value{}
+	}
+	enc, ok := interp.encodingArg("decode", args, 1)
+	if !ok {
+		return value{}
+	}
+	s, err := encodings[enc].decode(args[0].v.([]byte))
+	if err != nil {
+		return interp.failure(fmt.Errorf("decode: %v", err))
+	}
+	return value{typ: vstring, v: s}
+}
+
+func bytesEqual(a, b value) bool {
+	return bytes.Equal(a.v.([]byte), b.v.([]byte))
+}
+
+// bytesIndex returns the byte of b at i.
+func (interp *interp) bytesIndex(b []byte, i value) value {
+	if i.typ != vnum {
+		interp.err = fmt.Errorf("cannot index bytes with %v", i.typ)
+		return value{}
+	}
+	n := i.v.(int)
+	if n < 0 || n >= len(b) {
+		interp.err = fmt.Errorf("index %v out of range for %v bytes", n, len(b))
+		return value{}
+	}
+	return value{typ: vnum, v: int(b[n])}
+}
+
+// builtinSlice returns the bytes, or the characters of a string, from the
+// position lo up to but not including hi, which defaults to the end.
+func (interp *interp) builtinSlice(args []value) value {
+	if len(args) < 2 || len(args) > 3 || args[0].typ != vbytes && args[0].typ != vstring {
+		interp.err = fmt.Errorf("slice expects bytes or a string, a start, and an optional end")
+		return value{}
+	}
+	var n int
+	if args[0].typ == vbytes {
+		n = len(args[0].v.([]byte))
+	} else {
+		n = utf8.RuneCountInString(args[0].v.(string))
+	}
+	lo, hi := args[1], value{typ: vnum, v: n}
+	if len(args) == 3 {
+		hi = args[2]
+	}
+	if lo.typ != vnum || hi.typ != vnum {
+		interp.err = fmt.Errorf("slice expects numbers for its start and end")
+		return value{}
+	}
+	i, j := lo.v.(int), hi.v.(int)
+	if i < 0 || j < i || j > n {
+		interp.err = fmt.Errorf("slice bounds [%v:%v] out of range for length %v", i, j, n)
+		return value{}
+	}
+	if args[0].typ == vbytes {
+		return value{typ: vbytes, v: args[0].v.([]byte)[i:j:j]}
+	}
+	rs := []rune(args[0].v.(string))
+	return value{typ: vstring, v: string(rs[i:j])}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/scanner"
+	"time"
+)
+
+// cacheVersion must be changed whenever the passes run on the tree change,
+// so that stale entries are ignored. Changes to the kinds of nodes and
+// tokens are accounted for by cacheKey.
+const cacheVersion = "refgc-3"
+
+// cacheDir is where compiled files are cached. Caching is disabled if it
+// is empty.
+var cacheDir string
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "refgc")
+}
+
+// cnode is the serialized form of a node.
+type cnode struct {
+	Nil   bool
+	Kind  kind
+	Name  string
+	Pos   scanner.Position
+	Ttype ttype
+	Text  string
+	TPos  scanner.Position
+	List  []cnode
+}
+
+func encodeNode(n *node) cnode {
+	if n == nil {
+		return cnode{Nil: true}
+	}
+	c := cnode{
+		Kind:  n.kind,
+		Name:  n.name,
+		Pos:   n.pos,
+		Ttype: n.value.ttype,
+		Text:  n.value.text,
+		TPos:  n.value.pos,
+	}
+	for _, x := range n.list {
+		c.List = append(c.List, encodeNode(x))
+	}
+	return c
+}
+
+func decodeNode(c cnode) *node {
+	if c.Nil {
+		return nil
+	}
+	n := &node{
+		kind:  c.Kind,
+		name:  c.Name,
+		pos:   c.Pos,
+		value: token{ttype: c.Ttype, pos: c.TPos, text: c.Text},
+	}
+	for _, x := range c.List {
+		n.list = append(n.list, decodeNode(x))
+	}
+	return n
+}
+
+// cacheKey identifies the compiled form of the source src read from name.
+func cacheKey(name string, src []byte) string {
+	h := sha256.New()
+	h.Write([]byte(cacheVersion))
+	h.Write([]byte{0})
+	h.Write([]byte(_kind_name))
+	h.Write([]byte(_ttype_name))
+	h.Write([]byte{0})
+	h.Write([]byte(runtime.GOOS + "/" + runtime.GOARCH)) // for when statements
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readCache(key string) (*node, bool) {
+	if cacheDir == "" {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(filepath.Join(cacheDir, key))
+	if err != nil {
+		return nil, false
+	}
+	var c cnode
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&c); err != nil {
+		return nil, false
+	}
+	// Mark the entry as used, so that pruneCache keeps it.
+	now := time.Now()
+	os.Chtimes(filepath.Join(cacheDir, key), now, now)
+	return decodeNode(c), true
+}
+
+// Entries that haven't been used for cacheMaxAge are removed, which is
+// checked at most once every pruneInterval.
+const (
+	cacheMaxAge   = 30 * 24 * time.Hour
+	pruneInterval = 24 * time.Hour
+	pruneMarker   = "pruned"
+)
+
+// pruneCache removes the entries that haven't been used recently, so that
+// old versions of files don't accumulate in the cache. Failures are
+// ignored.
+func pruneCache() {
+	if cacheDir == "" {
+		return
+	}
+	marker := filepath.Join(cacheDir, pruneMarker)
+	if fi, err := os.Stat(marker); err == nil && time.Since(fi.ModTime()) < pruneInterval {
+		return
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+	if f, err := os.Create(marker); err == nil {
+		f.Close()
+	}
+	for _, e := range entries {
+		if e.Name() == pruneMarker || e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		// Temporary files are left by writers that didn't finish.
+		if time.Since(fi.ModTime()) > cacheMaxAge || strings.Contains(e.Name(), ".tmp") && time.Since(fi.ModTime()) > time.Hour {
+			os.Remove(filepath.Join(cacheDir, e.Name()))
+		}
+	}
+}
+
+// writeCache stores n under key. Failures are ignored, since the cache is
+// only an optimization.
+func writeCache(key string, n *node) {
+	if cacheDir == "" {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(encodeNode(n)); err != nil {
+		return
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(cacheDir, key+".tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || os.Rename(f.Name(), filepath.Join(cacheDir, key)) != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,782 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/scanner"
+)
+
+// A tset is a set of the types a value may have at run time, with a bit
+// for each vtype.
+type tset uint64
+
+const anyType = ^tset(0)
+
+func typesOf(ts ...vtype) tset {
+	var s tset
+	for _, t := range ts {
+		s |= 1 << uint(t)
+	}
+	return s
+}
+
+func (s tset) has(t vtype) bool { return s&(1<<uint(t)) != 0 }
+
+func (s tset) String() string {
+	if s == anyType {
+		return "any"
+	}
+	if s == 0 {
+		return "none"
+	}
+	var names []string
+	for t := verr; !strings.HasPrefix(t.String(), "vtype("); t++ {
+		if s.has(t) {
+			names = append(names, typeName(t))
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// A binding is what the checker knows about a variable: the types it may
+// have, and the function it holds, if it always holds the same one.
+type binding struct {
+	t  tset
+	fn *node
+}
+
+// A cscope is the checker's view of a scope. Variables of the scopes
+// outside a function's are treated as having any type inside it, since
+// they may be changed between when it is defined and when it is called.
+type cscope struct {
+	parent *cscope
+	fn     bool // whether this is the outermost scope of a function body
+	m      map[string]binding
+}
+
+// A checker finds type errors in a program without running it. It
+// reports only operations that would fail whatever types their operands
+// have at run time, and assumes nothing about values it can't follow,
+// like array elements and the results of builtins.
+type checker struct {
+	scope *cscope
+	errs  map[string]bool
+
+	// rets holds the types a function's results may have, once its body
+	// has been checked, and checking the functions being checked.
+	rets     map[*node]tset
+	checking map[*node]bool
+	ret      tset // results of the function being checked
+
+	// idents holds what was inferred about each occurrence of an
+	// identifier, joined over every time it was checked.
+	idents map[scanner.Position]inferred
+}
+
+// inferred is what the checker inferred about an identifier.
+type inferred struct {
+	name string
+	b    binding
+}
+
+func newChecker() *checker {
+	return &checker{
+		errs:     make(map[string]bool),
+		rets:     make(map[*node]tset),
+		checking: make(map[*node]bool),
+		idents:   make(map[scanner.Position]inferred),
+	}
+}
+
+// record notes that the identifier id is described by b where it occurs.
+func (c *checker) record(id *node, b binding) {
+	if old, ok := c.idents[id.pos]; ok {
+		if old.b.fn != b.fn {
+			b.fn = nil
+		}
+		b.t |= old.b.t
+	}
+	c.idents[id.pos] = inferred{id.value.text, b}
+}
+
+// describe formats what is known about a binding, giving the parameters
+// and results of the function it holds, if any.
+func (c *checker) describe(b binding) string {
+	if b.fn == nil || b.t != typesOf(vfunc) {
+		return b.t.String()
+	}
+	var params []string
+	for _, p := range b.fn.list[:len(b.fn.list)-1] {
+		params = append(params, p.value.text)
+	}
+	return fmt.Sprintf("func(%v) %v", strings.Join(params, ", "), c.rets[b.fn])
+}
+
+func (c *checker) errorf(format string, args ...interface{}) {
+	c.errs[fmt.Sprintf(format, args...)] = true
+}
+
+func (c *checker) push(fn bool) {
+	c.scope = &cscope{parent: c.scope, fn: fn, m: make(map[string]binding)}
+}
+
+func (c *checker) pop() { c.scope = c.scope.parent }
+
+// lookup returns the binding of name, and whether it was found.
+func (c *checker) lookup(name string) (binding, bool) {
+	opaque := false
+	for s := c.scope; s != nil; s = s.parent {
+		if b, ok := s.m[name]; ok {
+			if opaque {
+				return binding{t: anyType}, true
+			}
+			return b, true
+		}
+		opaque = opaque || s.fn
+	}
+	return binding{}, false
+}
+
+// inFunc reports whether the checker is in the body of a function.
+func (c *checker) inFunc() bool {
+	for s := c.scope; s != nil; s = s.parent {
+		if s.fn {
+			return true
+		}
+	}
+	return false
+}
+
+// assign records that name was assigned a value described by b. As when
+// running the program, it is bound in the current scope if it doesn't
+// exist yet. A variable of an enclosing function's scope may be assigned
+// when the function is called, so it could have any type from then on.
+func (c *checker) assign(name string, b binding) {
+	opaque := false
+	for s := c.scope; s != nil; s = s.parent {
+		if _, ok := s.m[name]; ok {
+			if opaque {
+				b = binding{t: anyType}
+			}
+			s.m[name] = b
+			return
+		}
+		opaque = opaque || s.fn
+	}
+	c.scope.m[name] = b
+}
+
+// snapshot returns a copy of the bindings of every scope.
+func (c *checker) snapshot() []map[string]binding {
+	var snap []map[string]binding
+	for s := c.scope; s != nil; s = s.parent {
+		m := make(map[string]binding, len(s.m))
+		for k, v := range s.m {
+			m[k] = v
+		}
+		snap = append(snap, m)
+	}
+	return snap
+}
+
+// join merges the bindings in snap into the current ones, for after a
+// branch that may or may not have been taken.
+func (c *checker) join(snap []map[string]binding) {
+	i := 0
+	for s := c.scope; s != nil; s = s.parent {
+		for k, old := range snap[i] {
+			b, ok := s.m[k]
+			switch {
+			case !ok:
+				s.m[k] = old
+			case b.fn != old.fn:
+				s.m[k] = binding{t: b.t | old.t}
+			default:
+				s.m[k] = binding{t: b.t | old.t, fn: b.fn}
+			}
+		}
+		i++
+	}
+}
+
+// restore replaces the current bindings with those in snap.
+func (c *checker) restore(snap []map[string]binding) {
+	i := 0
+	for s := c.scope; s != nil; s = s.parent {
+		s.m = snap[i]
+		i++
+	}
+}
+
+func (c *checker) file(af *node) {
+	c.push(false)
+	c.stmts(af.list)
+	c.pop()
+}
+
+func (c *checker) block(b *node) {
+	c.push(false)
+	c.stmts(b.list)
+	c.pop()
+}
+
+// stmts checks a list of statements, after binding the functions they
+// declare, as evalStmts does.
+func (c *checker) stmts(list []*node) {
+	for _, s := range list {
+		if s.kind == kexportstmt {
+			s = s.list[0]
+		}
+		if s.kind == kfuncdecl {
+			b := binding{t: typesOf(vfunc), fn: s.list[1]}
+			c.scope.m[s.list[0].value.text] = b
+			c.record(s.list[0], b)
+		}
+	}
+	for _, s := range list {
+		c.stmt(s)
+	}
+}
+
+// loop checks a loop body until the types of the variables it changes
+// stop growing, so that each iteration sees the types left by the last.
+func (c *checker) loop(body func()) {
+	for i := 0; i < 3; i++ {
+		before := c.snapshot()
+		body()
+		c.join(before)
+		after := c.snapshot()
+		same := true
+		for j := range before {
+			for k, b := range after[j] {
+				if before[j][k].t != b.t {
+					same = false
+				}
+			}
+		}
+		if same {
+			return
+		}
+	}
+}
+
+func (c *checker) stmt(n *node) {
+	switch n.kind {
+	case kassignstmt:
+		lhs, rhs := n.list[0], n.list[1]
+		if n.value.ttype != tillegal {
+			t := c.binary(n, assignOps[n.value.ttype], c.expr(lhs), c.expr(rhs))
+			if lhs.kind == kident {
+				c.assign(lhs.value.text, binding{t: t})
+				c.record(lhs, binding{t: t})
+			} else {
+				c.expr(lhs)
+			}
+			return
+		}
+		if lhs.kind == karraylit {
+			c.expr(rhs)
+			for _, e := range lhs.list {
+				if e.kind == kkvexpr {
+					c.expr(e.list[0])
+					e = e.list[1]
+				}
+				c.target(e, binding{t: anyType})
+			}
+			return
+		}
+		t := c.expr(rhs)
+		b := binding{t: t}
+		if rhs.kind == kfunclit {
+			b.fn = rhs
+		}
+		c.target(lhs, b)
+	case kletstmt:
+		lhs, rhs := n.list[0], n.list[1]
+		b := binding{t: typesOf(vnil)}
+		if rhs != nil {
+			b.t = c.expr(rhs)
+			if rhs.kind == kfunclit {
+				b.fn = rhs
+			}
+		}
+		names := []*node{lhs}
+		if lhs.kind == karraylit {
+			names, b = lhs.list, binding{t: anyType}
+		}
+		for _, id := range names {
+			c.scope.m[id.value.text] = b
+			c.record(id, b)
+		}
+	case kblockstmt:
+		c.block(n)
+	case kifstmt:
+		c.expr(n.list[0])
+		before := c.snapshot()
+		c.block(n.list[1])
+		if len(n.list) == 3 {
+			taken := c.snapshot()
+			c.restore(before)
+			c.stmt(n.list[2])
+			c.join(taken)
+		} else {
+			c.join(before)
+		}
+	case kexprstmt:
+		c.expr(n.list[0])
+	case kwhilestmt:
+		c.expr(n.list[0])
+		c.loop(func() {
+			c.block(n.list[1])
+			c.expr(n.list[0])
+		})
+	case kforstmt:
+		k, v, x, body := n.list[0], n.list[1], n.list[2], n.list[3]
+		xt := c.expr(x)
+		if xt&typesOf(varray, vstring, vnum, vsortedmap, vmap, vset, vbytes, vobject) == 0 && xt != 0 {
+			c.errorf("%v: cannot iterate over %v", x.pos, xt)
+		}
+		c.loop(func() {
+			c.push(false)
+			if k != nil {
+				c.scope.m[k.value.text] = binding{t: anyType}
+				c.record(k, binding{t: anyType})
+			}
+			c.scope.m[v.value.text] = binding{t: anyType}
+			c.record(v, binding{t: anyType})
+			c.block(body)
+			c.pop()
+		})
+	case kreturnstmt:
+		switch len(n.list) {
+		case 0:
+			c.ret |= typesOf(vnil)
+		case 1:
+			c.ret |= c.expr(n.list[0])
+		default:
+			for _, x := range n.list {
+				c.expr(x)
+			}
+			c.ret |= typesOf(vtuple)
+		}
+	case kimportstmt:
+		name := strings.TrimSuffix(n.value.text, `"`)
+		name = name[strings.LastIndexAny(name, `/"`)+1:]
+		if i := strings.LastIndexByte(name, '.'); i > 0 {
+			name = name[:i]
+		}
+		if len(n.list) > 0 {
+			name = n.list[0].value.text
+		}
+		c.scope.m[name] = binding{t: typesOf(vmodule)}
+	case kexportstmt:
+		c.stmt(n.list[0])
+	case kfuncdecl:
+		c.function(n.list[1])
+	case kclassdecl:
+		if n.list[1] != nil {
+			c.expr(n.list[1])
+		}
+		c.scope.m[n.list[0].value.text] = binding{t: typesOf(vclass)}
+		c.record(n.list[0], binding{t: typesOf(vclass)})
+		c.push(false)
+		c.scope.m["super"] = binding{t: typesOf(vclass)}
+		for _, m := range n.list[2:] {
+			c.function(m.list[1])
+		}
+		c.pop()
+	case ktrystmt:
+		body, name, catch, finally := n.list[0], n.list[1], n.list[2], n.list[3]
+		before := c.snapshot()
+		c.block(body)
+		c.join(before)
+		if catch != nil {
+			c.push(false)
+			if name != nil {
+				c.scope.m[name.value.text] = binding{t: typesOf(verror)}
+				c.record(name, binding{t: typesOf(verror)})
+			}
+			before := c.snapshot()
+			c.block(catch)
+			c.join(before)
+			c.pop()
+		}
+		if finally != nil {
+			c.block(finally)
+		}
+	case kthrowstmt:
+		c.expr(n.list[0])
+	}
+}
+
+// target records an assignment of a value described by b to x.
+func (c *checker) target(x *node, b binding) {
+	if x.kind == kident {
+		c.assign(x.value.text, b)
+		c.record(x, b)
+		return
+	}
+	c.expr(x)
+}
+
+// function checks the body of the function literal f, if it hasn't been
+// already, and returns the types of its results.
+func (c *checker) function(f *node) tset {
+	if t, ok := c.rets[f]; ok {
+		return t
+	}
+	if c.checking[f] {
+		return anyType
+	}
+	c.checking[f] = true
+	saved := c.ret
+	c.ret = 0
+	c.push(true)
+	for _, p := range f.list[:len(f.list)-1] {
+		c.scope.m[p.value.text] = binding{t: anyType}
+		c.record(p, binding{t: anyType})
+	}
+	body := f.list[len(f.list)-1]
+	c.stmts(body.list)
+	if len(body.list) == 0 || body.list[len(body.list)-1].kind != kreturnstmt {
+		c.ret |= typesOf(vnil)
+	}
+	c.pop()
+	t := c.ret
+	c.ret = saved
+	c.rets[f] = t
+	delete(c.checking, f)
+	return t
+}
+
+func (c *checker) expr(n *node) tset {
+	switch n.kind {
+	case karraylit:
+		for _, e := range n.list {
+			c.expr(e)
+		}
+		return typesOf(varray)
+	case kkvexpr:
+		c.expr(n.list[0])
+		return c.expr(n.list[1])
+	case knumlit:
+		if v, err := parseLiteral(n.value.text); err == nil {
+			return typesOf(v.typ)
+		}
+		return typesOf(vnum)
+	case kstringlit:
+		return typesOf(vstring)
+	case kfunclit:
+		c.function(n)
+		return typesOf(vfunc)
+	case kstructlit:
+		return typesOf(vstruct)
+	case kinterfacelit:
+		return typesOf(vinterface)
+	case kmaplit, ksetlit:
+		for _, e := range n.list {
+			c.expr(e)
+		}
+		if n.kind == ksetlit {
+			return typesOf(vset)
+		}
+		return typesOf(vmap)
+	case kident:
+		switch n.value.text {
+		case "true", "false":
+			return typesOf(vbool)
+		case "nil":
+			return typesOf(vnil)
+		}
+		if b, ok := c.lookup(n.value.text); ok {
+			c.record(n, b)
+			return b.t
+		}
+		if _, ok := builtins[n.value.text]; ok {
+			return typesOf(vfunc)
+		}
+		if c, ok := constants[n.value.text]; ok {
+			return typesOf(c.typ)
+		}
+		if _, ok := natives[n.value.text]; ok {
+			return typesOf(vmodule)
+		}
+		// A function may refer to a variable bound after it is defined,
+		// but nothing else may.
+		if !c.inFunc() && n.value.text != "input" {
+			c.errorf("%v: no identifier named %v exists", n.pos, n.value.text)
+		}
+		return anyType
+	case kunaryexpr:
+		t := c.expr(n.list[0])
+		switch n.value.ttype {
+		case tsub:
+			if t&typesOf(vnum, vbig, vdecimal, vfloat, vobject) == 0 {
+				c.errorf("%v: invalid operand for unary -: %v", n.pos, t)
+			}
+			return anyType
+		case tnot:
+			return typesOf(vbool)
+		}
+		return t
+	case kbinaryexpr:
+		if op := n.value.ttype; op == tis || op == tas {
+			return c.typeTest(n)
+		}
+		l := c.expr(n.list[0])
+		r := c.expr(n.list[1])
+		return c.binary(n, n.value.ttype, l, r)
+	case kindexexpr, kselectorexpr:
+		c.expr(n.list[0])
+		if n.kind == kindexexpr {
+			c.expr(n.list[1])
+		}
+		return anyType
+	case kparenexpr:
+		return c.expr(n.list[0])
+	case kcallexpr:
+		return c.call(n)
+	}
+	return anyType
+}
+
+func (c *checker) call(n *node) tset {
+	fx := n.list[0]
+	positional := 0
+	for _, a := range n.list[1:] {
+		if a.kind == kkvexpr {
+			// The name of a named argument isn't a variable.
+			c.expr(a.list[1])
+			continue
+		}
+		c.expr(a)
+		positional++
+	}
+	if fx.kind == kident && (fx.value.text == "print" || fx.value.text == "println" || fx.value.text == "printf") {
+		if _, ok := c.lookup(fx.value.text); !ok {
+			return typesOf(vnil)
+		}
+	}
+	t := c.expr(fx)
+	if t&typesOf(vfunc, vstruct, vclass) == 0 && t != 0 {
+		c.errorf("%v: cannot call %v", n.pos, t)
+		return anyType
+	}
+	if fx.kind != kident {
+		return anyType
+	}
+	b, ok := c.lookup(fx.value.text)
+	if !ok || b.fn == nil {
+		return anyType
+	}
+	if params := len(b.fn.list) - 1; positional > params {
+		c.errorf("%v: too many arguments in call to %v: %v > %v", n.pos, fx.value.text, positional, params)
+	} else if positional == len(n.list)-1 && positional < params {
+		c.errorf("%v: not enough arguments in call to %v: %v < %v", n.pos, fx.value.text, positional, params)
+	}
+	t = c.function(b.fn)
+	if t == anyType {
+		return t
+	}
+	return t &^ typesOf(vtuple)
+}
+
+// typeTest returns the types of the result of v is T or v as T, which
+// is known to be of type T if T names one.
+func (c *checker) typeTest(n *node) tset {
+	v := c.expr(n.list[0])
+	want := anyType
+	if t, ok := typeNamed(n.list[1]); ok {
+		want = typesOf(t)
+		if n.value.ttype == tas && v&want == 0 && v != 0 {
+			c.errorf("%v: %v is never %v", n.pos, v, n.list[1].value.text)
+		}
+	} else if t := c.expr(n.list[1]); t&typesOf(vinterface, vclass, vstruct) == 0 && t != 0 {
+		c.errorf("%v: cannot test whether a value is %v", n.pos, t)
+	}
+	if n.value.ttype == tis {
+		return typesOf(vbool)
+	}
+	if want == anyType {
+		return anyType
+	}
+	return v & want
+}
+
+// binary returns the types of the result of applying op to operands of
+// types l and r, reporting an error if it would fail for all of them.
+func (c *checker) binary(n *node, op ttype, l, r tset) tset {
+	if l&typesOf(vobject) != 0 {
+		return anyType
+	}
+	var result tset
+	for lt := verr; lt < 64; lt++ {
+		if !l.has(lt) {
+			continue
+		}
+		for rt := verr; rt < 64; rt++ {
+			if r.has(rt) {
+				result |= binaryType(op, lt, rt)
+			}
+		}
+	}
+	if result == 0 && l != 0 && r != 0 {
+		if l&r == 0 {
+			c.errorf("%v: type mismatch in binaryexpr %v %v %v", n.pos, l, n.value.text, r)
+		} else {
+			c.errorf("%v: invalid operation %v %v %v", n.pos, l, n.value.text, r)
+		}
+	}
+	return result
+}
+
+// binaryType returns the type of the result of applying op to operands
+// of types l and r, or 0 if it would fail, following binaryOp.
+func binaryType(op ttype, l, r vtype) tset {
+	switch op {
+	case tcoalesce:
+		if l == vnil {
+			return typesOf(r)
+		}
+		return typesOf(l)
+	case tland, tlor:
+		if l == vbool && r == vbool {
+			return typesOf(vbool)
+		}
+		return 0
+	case teql, tneq:
+		if l == vnil || r == vnil {
+			return typesOf(vbool)
+		}
+	}
+	// Arithmetic on numbers produces a vbig if the result overflows, on a
+	// number and a decimal, a decimal, and on a number and a float, a
+	// float. Decimals and floats can't be mixed.
+	if isNumType(l) && isNumType(r) {
+		if l == vdecimal && r == vfloat || l == vfloat && r == vdecimal {
+			return 0
+		}
+		switch op {
+		case tplus, tsub, tmul, tquo, trem, tpow:
+			if l == vdecimal || r == vdecimal {
+				return typesOf(vdecimal)
+			}
+			if l == vfloat || r == vfloat {
+				return typesOf(vfloat)
+			}
+			return typesOf(vnum, vbig)
+		case tlss, tgtr, tleq, tgeq, teql, tneq:
+			return typesOf(vbool)
+		}
+		return 0
+	}
+	if l != r {
+		return 0
+	}
+	switch op {
+	case tplus:
+		if l == vnum || l == vstring || l == vbytes {
+			return typesOf(l)
+		}
+	case tsub, tmul, tquo, trem, tpow:
+		if l == vnum {
+			return typesOf(vnum)
+		}
+	case tlss, tgtr, tleq, tgeq:
+		if l == vnum {
+			return typesOf(vbool)
+		}
+	case teql, tneq:
+		switch l {
+		case vnum, vbool, vstring, vbitset, vrecord, vobject, vclass, vfunc, varray, vmap, vset, vbytes:
+			return typesOf(vbool)
+		}
+	}
+	return 0
+}
+
+func isNumType(t vtype) bool { return t == vnum || t == vbig || t == vdecimal || t == vfloat }
+
+// checkMain checks each file named in args, printing the errors found,
+// and exits with status 1 if there were any. With -types, it also prints
+// the types inferred for each occurrence of an identifier, one per line
+// as file:line:col: name type, for editors to show.
+func checkMain(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	types := fs.Bool("types", false, "print the types inferred for each identifier")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: refgc check [-types] file...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	failed := false
+	for _, name := range fs.Args() {
+		af, err := parseFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		c := newChecker()
+		c.file(af)
+		if *types {
+			c.writeTypes(os.Stdout)
+		}
+		var errs []string
+		for e := range c.errs {
+			errs = append(errs, e)
+		}
+		sort.Slice(errs, func(i, j int) bool { return lessPos(errs[i], errs[j]) })
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		failed = failed || len(errs) > 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// writeTypes prints the types inferred for each identifier, in the order
+// they occur.
+func (c *checker) writeTypes(w io.Writer) {
+	var pos []scanner.Position
+	for p := range c.idents {
+		pos = append(pos, p)
+	}
+	sort.Slice(pos, func(i, j int) bool {
+		if pos[i].Line != pos[j].Line {
+			return pos[i].Line < pos[j].Line
+		}
+		return pos[i].Column < pos[j].Column
+	})
+	for _, p := range pos {
+		id := c.idents[p]
+		fmt.Fprintf(w, "%v: %v %v\n", p, id.name, c.describe(id.b))
+	}
+}
+
+// lessPos orders error messages beginning with file:line:col positions.
+func lessPos(a, b string) bool {
+	pa, pb := strings.SplitN(a, ":", 4), strings.SplitN(b, ":", 4)
+	if len(pa) < 4 || len(pb) < 4 || pa[0] != pb[0] {
+		return a < b
+	}
+	for i := 1; i < 3; i++ {
+		if pa[i] != pb[i] {
+			if len(pa[i]) != len(pb[i]) {
+				return len(pa[i]) < len(pb[i])
+			}
+			return pa[i] < pb[i]
+		}
+	}
+	return a < b
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkScript checks the program src, and returns the errors found.
+func checkScript(t *testing.T, src string) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.x")
+	if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	af, err := parseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newChecker()
+	c.file(af)
+	var errs []string
+	for e := range c.errs {
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+func TestCheckNegation(t *testing.T) {
+	if errs := checkScript(t, "x = -1.5;\nprintln(-PI);\ny = -x;\nz = -2;\nw = -z;\n"); len(errs) > 0 {
+		t.Errorf("negating numbers and floats: got errors %v", errs)
+	}
+	errs := checkScript(t, "x = -\"s\";\n")
+	if len(errs) != 1 || !strings.Contains(errs[0], "invalid operand for unary -") {
+		t.Errorf("negating a string: got errors %v", errs)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A class is the value of a class declaration. Calling it creates an
+// object and passes it to the init method, if the class or one of its
+// ancestors has one.
+type class struct {
+	name    string
+	parent  *class
+	methods map[string]value
+}
+
+// An object is an instance of a class. Its fields are created by
+// assigning to them, usually in init. A selector on an object names one
+// of its fields, or failing that, a method of its class.
+type object struct {
+	class  *class
+	fields value // an array keyed by field name
+}
+
+func (c *class) String() string { return "class " + c.name }
+
+func (o *object) String() string {
+	var sb strings.Builder
+	sb.WriteString(o.class.name)
+	sb.WriteString("{")
+	for i, e := range o.fields.entries() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%v: %v", e.k.v, e.v.elem())
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// method returns the method name of c, or of its nearest ancestor that
+// has one.
+func (c *class) method(name string) (value, bool) {
+	for ; c != nil; c = c.parent {
+		if m, ok := c.methods[name]; ok {
+			return m, true
+		}
+	}
+	return value{}, false
+}
+
+// evalClass binds the name of a class declaration to a new class. Its
+// methods are evaluated in a scope in which super is bound to the parent
+// class, through which they may call the methods it overrides.
+func (interp *interp) evalClass(nod *node) {
+	c := &class{name: nod.list[0].value.text, methods: make(map[string]value)}
+	scope := newEnv(interp.env)
+	if nod.list[1] != nil {
+		p := interp.evalRvalue(nod.list[1])
+		if interp.err != nil {
+			return
+		}
+		if p.typ != vclass {
+			interp.err = fmt.Errorf("%v: class %v cannot inherit from %v", nod.pos, c.name, p.typ)
+			return
+		}
+		c.parent = p.v.(*class)
+		scope.m["super"] = p
+	}
+	for _, m := range nod.list[2:] {
+		c.methods[m.list[0].value.text] = value{typ: vfunc, v: &closure{m.list[1], scope}}
+	}
+	interp.env.m[c.name] = value{typ: vclass, v: c}
+}
+
+// newObject returns an object of class c, initialized by calling its init
+// method with args.
+func (interp *interp) newObject(c *class, args []value) value {
+	o := value{typ: vobject, v: &object{class: c, fields: newArray()}}
+	init, ok := c.method("init")
+	if !ok {
+		if len(args) > 0 {
+			interp.err = fmt.Errorf("%v has no init method to take arguments", c.name)
+		}
+		return o
+	}
+	interp.call(init, append([]value{o}, args...))
+	return o
+}
+
+// objectMember returns the field name of o, or the method of its class with
+// that name.
+func (interp *interp) objectMember(o *object, name string) value {
+	k := value{typ: vstring, v: name}
+	if o.fields.has(k) {
+		return o.fields.get(k)
+	}
+	if m, ok := o.class.method(name); ok {
+		return m
+	}
+	interp.err = fmt.Errorf("%v has no field or method %v", o.class.name, name)
+	return value{}
+}
+
+// classMember returns the method name of c, which may then be called with
+// an explicit receiver.
+func (interp *interp) classMember(c *class, name string) value {
+	if m, ok := c.method(name); ok {
+		return m
+	}
+	interp.err = fmt.Errorf("%v has no method %v", c.name, name)
+	return value{}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtins["int"] = (*interp).builtinInt
+	builtins["str"] = (*interp).builtinStr
+	builtins["bool"] = (*interp).builtinBool
+	builtins["float"] = (*interp).builtinFloat
+}
+
+// The conversion builtins fail, like other builtins, when given a value
+// of a type they can't convert. A string that can't be parsed is instead
+// a problem with the program's input, so for one they return an error
+// value, which can be tested with iserror.
+
+// builtinInt converts a number, a string of decimal digits with an
+// optional sign, a decimal or float, which is truncated, or a bool to a
+// number.
+func (interp *interp) builtinInt(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("int expects one argument")
+		return value{}
+	}
+	switch x := args[0]; x.typ {
+	case vnum, vbig:
+		return x
+	case vstring:
+		n, err := parseNum(strings.TrimSpace(x.v.(string)))
+		if err != nil {
+			return interp.failure(fmt.Errorf("int: invalid number %q", x.v))
+		}
+		return n
+	case vdecimal:
+		return normBig(x.v.(*decimal).round(0, roundModes["down"]).unscaled)
+	case vfloat:
+		return interp.floatToNum(math.Trunc(x.v.(float64)))
+	case vbool:
+		if x.v.(bool) {
+			return value{typ: vnum, v: 1}
+		}
+		return value{typ: vnum, v: 0}
+	}
+	interp.err = fmt.Errorf("cannot convert %v to a number", args[0].typ)
+	return value{}
+}
+
+// builtinFloat converts a number, decimal, or float, or a string holding
+// one, to the nearest float.
+func (interp *interp) builtinFloat(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("float expects one argument")
+		return value{}
+	}
+	switch x := args[0]; x.typ {
+	case vnum, vbig, vdecimal, vfloat:
+		return value{typ: vfloat, v: toFloat(x)}
+	case vstring:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x.v.(string)), 64)
+		if err != nil {
+			return interp.failure(fmt.Errorf("float: invalid float %q", x.v))
+		}
+		return value{typ: vfloat, v: f}
+	}
+	interp.err = fmt.Errorf("cannot convert %v to a float", args[0].typ)
+	return value{}
+}
+
+// builtinStr returns a value as print would show it.
+func (interp *interp) builtinStr(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("str expects one argument")
+		return value{}
+	}
+	return value{typ: vstring, v: args[0].String()}
+}
+
+// builtinBool converts a bool, a number, which is true unless it is 0, nil,
+// which is false, or one of the strings "true" and "false" to a bool.
+func (interp *interp) builtinBool(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("bool expects one argument")
+		return value{}
+	}
+	switch x := args[0]; x.typ {
+	case vbool:
+		return x
+	case vnum:
+		return value{typ: vbool, v: x.v.(int) != 0}
+	case vnil:
+		return value{typ: vbool, v: false}
+	case vstring:
+		switch strings.TrimSpace(x.v.(string)) {
+		case "true":
+			return value{typ: vbool, v: true}
+		case "false":
+			return value{typ: vbool, v: false}
+		}
+		return interp.failure(fmt.Errorf("bool: invalid bool %q", x.v))
+	}
+	interp.err = fmt.Errorf("cannot convert %v to a bool", args[0].typ)
+	return value{}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "fmt"
+
+func init() {
+	builtins["deepcopy"] = (*interp).builtinDeepCopy
+}
+
+// copyValue returns a copy of v that shares no mutable state with it.
+// Copies are neither frozen nor watched.
+func copyValue(v value) value {
+	return copyShared(v, map[any]value{})
+}
+
+// copyShared is copyValue, where copies holds the copies made so far by
+// the identity of what they copy. Something that v holds more than once
+// is copied once, and a copy of something that holds itself holds the
+// copy, so the copy has the same shape as v, even if it has cycles.
+func copyShared(v value, copies map[any]value) value {
+	var id any
+	switch x := v.v.(type) {
+	case *array, *record, *hashMap, *sortedMap, *object:
+		id = x
+	default:
+		return v
+	}
+	if c, ok := copies[id]; ok {
+		return c
+	}
+	switch x := v.v.(type) {
+	case *array:
+		// The entries are made before they are copied, so that an array
+		// holding itself can hold its copy.
+		c := &array{entries: make([]struct{ k, v value }, len(x.entries))}
+		copies[id] = value{typ: varray, v: c}
+		for i, e := range x.entries {
+			c.entries[i].k, c.entries[i].v = copyShared(e.k, copies), copyShared(e.v, copies)
+		}
+	case *record:
+		c := &record{typ: x.typ, fields: make([]value, len(x.fields))}
+		copies[id] = value{typ: vrecord, v: c}
+		for i, f := range x.fields {
+			c.fields[i] = copyShared(f, copies)
+		}
+	case *hashMap:
+		c := newHashMap()
+		copies[id] = value{typ: v.typ, v: c}
+		for _, e := range x.items() {
+			k := copyShared(e.k, copies)
+			h, _ := hashKey(k)
+			c.index[h] = len(c.entries)
+			c.entries = append(c.entries, struct{ k, v value }{k, copyShared(e.v, copies)})
+		}
+	case *sortedMap:
+		c := &sortedMap{cmp: x.cmp, entries: make([]struct{ k, v value }, len(x.entries))}
+		copies[id] = value{typ: vsortedmap, v: c}
+		for i, e := range x.entries {
+			c.entries[i].k, c.entries[i].v = copyShared(e.k, copies), copyShared(e.v, copies)
+		}
+	case *object:
+		c := &object{class: x.class}
+		copies[id] = value{typ: vobject, v: c}
+		c.fields = copyShared(x.fields, copies)
+	}
+	return copies[id]
+}
+
+// builtinDeepCopy returns a copy of a value that shares nothing mutable
+// with it, so that changing one doesn't change the other. Classes and
+// functions aren't copied.
+func (interp *interp) builtinDeepCopy(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("deepcopy expects one argument")
+		return value{}
+	}
+	return copyValue(args[0])
+}
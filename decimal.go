@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtins["decimal"] = (*interp).builtinDecimal
+	builtins["round"] = (*interp).builtinRound
+}
+
+// A decimal is an exact decimal number, unscaled / 10**scale, for amounts
+// like money that can't be represented exactly by binary fractions. It is
+// never modified. Arithmetic between a decimal and a number produces a
+// decimal. Sums and differences have the larger of their operands'
+// scales, and products the sum of them, so they are exact; quotients are
+// rounded half to even to divScale more places than their operands have.
+type decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+const divScale = 16
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// parseDecimal parses a decimal written as an optional sign, digits, and
+// an optional point followed by more digits.
+func parseDecimal(s string) (*decimal, bool) {
+	whole, frac, _ := strings.Cut(s, ".")
+	digits := strings.TrimLeft(whole, "+-")
+	if len(whole)-len(digits) > 1 || digits == "" && frac == "" || strings.ContainsAny(frac, "+-") {
+		return nil, false
+	}
+	u, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return nil, false
+	}
+	return &decimal{u, len(frac)}, true
+}
+
+// toDecimal returns the number or decimal v as a decimal.
+func toDecimal(v value) *decimal {
+	if v.typ == vdecimal {
+		return v.v.(*decimal)
+	}
+	return &decimal{toBig(v), 0}
+}
+
+// rescale returns the unscaled value of d at scale, which must not be
+// less than d's.
+func (d *decimal) rescale(scale int) *big.Int {
+	if scale == d.scale {
+		return d.unscaled
+	}
+	return new(big.Int).Mul(d.unscaled, pow10(scale-d.scale))
+}
+
+func (d *decimal) String() string {
+	s := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(s) <= d.scale {
+			s = strings.Repeat("0", d.scale-len(s)+1) + s
+		}
+		s = s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// normalized returns d with trailing zeros after the point removed, for
+// comparing decimals that are equal but have different scales.
+func (d *decimal) normalized() *decimal {
+	u, scale := new(big.Int).Set(d.unscaled), d.scale
+	r := new(big.Int)
+	for scale > 0 {
+		q, _ := new(big.Int).QuoRem(u, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		u, scale = q, scale-1
+	}
+	return &decimal{u, scale}
+}
+
+func (d *decimal) cmp(e *decimal) int {
+	scale := max(d.scale, e.scale)
+	return d.rescale(scale).Cmp(e.rescale(scale))
+}
+
+// roundModes are the ways round can round a decimal, which differ in how
+// they treat the digits that are dropped.
+var roundModes = map[string]func(q *big.Int, sign, half int){
+	// toward zero, or away from it
+	"down": func(q *big.Int, sign, half int) {},
+	"up":   func(q *big.Int, sign, half int) { q.Add(q, big.NewInt(int64(sign))) },
+	// toward positive or negative infinity
+	"ceiling": func(q *big.Int, sign, half int) {
+		if sign > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	},
+	"floor": func(q *big.Int, sign, half int) {
+		if sign < 0 {
+			q.Sub(q, big.NewInt(1))
+		}
+	},
+	// to the nearest, with ties away from zero, toward zero, or to even
+	"half-up": func(q *big.Int, sign, half int) {
+		if half >= 0 {
+			q.Add(q, big.NewInt(int64(sign)))
+		}
+	},
+	"half-down": func(q *big.Int, sign, half int) {
+		if half > 0 {
+			q.Add(q, big.NewInt(int64(sign)))
+		}
+	},
+	"half-even": func(q *big.Int, sign, half int) {
+		if half > 0 || half == 0 && q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(sign)))
+		}
+	},
+}
+
+// round returns d rounded to places digits after the point by mode, which
+// is given the truncated result, the sign of d, and how the dropped
+// digits compare with one half. If places is negative, d is rounded to a
+// multiple of 10**-places.
+func (d *decimal) round(places int, mode func(q *big.Int, sign, half int)) *decimal {
+	if places >= d.scale {
+		return &decimal{d.rescale(places), places}
+	}
+	p := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(d.unscaled, p, new(big.Int))
+	if r.Sign() != 0 {
+		twice := new(big.Int).Abs(r)
+		mode(q, d.unscaled.Sign(), twice.Lsh(twice, 1).Cmp(p))
+	}
+	if places < 0 {
+		return &decimal{q.Mul(q, pow10(-places)), 0}
+	}
+	return &decimal{q, places}
+}
+
+// decimalOp applies op to l and r, at least one of which is a decimal and
+// the other a number.
+func (interp *interp) decimalOp(op ttype, l, r value) value {
+	x, y := toDecimal(l), toDecimal(r)
+	scale := max(x.scale, y.scale)
+	var z *decimal
+	switch op {
+	case tplus:
+		z = &decimal{new(big.Int).Add(x.rescale(scale), y.rescale(scale)), scale}
+	case tsub:
+		z = &decimal{new(big.Int).Sub(x.rescale(scale), y.rescale(scale)), scale}
+	case tmul:
+		z = &decimal{new(big.Int).Mul(x.unscaled, y.unscaled), x.scale + y.scale}
+	case tquo, trem:
+		if y.unscaled.Sign() == 0 {
+			interp.err = errDivideByZero
+			return value{}
+		}
+		if op == trem {
+			m := new(big.Int).Rem(x.rescale(scale), y.rescale(scale))
+			if interp.modulo == moduloEuclidean && m.Sign() < 0 {
+				m.Add(m, new(big.Int).Abs(y.rescale(scale)))
+			}
+			z = &decimal{m, scale}
+			break
+		}
+		n := scale + divScale
+		num := new(big.Int).Mul(x.unscaled, pow10(n-x.scale+y.scale))
+		q, rem := new(big.Int).QuoRem(num, y.unscaled, new(big.Int))
+		if rem.Sign() != 0 {
+			twice := new(big.Int).Abs(rem)
+			half := twice.Lsh(twice, 1).Cmp(new(big.Int).Abs(y.unscaled))
+			roundModes["half-even"](q, num.Sign()*y.unscaled.Sign(), half)
+		}
+		z = &decimal{q, n}
+		if nz := z.normalized(); nz.scale < scale {
+			z = &decimal{nz.rescale(scale), scale}
+		} else {
+			z = nz
+		}
+	case tpow:
+		if r.typ != vnum || r.v.(int) < 0 {
+			interp.err = fmt.Errorf("decimal exponent must be a non-negative number")
+			return value{}
+		}
+		n := r.v.(int)
+		z = &decimal{new(big.Int).Exp(x.unscaled, big.NewInt(int64(n)), nil), x.scale * n}
+	case teql, tneq, tlss, tleq, tgtr, tgeq:
+		return value{typ: vbool, v: compared(op, x.cmp(y))}
+	default:
+		interp.err = fmt.Errorf("invalid op %v", op)
+		return value{}
+	}
+	return value{typ: vdecimal, v: z}
+}
+
+// builtinDecimal converts a string, such as "19.99", or a number to a
+// decimal. A string that isn't a decimal results in an error value.
+func (interp *interp) builtinDecimal(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("decimal expects one argument")
+		return value{}
+	}
+	switch x := args[0]; x.typ {
+	case vdecimal:
+		return x
+	case vnum, vbig:
+		return value{typ: vdecimal, v: toDecimal(x)}
+	case vstring:
+		d, ok := parseDecimal(strings.TrimSpace(x.v.(string)))
+		if !ok {
+			return interp.failure(fmt.Errorf("decimal: invalid decimal %q", x.v))
+		}
+		return value{typ: vdecimal, v: d}
+	}
+	interp.err = fmt.Errorf("cannot convert %v to a decimal", args[0].typ)
+	return value{}
+}
+
+// builtinRound rounds a decimal or float to a number of places after the
+// point, which may be negative to round to tens, hundreds, and so on. The
+// optional mode is one of the keys of roundModes, and is half-even by
+// default. Numbers are already rounded to 0 places or more. A float is
+// rounded as the decimal that is its shortest representation, so that
+// round(2.675, 2) is 2.68, and the result is the nearest float.
+func (interp *interp) builtinRound(args []value) value {
+	if len(args) < 2 || len(args) > 3 || args[1].typ != vnum || len(args) == 3 && args[2].typ != vstring {
+		interp.err = fmt.Errorf("round expects a number, a number of places, and an optional mode")
+		return value{}
+	}
+	name := "half-even"
+	if len(args) == 3 {
+		name = args[2].v.(string)
+	}
+	mode, ok := roundModes[name]
+	if !ok {
+		interp.err = fmt.Errorf("round: unknown mode %v", name)
+		return value{}
+	}
+	places := args[1].v.(int)
+	switch x := args[0]; x.typ {
+	case vdecimal:
+		d := x.v.(*decimal).round(places, mode)
+		return value{typ: vdecimal, v: d}
+	case vnum, vbig:
+		if places >= 0 {
+			return x
+		}
+		return normBig(toDecimal(x).round(places, mode).unscaled)
+	case vfloat:
+		f := x.v.(float64)
+		d, ok := parseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+		if !ok {
+			// Infinities and NaN are already rounded.
+			return x
+		}
+		return value{typ: vfloat, v: toFloat(value{typ: vdecimal, v: d.round(places, mode)})}
+	}
+	interp.err = fmt.Errorf("cannot round %v", args[0].typ)
+	return value{}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	nativeModule("clipboard", map[string]builtin{
+		"read":  (*interp).clipboardRead,
+		"write": (*interp).clipboardWrite,
+	})
+	builtins["notify"] = (*interp).builtinNotify
+}
+
+// findCommand returns the first of cmds whose program is installed, or nil.
+func findCommand(cmds ...[]string) []string {
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// clipboardCommand returns the command that copies standard input to the
+// clipboard, if write is true, or that pastes the clipboard to standard
+// output.
+func clipboardCommand(write bool) []string {
+	switch runtime.GOOS {
+	case "darwin":
+		if write {
+			return []string{"pbcopy"}
+		}
+		return []string{"pbpaste"}
+	case "windows":
+		if write {
+			return []string{"clip"}
+		}
+		return findCommand([]string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"})
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if write {
+			return findCommand([]string{"wl-copy"})
+		}
+		return findCommand([]string{"wl-paste", "--no-newline"})
+	}
+	if write {
+		return findCommand([]string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	return findCommand([]string{"xclip", "-selection", "clipboard", "-o"}, []string{"xsel", "--clipboard", "--output"})
+}
+
+func (interp *interp) clipboardRead(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("clipboard.read expects no arguments")
+		return value{}
+	}
+	if !interp.allowed("clipboard.read") {
+		return value{}
+	}
+	c := clipboardCommand(false)
+	if c == nil {
+		interp.err = fmt.Errorf("clipboard.read: no clipboard is available on %v", runtime.GOOS)
+		return value{}
+	}
+	out, err := exec.Command(c[0], c[1:]...).Output()
+	if err != nil {
+		interp.err = fmt.Errorf("clipboard.read: %v", err)
+		return value{}
+	}
+	return value{typ: vstring, v: string(out)}
+}
+
+func (interp *interp) clipboardWrite(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("clipboard.write expects a string")
+		return value{}
+	}
+	if !interp.allowed("clipboard.write") {
+		return value{}
+	}
+	c := clipboardCommand(true)
+	if c == nil {
+		interp.err = fmt.Errorf("clipboard.write: no clipboard is available on %v", runtime.GOOS)
+		return value{}
+	}
+	cmd := exec.Command(c[0], c[1:]...)
+	cmd.Stdin = strings.NewReader(args[0].v.(string))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		interp.err = fmt.Errorf("clipboard.write: %v %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return value{}
+}
+
+// notifyCommand returns the command that shows a desktop notification, or
+// nil if there isn't one.
+func notifyCommand(title, msg string) []string {
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + strconv.Quote(msg) + " with title " + strconv.Quote(title)
+		return []string{"osascript", "-e", script}
+	case "windows":
+		return nil
+	}
+	if c := findCommand([]string{"notify-send"}); c != nil {
+		return append(c, "--", title, msg)
+	}
+	return nil
+}
+
+// builtinNotify shows a desktop notification with a title and message.
+func (interp *interp) builtinNotify(args []value) value {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vstring {
+		interp.err = fmt.Errorf("notify expects a title and a message")
+		return value{}
+	}
+	if !interp.allowed("notify") {
+		return value{}
+	}
+	c := notifyCommand(args[0].v.(string), args[1].v.(string))
+	if c == nil {
+		interp.err = fmt.Errorf("notify: no notification command is available on %v", runtime.GOOS)
+		return value{}
+	}
+	if out, err := exec.Command(c[0], c[1:]...).CombinedOutput(); err != nil {
+		interp.err = fmt.Errorf("notify: %v %s", err, bytes.TrimSpace(out))
+	}
+	return value{}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	builtins["diff"] = (*interp).builtinDiff
+}
+
+// An edit is one line of a diff: ' ' for a line in both texts, '-' for a
+// line only in the first, and '+' for a line only in the second.
+type edit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the edits that turn a into b, using a longest common
+// subsequence of lines.
+func diffLines(a, b []string) []edit {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	return edits
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// unifiedDiff returns the differences between a and b in unified format,
+// with context lines around each change, or "" if they are equal.
+func unifiedDiff(a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+	var sb strings.Builder
+	sb.WriteString("--- a\n+++ b\n")
+	// na and nb count the lines of a and b before edits[start].
+	na, nb := 0, 0
+	for start := 0; start < len(edits); {
+		// Find the next change, and the end of the hunk containing it,
+		// which is context lines past the last change that isn't followed
+		// by 2*context unchanged lines.
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		end, same := first, 0
+		for ; end < len(edits) && same <= 2*context; end++ {
+			if edits[end].op == ' ' {
+				same++
+			} else {
+				same = 0
+			}
+		}
+		end -= max(same-context, 0)
+		begin := max(first-context, start)
+		na += begin - start
+		nb += begin - start
+		var ca, cb int
+		for _, e := range edits[begin:end] {
+			if e.op != '+' {
+				ca++
+			}
+			if e.op != '-' {
+				cb++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%v +%v @@\n", hunkRange(na, ca), hunkRange(nb, cb))
+		for _, e := range edits[begin:end] {
+			sb.WriteByte(e.op)
+			sb.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		na += ca
+		nb += cb
+		start = end
+	}
+	return sb.String()
+}
+
+// hunkRange formats the range of n lines following the first skip lines
+// of a file, as in a unified diff hunk header.
+func hunkRange(skip, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%v,0", skip)
+	case 1:
+		return fmt.Sprint(skip + 1)
+	}
+	return fmt.Sprintf("%v,%v", skip+1, n)
+}
+
+// structDiff compares the entries of two arrays, and returns an array with
+// the keys "added" and "removed", holding the entries only in b or only
+// in a, and "changed", mapping each key whose value differs to an array
+// with the keys "from" and "to".
+func structDiff(a, b value) value {
+	added, removed, changed := newArray(), newArray(), newArray()
+	for _, e := range a.entries() {
+		v := b.get(e.k)
+		switch {
+		case !b.has(e.k):
+			removed.set(e.k, e.v)
+		case !v.eq(e.v):
+			c := newArray()
+			c.set(value{typ: vstring, v: "from"}, e.v)
+			c.set(value{typ: vstring, v: "to"}, v)
+			changed.set(e.k, c)
+		}
+	}
+	for _, e := range b.entries() {
+		if !a.has(e.k) {
+			added.set(e.k, e.v)
+		}
+	}
+	d := newArray()
+	d.set(value{typ: vstring, v: "added"}, added)
+	d.set(value{typ: vstring, v: "removed"}, removed)
+	d.set(value{typ: vstring, v: "changed"}, changed)
+	return d
+}
+
+// builtinDiff returns a unified diff of two strings, or a structural diff
+// of two arrays.
+func (interp *interp) builtinDiff(args []value) value {
+	if len(args) != 2 || args[0].typ != args[1].typ || args[0].typ != vstring && args[0].typ != varray {
+		interp.err = fmt.Errorf("diff expects two strings or two arrays")
+		return value{}
+	}
+	if args[0].typ == vstring {
+		return value{typ: vstring, v: unifiedDiff(args[0].v.(string), args[1].v.(string), 3)}
+	}
+	return structDiff(args[0], args[1])
+}
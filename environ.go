@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func init() {
+	builtins["getenv"] = (*interp).builtinGetenv
+	builtins["setenv"] = (*interp).builtinSetenv
+}
+
+// builtinGetenv returns the value of an environment variable, or nil if
+// it isn't set.
+func (interp *interp) builtinGetenv(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("getenv expects the name of a variable")
+		return value{}
+	}
+	if !interp.allowed("getenv") {
+		return value{}
+	}
+	v, ok := os.LookupEnv(args[0].v.(string))
+	if !ok {
+		return value{typ: vnil}
+	}
+	return value{typ: vstring, v: v}
+}
+
+// builtinSetenv sets an environment variable, for this process and the
+// commands it runs, or unsets it if the value is nil.
+func (interp *interp) builtinSetenv(args []value) value {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vstring && args[1].typ != vnil {
+		interp.err = fmt.Errorf("setenv expects the name of a variable and a string or nil")
+		return value{}
+	}
+	if !interp.allowed("setenv") {
+		return value{}
+	}
+	name := args[0].v.(string)
+	var err error
+	if args[1].typ == vnil {
+		err = os.Unsetenv(name)
+	} else {
+		err = os.Setenv(name, args[1].v.(string))
+	}
+	if err != nil {
+		interp.err = fmt.Errorf("setenv: %v", err)
+	}
+	return value{typ: vnil}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"text/scanner"
+	"time"
 )
 
 type env struct {
@@ -32,7 +39,62 @@ func newEnv(parent *env) *env {
 type interp struct {
 	env *env
 	err error
+	pos scanner.Position // of the statement being evaluated
 	ret value
+
+	// returning is set by a return statement, so that the statements
+	// around it are skipped until the function returns.
+	returning bool
+
+	calls    []frame // calls being evaluated, innermost last
+	maxDepth int     // limit on len(calls), or 0 for no limit
+
+	// path is the list of directories searched for imports that are
+	// neither absolute nor relative to the importing file.
+	path    []string
+	main    string   // file name of the program
+	args    []string // arguments following the file name
+	sandbox bool     // disallow access to the system
+	strict  bool     // disallow assignments to undeclared variables
+
+	httpTimeout time.Duration // limit on HTTP requests, or 0 for no limit
+
+	overflow overflowMode // what arithmetic that overflows an int does
+	modulo   moduloMode   // how / and % round with negative operands
+	modules  map[string]*module
+	mod      *module // module being loaded, if any
+
+	protos   *protoRegistry // loaded with grpc.load
+	catalogs *catalogs      // loaded with i18n.load
+	report   *report        // if non-nil, counters for -report
+
+	jobs    []*job // scheduled with schedule
+	nextJob int
+
+	rng    *rand.Rand // seeded with seed, or randomly when first used
+	seeded bool       // whether seed was called, so IDs come from rng
+
+	stdin  io.Reader // if nil, os.Stdin
+	stdout io.Writer // if nil, os.Stdout
+	inbuf  *bufio.Reader
+}
+
+func (interp *interp) in() *bufio.Reader {
+	if interp.inbuf == nil {
+		r := interp.stdin
+		if r == nil {
+			r = os.Stdin
+		}
+		interp.inbuf = bufio.NewReader(r)
+	}
+	return interp.inbuf
+}
+
+func (interp *interp) out() io.Writer {
+	if interp.stdout == nil {
+		return os.Stdout
+	}
+	return interp.stdout
 }
 
 func (interp *interp) beginScope() {
@@ -58,12 +120,30 @@ func (interp *interp) evalBlock(node *node) {
 	}
 	interp.beginScope()
 	defer interp.endScope()
-	for _, stmt := range node.list {
+	interp.evalStmts(node.list)
+}
+
+// evalStmts evaluates a list of statements in the current scope, after
+// binding the functions they declare, so that functions may be called
+// before their declarations.
+func (interp *interp) evalStmts(list []*node) {
+	for _, stmt := range list {
+		if stmt.kind == kexportstmt {
+			stmt = stmt.list[0]
+		}
+		if stmt.kind == kfuncdecl {
+			interp.env.m[stmt.list[0].value.text] = value{typ: vfunc, v: &closure{stmt.list[1], interp.env}}
+		}
+	}
+	for _, stmt := range list {
+		if interp.returning {
+			return
+		}
 		interp.evalStmt(stmt)
 	}
 }
 
-func (interp *interp) evalFuncBody(params, args []*node, body *node) value {
+func (interp *interp) evalFuncBody(params []*node, args []value, body *node) value {
 	interp.beginScope()
 	defer interp.endScope()
 	defer func() { interp.ret = value{} }()
@@ -72,45 +152,145 @@ func (interp *interp) evalFuncBody(params, args []*node, body *node) value {
 		return value{}
 	}
 	for i := range params {
-		interp.env.m[params[i].value.text] = interp.evalRvalue(args[i])
-	}
-	for _, stmt := range body.list {
-		interp.evalStmt(stmt)
+		interp.env.m[params[i].value.text] = args[i]
 	}
+	interp.evalStmts(body.list)
+	interp.returning = false
 	return interp.ret
 }
 
+// call calls the function fv with args. A function that returns nothing
+// returns nil.
+func (interp *interp) call(fv value, args []value) value {
+	if interp.err != nil {
+		return value{}
+	}
+	var v value
+	switch f := fv.v.(type) {
+	case *builtinFunc:
+		v = f.fn(interp, args)
+	case *structType:
+		v = interp.newRecord(f, args)
+	case *class:
+		v = interp.newObject(f, args)
+	case *closure:
+		// The body is evaluated in a scope enclosed by the one in which
+		// the function was defined, rather than the caller's.
+		saved := interp.env
+		interp.env = f.env
+		v = interp.evalFuncBody(f.params(), args, f.body())
+		interp.env = saved
+	default:
+		interp.err = fmt.Errorf("cannot call %v", fv.typ)
+		return value{}
+	}
+	if v.typ == verr && interp.err == nil {
+		return value{typ: vnil}
+	}
+	return v
+}
+
+// A closure is a function literal and the scope in which it was
+// evaluated.
+type closure struct {
+	fn  *node
+	env *env
+}
+
+func (c *closure) params() []*node { return c.fn.list[:len(c.fn.list)-1] }
+func (c *closure) body() *node     { return c.fn.list[len(c.fn.list)-1] }
+
 //go:generate stringer -type=vtype
 type vtype int
 
 const (
 	verr vtype = iota
+	vnil
 	vnum
 	vstring
 	vbool
 	varray
 	vfunc
+	vmodule
+	vhandle    // a Go object, such as a running task
+	vtuple     // the []value returned by a function with multiple results
+	vbitset    // a *bitset
+	vsortedmap // a *sortedMap
+	vstruct    // a *structType
+	vrecord    // a *record
+	vclass     // a *class
+	vobject    // an *object
+	vinterface // an *iface
+	verror     // an *errorValue
+	vmap       // a *hashMap
+	vset       // a *hashMap of elements to true
+	vbytes     // a []byte, which is never modified
+	vbig       // a *big.Int too large for an int, which is never modified
+	vdecimal   // a *decimal
+	vfloat     // a float64
 )
 
 type value struct {
 	typ vtype
 	v   interface{}
-	m   []struct {
+}
+
+// An array is the value of an array literal. It holds its entries in the
+// order their keys were first set, and setting an existing key changes
+// its value in place. Like maps, arrays are references: assigning one to
+// another variable or passing it to a function doesn't copy it.
+type array struct {
+	entries []struct {
 		k value
 		v value
 	}
+	watchers *watchers
+	frozen   bool
+}
+
+// newArray returns an empty array.
+func newArray() value {
+	return value{typ: varray, v: new(array)}
+}
+
+// arrayOf returns an array holding entries, which it doesn't copy.
+func arrayOf(entries []struct{ k, v value }) value {
+	return value{typ: varray, v: &array{entries: entries}}
+}
+
+// entries returns the entries of an array, or nil for any other value.
+func (v value) entries() []struct{ k, v value } {
+	if a, ok := v.v.(*array); ok {
+		return a.entries
+	}
+	return nil
 }
 
 func (v value) String() string {
 	switch v.typ {
-	case vnum, vstring, vbool:
+	case vnil:
+		return "nil"
+	case vnum, vstring, vbool, vbig, vdecimal:
+		return fmt.Sprint(v.v)
+	case vfloat:
+		return formatFloat(v.v.(float64))
+	case vbitset:
+		return v.v.(*bitset).String()
+	case vset:
+		return setString(v.v.(*hashMap))
+	case vbytes:
+		return bytesString(v.v.([]byte))
+	case vsortedmap:
+		return "sortedmap" + arrayOf(v.v.(*sortedMap).entries).String()
+	case vstruct, vrecord, vclass, vobject, vinterface, verror, vmap:
 		return fmt.Sprint(v.v)
 	case varray:
 		var sb strings.Builder
 		sb.WriteString("[")
-		for i, e := range v.m {
-			sb.WriteString(fmt.Sprintf("%s:%s", e.k, e.v))
-			if i != len(v.m)-1 {
+		es := v.entries()
+		for i, e := range es {
+			sb.WriteString(fmt.Sprintf("%s:%s", e.k.elem(), e.v.elem()))
+			if i != len(es)-1 {
 				sb.WriteString(",")
 			}
 		}
@@ -121,35 +301,276 @@ func (v value) String() string {
 	}
 }
 
+// elem formats v as an element of an array, quoting strings.
+func (v value) elem() string {
+	if v.typ == vstring {
+		return strconv.Quote(v.v.(string))
+	}
+	return v.String()
+}
+
+// eq reports whether v1 and v2 are equal, as by ==. Functions, classes,
+// and objects are equal only to themselves. Numbers of different types
+// are equal if they have the same value. Arrays and sorted maps are equal
+// if they have the same keys in the same order with equal values, and
+// maps and sets if they have the same keys with equal values, so they are
+// compared deeply, with the same rules for the values they hold.
 func (v1 value) eq(v2 value) bool {
+	return v1.equal(v2, nil)
+}
+
+// sameFunc reports whether the functions f1 and f2 are the same function.
+func sameFunc(f1, f2 value) bool {
+	b1, ok1 := f1.v.(*builtinFunc)
+	b2, ok2 := f2.v.(*builtinFunc)
+	if ok1 || ok2 {
+		return ok1 && ok2 && b1.name == b2.name
+	}
+	return f1.v == f2.v
+}
+
+// numEqual reports whether the numbers n1 and n2 are equal, converting
+// them as == does: to floats if either is a float, and otherwise to
+// decimals if either is a decimal. A decimal and a float, which == can't
+// compare, are unequal.
+func numEqual(n1, n2 value) bool {
+	switch {
+	case n1.typ == vnum && n2.typ == vnum:
+		return n1.v.(int) == n2.v.(int)
+	case n1.typ == vfloat && n2.typ == vdecimal, n1.typ == vdecimal && n2.typ == vfloat:
+		return false
+	case n1.typ == vfloat || n2.typ == vfloat:
+		return toFloat(n1) == toFloat(n2)
+	case n1.typ == vdecimal || n2.typ == vdecimal:
+		return toDecimal(n1).cmp(toDecimal(n2)) == 0
+	}
+	return toBig(n1).Cmp(toBig(n2)) == 0
+}
+
+// A visit is a pair of arrays or maps whose comparison is in progress.
+type visit struct{ a, b any }
+
+// equal is eq, where seen holds the pairs of arrays and maps being
+// compared by its callers. A pair that is compared again can only be
+// reached through a cycle, and is taken to be equal, so comparing
+// self-referential values terminates, with any difference found
+// elsewhere.
+func (v1 value) equal(v2 value, seen map[visit]bool) bool {
 	if v1.typ == vfunc && v2.typ == vfunc {
+		return sameFunc(v1, v2)
+	}
+	if v1.typ == v2.typ && (v1.typ == vclass || v1.typ == vobject) {
 		return v1.v == v2.v
 	}
+	if v1.typ == vrecord && v2.typ == vrecord {
+		return v1.v.(*record).eq(v2.v.(*record), seen)
+	}
+	if v1.typ == v2.typ && (v1.typ == varray || v1.typ == vsortedmap) {
+		es1, es2 := v1.entries(), v2.entries()
+		if v1.typ == vsortedmap {
+			es1, es2 = v1.v.(*sortedMap).entries, v2.v.(*sortedMap).entries
+		}
+		if len(es1) != len(es2) {
+			return false
+		}
+		p := visit{v1.v, v2.v}
+		if seen[p] {
+			return true
+		}
+		if seen == nil {
+			seen = map[visit]bool{}
+		}
+		seen[p] = true
+		for i := range es1 {
+			if !sameKey(es1[i].k, es2[i].k) || !es1[i].v.equal(es2[i].v, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	if isNumeric(v1) && isNumeric(v2) {
+		return numEqual(v1, v2)
+	}
+	if v1.typ == vbytes && v2.typ == vbytes {
+		return bytesEqual(v1, v2)
+	}
+	if v1.typ == v2.typ && (v1.typ == vmap || v1.typ == vset) {
+		p := visit{v1.v, v2.v}
+		if seen[p] {
+			return true
+		}
+		if seen == nil {
+			seen = map[visit]bool{}
+		}
+		seen[p] = true
+		return v1.v.(*hashMap).eq(v2.v.(*hashMap), seen)
+	}
 	return reflect.DeepEqual(v1, v2)
 }
 
+// sameKey reports whether k1 and k2 are the same key of an array. As in
+// maps, numbers of different types are different keys, even if they are
+// equal.
+func sameKey(k1, k2 value) bool {
+	return k1.typ == k2.typ && k1.eq(k2)
+}
+
 func (val *value) get(k value) value {
-	for _, e := range val.m {
-		if k.eq(e.k) {
+	for _, e := range val.entries() {
+		if sameKey(k, e.k) {
 			return e.v
 		}
 	}
-	return value{}
+	return value{typ: vnil}
 }
 
+func (val *value) has(k value) bool {
+	for _, e := range val.entries() {
+		if sameKey(k, e.k) {
+			return true
+		}
+	}
+	return false
+}
+
+// set sets the entry of the array val with key k to v.
 func (val *value) set(k, v value) {
-	for i := range val.m {
-		if k.eq(val.m[i].k) {
-			val.m[i].v = v
+	a := val.v.(*array)
+	for i := range a.entries {
+		if sameKey(k, a.entries[i].k) {
+			a.entries[i].v = v
 			return
 		}
 	}
-	val.m = append(val.m, struct {
+	a.entries = append(a.entries, struct {
 		k value
 		v value
 	}{k, v})
 }
 
+// index returns the element of m with key k.
+func (interp *interp) index(m, k value) value {
+	switch m.typ {
+	case vsortedmap:
+		return interp.sortedGet(m.v.(*sortedMap), k)
+	case vmap:
+		return interp.mapGet(m.v.(*hashMap), k)
+	case vbytes:
+		return interp.bytesIndex(m.v.([]byte), k)
+	case vstring:
+		return interp.stringIndex(m.v.(string), k)
+	case vrecord:
+		interp.err = fmt.Errorf("cannot index %v; use a selector to access its fields", m.v.(*record).typ)
+		return value{}
+	case vobject:
+		if f, ok := operatorMethod(m, "__index"); ok {
+			return interp.call(f, []value{m, k})
+		}
+		interp.err = fmt.Errorf("cannot index %v object; use a selector to access its fields or define __index", m.v.(*object).class.name)
+		return value{}
+	}
+	return m.get(k)
+}
+
+// setIndex sets the element of m with key k to v, and notifies m's
+// watchers if that changed it.
+func (interp *interp) setIndex(m *value, k, v value) {
+	switch m.typ {
+	case vrecord:
+		interp.err = fmt.Errorf("cannot index %v; use a selector to access its fields", m.v.(*record).typ)
+		return
+	case vobject:
+		if f, ok := operatorMethod(*m, "__setindex"); ok {
+			interp.call(f, []value{*m, k, v})
+			return
+		}
+		interp.err = fmt.Errorf("cannot index %v object; use a selector to access its fields or define __setindex", m.v.(*object).class.name)
+		return
+	case vmap:
+		interp.mapSet(m.v.(*hashMap), k, v)
+		return
+	case vbytes:
+		interp.err = fmt.Errorf("cannot assign to an element of bytes, which are immutable")
+		return
+	case vstring:
+		interp.err = fmt.Errorf("cannot assign to a character of a string, which is immutable")
+		return
+	case varray:
+		if isFrozen(*m) {
+			interp.err = fmt.Errorf("cannot modify frozen array")
+			return
+		}
+	case vsortedmap:
+	default:
+		interp.err = fmt.Errorf("cannot index %v", typeName(m.typ))
+		return
+	}
+	w := watchersOf(*m)
+	var old value
+	if w != nil {
+		old = interp.index(*m, k)
+	}
+	if m.typ == vsortedmap {
+		interp.sortedSet(m.v.(*sortedMap), k, v)
+	} else {
+		m.set(k, v)
+	}
+	if w != nil && !old.eq(v) {
+		w.notify(interp, k, old, v)
+	}
+}
+
+// selector returns the member of m named by a selector, which is an
+// exported binding of a module, a field of a record, or otherwise the
+// element whose key is the name.
+func (interp *interp) selector(m value, name string) value {
+	switch m.typ {
+	case vmodule:
+		return interp.member(m.v.(*module), name)
+	case vrecord:
+		return interp.field(m.v.(*record), name)
+	case vobject:
+		return interp.objectMember(m.v.(*object), name)
+	case vclass:
+		return interp.classMember(m.v.(*class), name)
+	case verror:
+		return interp.errorField(m.v.(*errorValue), name)
+	}
+	return interp.index(m, value{typ: vstring, v: name})
+}
+
+// setMember sets the member of m named by a selector to v, if m is a
+// value whose members aren't its elements, and reports whether it was.
+func (interp *interp) setMember(m value, name string, v value) bool {
+	switch m.typ {
+	case vrecord:
+		interp.setField(m.v.(*record), name, v)
+	case vobject:
+		m.v.(*object).fields.set(value{typ: vstring, v: name}, v)
+	case vclass:
+		interp.err = fmt.Errorf("cannot assign to class member %v", name)
+	default:
+		return false
+	}
+	return true
+}
+
+// selectorKey returns the key of the element named by the selector
+// expression x.
+func selectorKey(x *node) value {
+	return value{typ: vstring, v: x.list[1].value.text}
+}
+
+// isMethod reports whether f is a method, which is a function whose first
+// parameter is named self. When a method is called through a selector, its
+// receiver is passed as self.
+func isMethod(f value) bool {
+	c, ok := f.v.(*closure)
+	return ok && len(c.params()) > 0 && c.params()[0].value.text == "self"
+}
+
+// isTrue reports whether v is true. Every other value, including nil, is
+// false.
 func (interp *interp) isTrue(v value) bool {
 	if interp.err != nil {
 		return false
@@ -162,9 +583,52 @@ func (interp *interp) setValue(node *node, v value) {
 		return
 	}
 	switch node.kind {
-	case karraylit, knumlit, kstringlit, kparenexpr, kfunclit, kunaryexpr, kbinaryexpr, kcallexpr:
+	case knumlit, kstringlit, kparenexpr, kfunclit, kunaryexpr, kbinaryexpr, kcallexpr:
 		interp.err = fmt.Errorf("cannot assign to %v", node.kind)
+	case karraylit:
+		// Each element is assigned the value with its key in v, or the
+		// value in the same position if v holds multiple results.
+		if v.typ == vtuple {
+			vs := v.v.([]value)
+			if len(vs) != len(node.list) {
+				interp.err = fmt.Errorf("assignment mismatch: %v variables but %v values", len(node.list), len(vs))
+				return
+			}
+			for i, e := range node.list {
+				if e.kind == kkvexpr {
+					interp.err = fmt.Errorf("cannot destructure multiple values by key")
+					return
+				}
+				interp.setValue(e, vs[i])
+			}
+			return
+		}
+		if v.typ != varray && v.typ != vmap {
+			interp.err = fmt.Errorf("cannot destructure %v", v.typ)
+			return
+		}
+		for i, e := range node.list {
+			k, x := value{typ: vnum, v: i}, e
+			if e.kind == kkvexpr {
+				k, x = interp.evalRvalue(e.list[0]), e.list[1]
+			}
+			interp.setValue(x, interp.index(v, k))
+		}
 	case kident:
+		if interp.strict && interp.env.lookup(node.value.text) == nil {
+			interp.err = fmt.Errorf("%v: assignment to undeclared variable %v", node.pos, node.value.text)
+			return
+		}
+		switch t := v.v.(type) {
+		case *structType:
+			if t.name == "" {
+				t.name = node.value.text
+			}
+		case *iface:
+			if t.name == "" {
+				t.name = node.value.text
+			}
+		}
 		if e := interp.env.lookup(node.value.text); e != nil {
 			e.m[node.value.text] = v
 			return
@@ -173,11 +637,17 @@ func (interp *interp) setValue(node *node, v value) {
 	case kindexexpr:
 		m := interp.evalRvalue(node.list[0])
 		i := interp.evalRvalue(node.list[1])
-		m.set(i, v)
+		interp.setIndex(&m, i, v)
 	case kselectorexpr:
 		m := interp.evalRvalue(node.list[0])
-		k := interp.evalRvalue(node.list[1])
-		m.set(k, v)
+		if m.typ == vmodule {
+			interp.err = fmt.Errorf("cannot assign to module member %v", node.list[1].value.text)
+			return
+		}
+		if interp.setMember(m, node.list[1].value.text, v) {
+			return
+		}
+		interp.setIndex(&m, selectorKey(node), v)
 	}
 }
 
@@ -187,176 +657,429 @@ func (interp *interp) evalRvalue(nod *node) value {
 	}
 	switch nod.kind {
 	case karraylit:
-		v := value{typ: varray}
+		v := newArray()
 		for i, e := range nod.list {
 			switch {
 			case e.kind == knumlit:
-				vv, err := strconv.Atoi(e.value.text)
-				if err != nil {
-					interp.err = err
+				vv := interp.numLit(e.value.text)
+				if interp.err != nil {
+					interp.arithErrorAt(e)
 					continue
 				}
-				v.set(value{typ: vnum, v: i}, value{typ: vnum, v: vv})
+				v.set(value{typ: vnum, v: i}, vv)
 			case e.kind == kstringlit:
-				v.set(value{typ: vnum, v: i}, value{typ: vstring, v: e.value.text})
-			case len(e.list) == 1:
-				v.set(value{typ: vnum, v: i}, interp.evalRvalue(e.list[0]))
-			default:
+				v.set(value{typ: vnum, v: i}, interp.evalRvalue(e))
+			case e.kind == kkvexpr:
 				v.set(interp.evalRvalue(e.list[0]), interp.evalRvalue(e.list[1]))
+			default:
+				v.set(value{typ: vnum, v: i}, interp.evalRvalue(e))
 			}
 		}
 		return v
 	case knumlit:
-		var v interface{}
-		v, interp.err = strconv.Atoi(nod.value.text)
-		return value{typ: vnum, v: v}
+		v := interp.numLit(nod.value.text)
+		interp.arithErrorAt(nod)
+		return v
 	case kstringlit:
-		return value{typ: vstring, v: nod.value.text}
+		var s string
+		s, interp.err = strconv.Unquote(nod.value.text)
+		return value{typ: vstring, v: s}
+	case kstructlit:
+		return interp.evalStruct(nod)
+	case kinterfacelit:
+		return interp.evalInterface(nod)
+	case kmaplit:
+		return interp.evalMapLit(nod)
+	case ksetlit:
+		return interp.evalSetLit(nod)
 	case kfunclit:
-		return value{typ: vfunc, v: nod}
+		return value{typ: vfunc, v: &closure{nod, interp.env}}
 	case kident:
 		switch nod.value.text {
 		case "true":
 			return value{typ: vbool, v: true}
 		case "false":
 			return value{typ: vbool, v: false}
+		case "nil":
+			return value{typ: vnil}
 		}
 		if e := interp.env.lookup(nod.value.text); e != nil {
 			return e.m[nod.value.text]
 		}
+		if b, ok := builtins[nod.value.text]; ok {
+			return value{typ: vfunc, v: &builtinFunc{nod.value.text, b}}
+		}
+		if c, ok := constants[nod.value.text]; ok {
+			return c
+		}
+		if m, ok := natives[nod.value.text]; ok {
+			return value{typ: vmodule, v: m}
+		}
 		interp.err = fmt.Errorf("no identifier named %v exists", nod.value.text)
 		return value{}
 	case kunaryexpr:
-		val := interp.evalRvalue(nod.list[0])
-		switch nod.value.ttype {
-		case tplus:
-		case tsub:
-			val.v = -val.v.(int)
-			return val
-		case tnot:
-			val.v = !val.v.(bool)
+		if x := nod.list[0]; x.kind == knumlit && nod.value.ttype == tsub {
+			// The literal for the most negative int is only in range
+			// once negated, so it mustn't overflow on its own.
+			if v, err := parseNum("-" + x.value.text); err == nil && v.typ == vnum {
+				return v
+			}
 		}
-		return val
-	case kbinaryexpr:
-		l, r := interp.evalRvalue(nod.list[0]), interp.evalRvalue(nod.list[1])
-		if l.typ != r.typ {
-			interp.err = fmt.Errorf("type mismatch in binaryexpr %v != %v", l.typ, r.typ)
+		val := interp.evalRvalue(nod.list[0])
+		if interp.err != nil {
 			return value{}
 		}
 		switch nod.value.ttype {
 		case tplus:
-			if l.typ == vstring {
-				return value{typ: vstring, v: l.v.(string) + r.v.(string)}
-			}
-			if l.typ == vnum {
-				return value{typ: vnum, v: l.v.(int) + r.v.(int)}
-			}
 		case tsub:
-			if l.typ == vnum {
-				return value{typ: vnum, v: l.v.(int) - r.v.(int)}
-			}
-		case tmul:
-			if l.typ == vnum {
-				return value{typ: vnum, v: l.v.(int) * r.v.(int)}
-			}
-		case tquo:
-			if l.typ == vnum {
-				return func() value {
-					defer func() {
-						if err := recover(); err != nil {
-							interp.err = err.(error)
-						}
-					}()
-					return value{typ: vnum, v: l.v.(int) / r.v.(int)}
-				}()
+			if m, ok := operatorMethod(val, "__neg"); ok {
+				return interp.call(m, []value{val})
 			}
-		case trem:
-			if l.typ == vnum {
-				return func() value {
-					defer func() {
-						if err := recover(); err != nil {
-							interp.err = err.(error)
-						}
-					}()
-					return value{typ: vnum, v: l.v.(int) % r.v.(int)}
-				}()
+			if isInteger(val) || val.typ == vdecimal || val.typ == vfloat {
+				v := interp.negate(val)
+				interp.arithErrorAt(nod)
+				return v
 			}
-		case tland:
-			if l.typ == vbool {
-				return value{typ: vbool, v: l.v.(bool) && r.v.(bool)}
-			}
-		case tlor:
-			if l.typ == vbool {
-				return value{typ: vbool, v: l.v.(bool) || r.v.(bool)}
-			}
-		case teql:
-			if l.typ == vnum {
-				return value{typ: vbool, v: l.v.(int) == r.v.(int)}
-			}
-			if l.typ == vbool {
-				return value{typ: vbool, v: l.v.(bool) == r.v.(bool)}
-			}
-			if l.typ == vstring {
-				return value{typ: vbool, v: l.v.(string) == r.v.(string)}
-			}
-			// TODO: array?
-		case tlss:
-			if l.typ == vnum {
-				return value{typ: vbool, v: l.v.(int) < r.v.(int)}
-			}
-		case tgtr:
-			if l.typ == vnum {
-				return value{typ: vbool, v: l.v.(int) > r.v.(int)}
-			}
-		case tneq:
-			if l.typ == vnum {
-				return value{typ: vbool, v: l.v.(int) != r.v.(int)}
+			interp.err = fmt.Errorf("cannot negate a value of type %v", typeName(val.typ))
+			return value{}
+		case tnot:
+			switch val.typ {
+			case vnil:
+				return value{typ: vbool, v: true}
+			case vbool:
+				val.v = !val.v.(bool)
+			default:
+				interp.err = fmt.Errorf("cannot apply ! to a value of type %v", typeName(val.typ))
+				return value{}
 			}
-			if l.typ == vbool {
-				return value{typ: vbool, v: l.v.(bool) != r.v.(bool)}
+		}
+		return val
+	case kbinaryexpr:
+		switch op := nod.value.ttype; op {
+		case tcoalesce:
+			// The right side is only evaluated if the left is nil.
+			if l := interp.evalRvalue(nod.list[0]); l.typ != vnil || interp.err != nil {
+				return l
 			}
-			if l.typ == vstring {
-				return value{typ: vbool, v: l.v.(string) != r.v.(string)}
+			return interp.evalRvalue(nod.list[1])
+		case tland, tlor:
+			// The right side is only evaluated if the left doesn't
+			// determine the result.
+			l := interp.evalRvalue(nod.list[0])
+			if interp.err != nil {
+				return value{}
 			}
-			// TODO: array?
-		case tleq:
-			if l.typ == vnum {
-				return value{typ: vbool, v: l.v.(int) <= r.v.(int)}
+			if l.typ == vbool && l.v.(bool) == (op == tlor) {
+				return l
 			}
-		case tgeq:
-			if l.typ == vnum {
-				return value{typ: vbool, v: l.v.(int) >= r.v.(int)}
+			r := interp.evalRvalue(nod.list[1])
+			if interp.err != nil {
+				return value{}
 			}
+			return interp.binaryOp(op, l, r)
 		}
-		interp.err = fmt.Errorf("invalid op %v", nod.value.ttype)
-		return value{}
+		if op := nod.value.ttype; op == tis || op == tas {
+			return interp.evalTypeTest(nod)
+		}
+		l, r := interp.evalRvalue(nod.list[0]), interp.evalRvalue(nod.list[1])
+		if interp.err != nil {
+			return value{}
+		}
+		v := interp.binaryOp(nod.value.ttype, l, r)
+		interp.arithErrorAt(nod)
+		return v
 	case kindexexpr:
 		m := interp.evalRvalue(nod.list[0])
 		i := interp.evalRvalue(nod.list[1])
-		return m.get(i)
+		return interp.index(m, i)
 	case kselectorexpr:
-		m := interp.evalRvalue(nod.list[0])
-		k := interp.evalRvalue(nod.list[1])
-		return m.get(k)
+		return interp.selector(interp.evalRvalue(nod.list[0]), nod.list[1].value.text)
 	case kparenexpr:
 		return interp.evalRvalue(nod.list[0])
 	case kcallexpr:
-		// panic("TODO")
-		if nod.list[0].value.text == "print" {
-			fmt.Println(interp.evalRvalue(nod.list[1]))
+		v := interp.evalCall(nod)
+		if v.typ == vtuple {
+			interp.err = fmt.Errorf("%v: multiple values used as a single value", nod.pos)
 			return value{}
 		}
-		f := interp.evalRvalue(nod.list[0]).v.(*node)
-		return interp.evalFuncBody(f.list[:len(f.list)-1], nod.list[1:], f.list[len(f.list)-1])
-		// fmt.Println(interp.evalRvalue(node.list[1]))
+		return v
 	}
 	return value{}
 }
 
+// evalMulti evaluates an expression that may be a call returning multiple
+// values.
+func (interp *interp) evalMulti(nod *node) value {
+	if nod.kind == kcallexpr {
+		return interp.evalCall(nod)
+	}
+	return interp.evalRvalue(nod)
+}
+
+func (interp *interp) evalCall(nod *node) value {
+	if interp.err != nil {
+		return value{}
+	}
+	if nod.list[0].kind == kident && nod.list[0].value.text == "assert" && interp.env.lookup("assert") == nil && len(nod.list) > 1 {
+		return interp.evalAssert(nod)
+	}
+	var fv value
+	var args []value
+	if x := nod.list[0]; x.kind == kselectorexpr {
+		recv := interp.evalRvalue(x.list[0])
+		fv = interp.selector(recv, x.list[1].value.text)
+		switch {
+		case recv.typ == vclass && x.list[0].kind == kident && x.list[0].value.text == "super":
+			// A method called through super has the caller's receiver.
+			args = append(args, interp.evalRvalue(&node{kind: kident, pos: x.pos, value: token{ttype: tident, text: "self"}}))
+		case recv.typ != vmodule && recv.typ != vclass && isMethod(fv):
+			args = append(args, recv)
+		}
+	} else {
+		fv = interp.evalRvalue(x)
+	}
+	if interp.maxDepth > 0 && len(interp.calls) >= interp.maxDepth {
+		interp.err = fmt.Errorf("%v: maximum call depth of %v exceeded\n%v", nod.pos, interp.maxDepth, interp.callChain())
+		return value{}
+	}
+	var named []*node
+	for _, arg := range nod.list[1:] {
+		if arg.kind == kkvexpr {
+			named = append(named, arg)
+			continue
+		}
+		args = append(args, interp.evalRvalue(arg))
+	}
+	if named != nil {
+		args = interp.bindNamed(fv, args, named)
+	}
+	interp.calls = append(interp.calls, frame{callee(nod.list[0]), nod.pos})
+	if interp.report != nil {
+		_, isBuiltin := fv.v.(*builtinFunc)
+		interp.report.called(callee(nod.list[0]), isBuiltin, len(interp.calls))
+	}
+	v := interp.call(fv, args)
+	interp.calls = interp.calls[:len(interp.calls)-1]
+	return v
+}
+
+// A frame records a call, for reporting the call chain in errors.
+type frame struct {
+	name string
+	pos  scanner.Position
+}
+
+// callee returns a name for the function called by evaluating x.
+func callee(x *node) string {
+	switch x.kind {
+	case kident:
+		return x.value.text
+	case kselectorexpr:
+		return callee(x.list[0]) + "." + x.list[1].value.text
+	case kfunclit:
+		return "func literal"
+	}
+	return "function"
+}
+
+// callChain describes the calls being evaluated, innermost first. Only
+// the innermost and outermost few are listed if there are many.
+func (interp *interp) callChain() string {
+	const shown = 10
+	var sb strings.Builder
+	sb.WriteString("call chain:")
+	for i := len(interp.calls) - 1; i >= 0; i-- {
+		if i == len(interp.calls)-1-shown && i >= shown {
+			fmt.Fprintf(&sb, "\n\t... %v more calls", i+1-shown)
+			i = shown
+			continue
+		}
+		f := interp.calls[i]
+		fmt.Fprintf(&sb, "\n\t%v called at %v", f.name, f.pos)
+	}
+	return sb.String()
+}
+
+// bindNamed returns the arguments to the function fv in parameter order,
+// given the positional arguments args followed by the named arguments.
+func (interp *interp) bindNamed(fv value, args []value, named []*node) []value {
+	if interp.err != nil {
+		return nil
+	}
+	// A struct's fields are its parameters, but unlike a function's, they
+	// may be left out.
+	var params []string
+	optional := false
+	switch f := fv.v.(type) {
+	case *closure:
+		for _, p := range f.params() {
+			params = append(params, p.value.text)
+		}
+	case *structType:
+		params, optional = f.fields, true
+	case *class:
+		// The arguments are passed to init after self.
+		if init, ok := f.method("init"); ok {
+			for _, p := range init.v.(*closure).params()[1:] {
+				params = append(params, p.value.text)
+			}
+		}
+	case builtin:
+		interp.err = fmt.Errorf("cannot use named arguments with a builtin")
+		return nil
+	default:
+		interp.err = fmt.Errorf("cannot call %v", fv.typ)
+		return nil
+	}
+	if len(args) > len(params) {
+		interp.err = fmt.Errorf("too many arguments: %v > %v", len(args), len(params))
+		return nil
+	}
+	bound := make([]value, len(params))
+	given := make([]bool, len(params))
+	copy(bound, args)
+	for i := range args {
+		given[i] = true
+	}
+	for _, n := range named {
+		name := n.list[0].value.text
+		i := 0
+		for i < len(params) && params[i] != name {
+			i++
+		}
+		switch {
+		case i == len(params):
+			interp.err = fmt.Errorf("%v: unknown parameter %v", n.pos, name)
+			return nil
+		case given[i]:
+			interp.err = fmt.Errorf("%v: argument %v given more than once", n.pos, name)
+			return nil
+		}
+		bound[i], given[i] = interp.evalRvalue(n.list[1]), true
+	}
+	for i, ok := range given {
+		if !ok && !optional {
+			interp.err = fmt.Errorf("missing argument %v", params[i])
+			return nil
+		}
+	}
+	return bound
+}
+
+func (interp *interp) binaryOp(op ttype, l, r value) value {
+	if l.typ == vobject {
+		if v, ok := interp.overloadedOp(op, l, r); ok {
+			return v
+		}
+	}
+	// nil is equal only to itself, and may be compared with any value.
+	if (op == teql || op == tneq) && (l.typ == vnil || r.typ == vnil) {
+		return value{typ: vbool, v: (l.typ == r.typ) == (op == teql)}
+	}
+	if (l.typ == vfloat || isInteger(l)) && (r.typ == vfloat || isInteger(r)) && (l.typ == vfloat || r.typ == vfloat) {
+		return interp.floatOp(op, l, r)
+	}
+	if isInteger(l) && isInteger(r) && (l.typ == vbig || r.typ == vbig) {
+		return interp.bigOp(op, l, r)
+	}
+	if (l.typ == vdecimal || isInteger(l)) && (r.typ == vdecimal || isInteger(r)) && (l.typ == vdecimal || r.typ == vdecimal) {
+		return interp.decimalOp(op, l, r)
+	}
+	if l.typ != r.typ {
+		interp.err = fmt.Errorf("type mismatch in binaryexpr %v != %v", l.typ, r.typ)
+		return value{}
+	}
+	switch op {
+	case tplus:
+		if l.typ == vstring {
+			return value{typ: vstring, v: l.v.(string) + r.v.(string)}
+		}
+		if l.typ == vbytes {
+			return value{typ: vbytes, v: slices.Concat(l.v.([]byte), r.v.([]byte))}
+		}
+		if l.typ == vnum {
+			return interp.arith(op, l, r)
+		}
+	case tsub:
+		if l.typ == vnum {
+			return interp.arith(op, l, r)
+		}
+	case tmul:
+		if l.typ == vnum {
+			return interp.arith(op, l, r)
+		}
+	case tquo:
+		if l.typ == vnum {
+			return interp.arith(op, l, r)
+		}
+	case trem:
+		if l.typ == vnum {
+			return interp.arith(op, l, r)
+		}
+	case tpow:
+		if l.typ == vnum {
+			return interp.arith(op, l, r)
+		}
+	case tland:
+		if l.typ == vbool {
+			return value{typ: vbool, v: l.v.(bool) && r.v.(bool)}
+		}
+	case tlor:
+		if l.typ == vbool {
+			return value{typ: vbool, v: l.v.(bool) || r.v.(bool)}
+		}
+	case teql:
+		if l.typ == vnum {
+			return value{typ: vbool, v: l.v.(int) == r.v.(int)}
+		}
+		if l.typ == vbool {
+			return value{typ: vbool, v: l.v.(bool) == r.v.(bool)}
+		}
+		if l.typ == vstring {
+			return value{typ: vbool, v: l.v.(string) == r.v.(string)}
+		}
+		if l.typ == vbitset || l.typ == vrecord || l.typ == vobject || l.typ == vclass || l.typ == vfunc || l.typ == varray || l.typ == vmap || l.typ == vset || l.typ == vsortedmap || l.typ == vbytes {
+			return value{typ: vbool, v: l.eq(r)}
+		}
+	case tlss:
+		if l.typ == vnum {
+			return value{typ: vbool, v: l.v.(int) < r.v.(int)}
+		}
+	case tgtr:
+		if l.typ == vnum {
+			return value{typ: vbool, v: l.v.(int) > r.v.(int)}
+		}
+	case tneq:
+		if l.typ == vnum {
+			return value{typ: vbool, v: l.v.(int) != r.v.(int)}
+		}
+		if l.typ == vbool {
+			return value{typ: vbool, v: l.v.(bool) != r.v.(bool)}
+		}
+		if l.typ == vstring {
+			return value{typ: vbool, v: l.v.(string) != r.v.(string)}
+		}
+		if l.typ == vbitset || l.typ == vrecord || l.typ == vobject || l.typ == vclass || l.typ == vfunc || l.typ == varray || l.typ == vmap || l.typ == vset || l.typ == vsortedmap || l.typ == vbytes {
+			return value{typ: vbool, v: !l.eq(r)}
+		}
+	case tleq:
+		if l.typ == vnum {
+			return value{typ: vbool, v: l.v.(int) <= r.v.(int)}
+		}
+	case tgeq:
+		if l.typ == vnum {
+			return value{typ: vbool, v: l.v.(int) >= r.v.(int)}
+		}
+	}
+	interp.err = fmt.Errorf("invalid op %v", op)
+	return value{}
+}
+
 func (interp *interp) evalStmt(node *node) {
 	if interp.err != nil {
 		return
 	}
+	interp.pos = node.pos
 	switch node.kind {
 	case kassignstmt:
 		// handle declaration
@@ -364,6 +1087,14 @@ func (interp *interp) evalStmt(node *node) {
 			if it already exists, set
 			else store in current scope
 		*/
+		if node.value.ttype != tillegal {
+			interp.evalAssignOp(node)
+			return
+		}
+		if node.list[0].kind == karraylit {
+			interp.setValue(node.list[0], interp.evalMulti(node.list[1]))
+			return
+		}
 		interp.setValue(node.list[0], interp.evalRvalue(node.list[1]))
 	case kblockstmt:
 		interp.evalBlock(node)
@@ -374,15 +1105,192 @@ func (interp *interp) evalStmt(node *node) {
 		} else if len(node.list) == 3 {
 			interp.evalStmt(node.list[2])
 		}
-	case kemptystmt:
-		// do nothing
+	case kemptystmt, kfuncdecl:
+		// do nothing; functions are bound by evalStmts
 	case kexprstmt:
-		interp.evalRvalue(node.list[0])
+		interp.evalMulti(node.list[0])
 	case kwhilestmt:
-		for interp.isTrue(interp.evalRvalue(node.list[0])) {
+		for !interp.returning && interp.isTrue(interp.evalRvalue(node.list[0])) {
 			interp.evalBlock(node.list[1])
 		}
+	case kforstmt:
+		interp.evalFor(node)
 	case kreturnstmt:
-		interp.ret = interp.evalRvalue(node.list[0])
+		switch len(node.list) {
+		case 0:
+			interp.ret = value{typ: vnil}
+		case 1:
+			interp.ret = interp.evalMulti(node.list[0])
+		default:
+			vs := make([]value, len(node.list))
+			for i, x := range node.list {
+				vs[i] = interp.evalRvalue(x)
+			}
+			interp.ret = value{typ: vtuple, v: vs}
+		}
+		interp.returning = true
+	case kimportstmt:
+		interp.evalImport(node)
+	case kclassdecl:
+		interp.evalClass(node)
+	case kletstmt:
+		interp.evalLet(node)
+	case ktrystmt:
+		interp.evalTry(node)
+	case kthrowstmt:
+		interp.evalThrow(node)
+	case kexportstmt:
+		interp.evalStmt(node.list[0])
+		if interp.mod != nil {
+			interp.mod.exports[node.list[0].list[0].value.text] = true
+		}
+	}
+}
+
+// evalLet declares variables in the current scope, after evaluating their
+// initial values, which are nil if none are given.
+func (interp *interp) evalLet(nod *node) {
+	lhs, rhs := nod.list[0], nod.list[1]
+	var v value
+	if rhs != nil {
+		if lhs.kind == karraylit {
+			v = interp.evalMulti(rhs)
+		} else {
+			v = interp.evalRvalue(rhs)
+		}
+		if interp.err != nil {
+			return
+		}
+	}
+	names := []*node{lhs}
+	if lhs.kind == karraylit {
+		names = lhs.list
+	}
+	for _, n := range names {
+		if _, ok := interp.env.m[n.value.text]; ok {
+			interp.err = fmt.Errorf("%v: %v is already declared in this scope", n.pos, n.value.text)
+			return
+		}
+		interp.env.m[n.value.text] = value{typ: vnil}
+	}
+	if rhs != nil {
+		interp.setValue(lhs, v)
+	}
+}
+
+// evalFor runs a for-in loop. Arrays are iterated in insertion order,
+// strings by character, a number n as the range 0 through n-1, and
+// objects by the iterator protocol. The entries of an array are fixed
+// when the loop begins, so assignments in the body don't affect the
+// iteration.
+func (interp *interp) evalFor(node *node) {
+	k, v, x, body := node.list[0], node.list[1], node.list[2], node.list[3]
+	xs := interp.evalRvalue(x)
+	if interp.err != nil {
+		return
+	}
+	if !iterable(xs) {
+		interp.err = fmt.Errorf("%v: cannot iterate over %v", x.pos, xs.typ)
+		return
+	}
+	interp.iterate(xs, func(kv, vv value) bool {
+		interp.beginScope()
+		if k != nil {
+			interp.env.m[k.value.text] = kv
+		}
+		interp.env.m[v.value.text] = vv
+		interp.evalBlock(body)
+		interp.endScope()
+		return interp.err == nil && !interp.returning
+	})
+}
+
+// ipow returns x**y. If the result overflows an int, it returns the
+// result wrapped around, and errOverflow. Negative exponents are an
+// error, since there are no fractional numbers.
+func ipow(x, y int) (int, error) {
+	if y < 0 {
+		return 0, fmt.Errorf("negative exponent %v", y)
+	}
+	n, overflow := 1, false
+	for y > 0 {
+		var ok bool
+		if y&1 == 1 {
+			n, ok = mulInt(n, x)
+			overflow = overflow || !ok
+		}
+		y >>= 1
+		if y > 0 {
+			x, ok = mulInt(x, x)
+			overflow = overflow || !ok
+		}
+	}
+	if overflow {
+		return n, errOverflow
+	}
+	return n, nil
+}
+
+// assignOps maps each compound assignment operator to its binary operator.
+var assignOps = map[ttype]ttype{
+	taddassign: tplus,
+	tsubassign: tsub,
+	tmulassign: tmul,
+	tquoassign: tquo,
+	tremassign: trem,
+}
+
+// evalAssignOp evaluates a compound assignment like x op= y. The operands
+// of an index or selector target are evaluated only once.
+func (interp *interp) evalAssignOp(node *node) {
+	lhs, op := node.list[0], assignOps[node.value.ttype]
+	switch lhs.kind {
+	case kident:
+		l := interp.evalRvalue(lhs)
+		r := interp.evalRvalue(node.list[1])
+		if interp.err != nil {
+			return
+		}
+		v := interp.binaryOp(op, l, r)
+		if interp.arithErrorAt(node); interp.err != nil {
+			return
+		}
+		interp.setValue(lhs, v)
+	case kindexexpr, kselectorexpr:
+		m := interp.evalRvalue(lhs.list[0])
+		if m.typ == vmodule {
+			interp.err = fmt.Errorf("cannot assign to module member %v", lhs.list[1].value.text)
+			return
+		}
+		if (m.typ == vrecord || m.typ == vobject) && lhs.kind == kselectorexpr {
+			l := interp.selector(m, lhs.list[1].value.text)
+			r := interp.evalRvalue(node.list[1])
+			if interp.err != nil {
+				return
+			}
+			v := interp.binaryOp(op, l, r)
+			if interp.arithErrorAt(node); interp.err != nil {
+				return
+			}
+			interp.setMember(m, lhs.list[1].value.text, v)
+			return
+		}
+		var k value
+		if lhs.kind == kselectorexpr {
+			k = selectorKey(lhs)
+		} else {
+			k = interp.evalRvalue(lhs.list[1])
+		}
+		r := interp.evalRvalue(node.list[1])
+		if interp.err != nil {
+			return
+		}
+		v := interp.binaryOp(op, interp.index(m, k), r)
+		if interp.arithErrorAt(node); interp.err != nil {
+			return
+		}
+		interp.setIndex(&m, k, v)
+	default:
+		interp.err = fmt.Errorf("cannot assign to %v", lhs.kind)
 	}
 }
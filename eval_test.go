@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runScript runs the program src as the file name in a temporary
+// directory, and returns what it printed and the error it failed with.
+func runScript(t *testing.T, name, src string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp := &interp{main: path, stdin: strings.NewReader(""), stdout: &out}
+	af, err := parseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp.evalBlock(af)
+	return out.String(), interp.err
+}
+
+// wantOutput runs src and checks that it succeeds and prints want.
+func wantOutput(t *testing.T, src, want string) {
+	t.Helper()
+	got, err := runScript(t, "main.x", src)
+	if err != nil {
+		t.Fatalf("%v\nfailed: %v", src, err)
+	}
+	if got != want {
+		t.Errorf("%v\nprinted %q, want %q", src, got, want)
+	}
+}
+
+func TestUnaryTypeError(t *testing.T) {
+	wantOutput(t, `
+		try { x = -"s"; } catch e { println(e); };
+		try { x = !1; } catch e { println(e); };
+		println(-2, !true, !nil);
+	`, "cannot negate a value of type string\ncannot apply ! to a value of type num\n-2 false true\n")
+}
+
+func TestDivideByZeroPosition(t *testing.T) {
+	_, err := runScript(t, "main.x", "x = 0;\ntry {\n  y = 1 / x;\n} catch e {\n  throw e;\n};\n")
+	if want := "main.x:3:7: uncaught exception: division by zero"; err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("got error %v, want one ending in %q", err, want)
+	}
+	_, err = runScript(t, "main.x", "x = 0;\ny = 1 / x;\n")
+	if want := "main.x:2:5: division by zero"; err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("got error %v, want one ending in %q", err, want)
+	}
+}
+
+func TestClosureCounter(t *testing.T) {
+	wantOutput(t, `
+		func counter() {
+			n = 0;
+			return func() { n = n + 1; return n; };
+		};
+		c1 = counter();
+		c2 = counter();
+		println(c1(), c1(), c1(), c2());
+	`, "1 2 3 1\n")
+}
+
+func TestClosureAdder(t *testing.T) {
+	wantOutput(t, `
+		func adder(a) { return x => x + a; };
+		add2 = adder(2);
+		add10 = adder(10);
+		println(add2(1), add10(1), add2(add10(0)));
+	`, "3 11 12\n")
+}
+
+func TestClosureScope(t *testing.T) {
+	// A closure sees later assignments to the variables it captured, and
+	// each iteration's let binding is captured separately.
+	wantOutput(t, `
+		x = 1;
+		f = () => x;
+		x = 2;
+		fs = [];
+		for i in 3 { let j = i; fs[len(fs)] = () => j * j; };
+		println(f(), fs[0](), fs[1](), fs[2]());
+	`, "2 0 1 4\n")
+}
+
+func TestArrayAliases(t *testing.T) {
+	// Arrays are references, so watching or freezing one, or changing it
+	// through a variable or parameter, affects every alias of it, however
+	// watch and freeze are reached.
+	wantOutput(t, `
+		a = [1, 2];
+		w = watch;
+		w(a, func(k, old, new) { println("changed", k, old, new); });
+		b = a;
+		b[1] = 6;
+		b[2] = 7;
+		println(a);
+		func add(xs) { xs[len(xs)] = 8; };
+		add(a);
+		println(len(b));
+		c = deepcopy(a);
+		c[0] = 0;
+		println(a[0]);
+		fz = freeze;
+		fz(a);
+		try { b[0] = 2; } catch e { println(e); };
+		d = [1];
+		e = freeze(d);
+		try { d[0] = 2; } catch err { println(err); };
+		m = ["x": [1]];
+		freeze(m["x"]);
+		try { m["x"][0] = 2; } catch err { println(err); };
+	`, "changed 1 2 6\nchanged 2 nil 7\n[0:1,1:6,2:7]\nchanged 3 nil 8\n4\n1\ncannot modify frozen array\ncannot modify frozen array\ncannot modify frozen array\n")
+}
+
+func TestFuncEquality(t *testing.T) {
+	wantOutput(t, `
+		func f() { return 1; };
+		g = f;
+		h = () => 1;
+		println(f == f, f == g, f != g, f == h, h == h);
+		p = print;
+		println(print == print, p == print, print == println, md5 == sha1, md5 == md5, f == print, f == nil);
+		z = archive.zip;
+		println(z == archive.zip, archive.zip == archive.tar);
+	`, "true true false false true\ntrue true false false true false false\ntrue false\n")
+}
+
+func TestContainerEquality(t *testing.T) {
+	// Elements are compared by the same rules as numbers themselves, but
+	// numbers of different types are different keys.
+	wantOutput(t, `
+		println(1 == 1.0, [1] == [1.0], {"a": 1} == {"a": 1.0}, [decimal("1")] == [1], [[2]] == [[2.0]]);
+		println([1] == [2.0], [decimal("1")] == [1.0], [1: "a"] == [1.0: "a"]);
+		a = [];
+		a[1] = "x";
+		println(a[1.0], len(a));
+		s = sortedmap();
+		s[1] = 1;
+		t = sortedmap();
+		t[1] = 1.0;
+		println(s == t);
+	`, "true true true true true\nfalse false false\nnil 1\ntrue\n")
+}
+
+func TestMapRemove(t *testing.T) {
+	// Removing entries keeps the order of the rest, however many are
+	// removed, and a key added again comes last.
+	wantOutput(t, `
+		m = {};
+		for i in 10 { m[i] = i * i; };
+		for i in 8 { if i != 3 { remove(m, i); }; };
+		println(m, len(m));
+		remove(m, 3);
+		m[3] = 0;
+		println(m, keys(m), m == {8: 64, 9: 81, 3: 0});
+	`, "{3: 9, 8: 64, 9: 81} 3\n{8: 64, 9: 81, 3: 0} [0:8,1:9,2:3] true\n")
+}
+
+func TestOverflowLiteral(t *testing.T) {
+	// With -overflow=error, an integer literal that doesn't fit in an int
+	// overflows, except for the most negative int.
+	for _, tt := range []struct {
+		src     string
+		wantErr bool
+	}{
+		{"println(-9223372036854775808, 9223372036854775807);", false},
+		{"println(99999999999999999999);", true},
+		{"println(-9223372036854775809);", true},
+		{"println([1, 99999999999999999999]);", true},
+	} {
+		path := filepath.Join(t.TempDir(), "main.x")
+		if err := os.WriteFile(path, []byte(tt.src+"\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		af, err := parseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		interp := &interp{main: path, stdin: strings.NewReader(""), stdout: &out, overflow: overflowError}
+		interp.evalBlock(af)
+		if got := errors.Is(interp.err, errOverflow); got != tt.wantErr {
+			t.Errorf("%s: got error %v, want overflow %v", tt.src, interp.err, tt.wantErr)
+		}
+	}
+}
+
+func TestBitsetLimit(t *testing.T) {
+	wantOutput(t, `
+		b = bitset.new([1, 200]);
+		try { bitset.set(b, 1000000000000); } catch e { println(e); };
+		println(b, bitset.test(b, 1000000000000));
+	`, "bitset.set: 1000000000000 is too large for a bitset, whose members are at most 2147483647\nbitset{1,200} false\n")
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"text/scanner"
+)
+
+func init() {
+	builtins["error"] = (*interp).builtinError
+	builtins["iserror"] = (*interp).builtinIsError
+	builtins["exit"] = (*interp).builtinExit
+}
+
+// An errorValue is an error that was thrown, or that occurred while
+// evaluating a try statement's body, as caught by its catch clause. It has
+// the fields message, pos, and value, which is the thrown value, or nil
+// for a runtime error.
+type errorValue struct {
+	msg   string
+	pos   scanner.Position
+	value value
+}
+
+func (e *errorValue) String() string { return e.msg }
+
+// A thrown error is stored in interp.err while it propagates.
+type thrown struct {
+	e *errorValue
+}
+
+func (t *thrown) Error() string {
+	return fmt.Sprintf("%v: uncaught exception: %v", t.e.pos, t.e.msg)
+}
+
+// An exitStatus is stored in interp.err while the program exits with a
+// status code. Like a thrown error, it unwinds the program, running the
+// finally blocks it passes, but no catch clause can stop it.
+type exitStatus struct {
+	code int
+}
+
+func (e *exitStatus) Error() string {
+	return fmt.Sprintf("exit status %v", e.code)
+}
+
+// builtinExit exits the program with an optional status code, which is 0
+// by default.
+func (interp *interp) builtinExit(args []value) value {
+	code := 0
+	if len(args) > 1 || len(args) == 1 && args[0].typ != vnum {
+		interp.err = fmt.Errorf("exit expects an optional status code")
+		return value{}
+	}
+	if len(args) == 1 {
+		code = args[0].v.(int)
+	}
+	if code < 0 || code > 255 {
+		interp.err = fmt.Errorf("exit status %v out of range [0, 255]", code)
+		return value{}
+	}
+	interp.err = &exitStatus{code}
+	return value{}
+}
+
+// caught returns the error value for err, which was caught by a try
+// statement.
+// Runtime errors are reported at the statement that caused them.
+func (interp *interp) caught(err error) value {
+	switch err := err.(type) {
+	case *thrown:
+		return value{typ: verror, v: err.e}
+	case *arithError:
+		// The error value has the position, so it isn't in the message.
+		return value{typ: verror, v: &errorValue{msg: err.err.Error(), pos: err.pos, value: value{typ: vnil}}}
+	}
+	return interp.failure(err)
+}
+
+func (interp *interp) evalThrow(nod *node) {
+	v := interp.evalRvalue(nod.list[0])
+	if interp.err != nil {
+		return
+	}
+	if e, ok := v.v.(*errorValue); ok {
+		interp.err = &thrown{e}
+		return
+	}
+	interp.err = &thrown{&errorValue{msg: v.String(), pos: nod.pos, value: v}}
+}
+
+// evalTry runs a try statement. If its body fails, the error is bound to
+// the catch clause's name while its block runs. The finally block then
+// runs in any case; if it completes normally, the statement continues to
+// fail or return as it would have without it. A program that is exiting
+// isn't caught, and continues to exit even if the finally block fails or
+// returns, unless it calls exit itself.
+//
+// A block that fails leaves its scope in place, since endScope does nothing
+// once interp.err is set, so the scope around the statement is restored
+// after each block.
+func (interp *interp) evalTry(nod *node) {
+	body, name, catch, finally := nod.list[0], nod.list[1], nod.list[2], nod.list[3]
+	env := interp.env
+	interp.evalBlock(body)
+	interp.env = env
+	_, exiting := interp.err.(*exitStatus)
+	if interp.err != nil && catch != nil && !exiting {
+		e := interp.caught(interp.err)
+		interp.err = nil
+		interp.beginScope()
+		if name != nil {
+			interp.env.m[name.value.text] = e
+		}
+		interp.evalStmts(catch.list)
+		interp.env = env
+	}
+	if finally == nil {
+		return
+	}
+	err, ret, returning := interp.err, interp.ret, interp.returning
+	interp.err, interp.returning = nil, false
+	interp.evalBlock(finally)
+	interp.env = env
+	if _, ok := interp.err.(*exitStatus); exiting && !ok || interp.err == nil && !interp.returning {
+		interp.err, interp.ret, interp.returning = err, ret, returning
+	}
+}
+
+// failure returns an error value for err, which a builtin returns instead
+// of failing when it can't do what it was asked because of something
+// outside the program, like a missing file or malformed input. It is
+// reported at the statement calling the builtin.
+func (interp *interp) failure(err error) value {
+	return value{typ: verror, v: &errorValue{msg: err.Error(), pos: interp.pos, value: value{typ: vnil}}}
+}
+
+// builtinError returns an error value with a message and an optional
+// value, which may be returned by a function that fails, or thrown.
+func (interp *interp) builtinError(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("error expects a message and an optional value")
+		return value{}
+	}
+	e := &errorValue{msg: args[0].v.(string), pos: interp.pos, value: value{typ: vnil}}
+	if len(args) == 2 {
+		e.value = args[1]
+	}
+	return value{typ: verror, v: e}
+}
+
+func (interp *interp) builtinIsError(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("iserror expects a value")
+		return value{}
+	}
+	return value{typ: vbool, v: args[0].typ == verror}
+}
+
+// errorField returns the field name of the error e.
+func (interp *interp) errorField(e *errorValue, name string) value {
+	switch name {
+	case "message":
+		return value{typ: vstring, v: e.msg}
+	case "pos":
+		return value{typ: vstring, v: e.pos.String()}
+	case "value":
+		return e.value
+	}
+	interp.err = fmt.Errorf("error has no field %v", name)
+	return value{}
+}
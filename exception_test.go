@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestTryScope(t *testing.T) {
+	wantOutput(t, `
+		try {
+			try { q = 1; throw "x"; } catch e { r = 2; throw "y"; } finally { s = 3; throw "z"; };
+		} catch e {
+			println(e);
+		};
+		try { println(q); } catch e { println(e); };
+		try { println(r); } catch e { println(e); };
+		try { println(s); } catch e { println(e); };
+	`, "z\nno identifier named q exists\nno identifier named r exists\nno identifier named s exists\n")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+func init() {
+	builtins["exec"] = (*interp).builtinExec
+}
+
+// builtinExec runs a command with arguments, which are strings, and
+// returns three values: what it wrote to standard output and standard
+// error, as strings, and its exit code. A command that exits with a
+// nonzero code hasn't failed to run, but one that can't be started
+// results in an error value. It isn't allowed in the sandbox.
+func (interp *interp) builtinExec(args []value) value {
+	if len(args) < 1 {
+		interp.err = fmt.Errorf("exec expects a command and arguments")
+		return value{}
+	}
+	argv := make([]string, len(args))
+	for i, a := range args {
+		if a.typ != vstring {
+			interp.err = fmt.Errorf("exec expects a command and arguments, which are strings")
+			return value{}
+		}
+		argv[i] = a.v.(string)
+	}
+	if !interp.allowed("exec") {
+		return value{}
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	code := 0
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) {
+			return interp.failure(err)
+		}
+		code = exit.ExitCode()
+	}
+	return value{typ: vtuple, v: []value{
+		{typ: vstring, v: stdout.String()},
+		{typ: vstring, v: stderr.String()},
+		{typ: vnum, v: code},
+	}}
+}
@@ -0,0 +1,216 @@
+//go:build ffi && cgo && (linux || darwin) && (amd64 || arm64)
+
+package main
+
+// Functions are called through a single function pointer type, which
+// relies on the calling conventions of the System V and arm64 ABIs, and
+// libraries are opened with dlopen, so ffi is only built where both hold.
+// Elsewhere, ffi_other.go reports that it is unsupported.
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef int64_t i64;
+
+// Integer and floating point arguments are passed in separate registers,
+// each in order, so every function is called with all six integer and
+// eight floating point arguments, and ignores those it doesn't take.
+#define FFI_ARGS(a, d) a[0], a[1], a[2], a[3], a[4], a[5], d[0], d[1], d[2], d[3], d[4], d[5], d[6], d[7]
+#define FFI_TYPE(r) r (*)(i64, i64, i64, i64, i64, i64, double, double, double, double, double, double, double, double)
+
+static i64 ffi_call(void *f, i64 *a, double *d) {
+	return ((FFI_TYPE(i64))f)(FFI_ARGS(a, d));
+}
+
+static double ffi_calld(void *f, i64 *a, double *d) {
+	return ((FFI_TYPE(double))f)(FFI_ARGS(a, d));
+}
+
+static float ffi_callf(void *f, i64 *a, double *d) {
+	return ((FFI_TYPE(float))f)(FFI_ARGS(a, d));
+}
+
+static char *ffi_str(i64 r) {
+	return (char *)(intptr_t)r;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unsafe"
+)
+
+// maxFFIArgs and maxFFIFloats are the largest numbers of integer and
+// floating point arguments a C function can be called with.
+const (
+	maxFFIArgs   = 6
+	maxFFIFloats = 8
+)
+
+// An ffiLib is a shared library opened with ffi.open.
+type ffiLib struct {
+	name   string
+	handle unsafe.Pointer
+}
+
+func init() {
+	nativeModule("ffi", map[string]builtin{
+		"open":  (*interp).ffiOpen,
+		"call":  (*interp).ffiCall,
+		"close": (*interp).ffiClose,
+	})
+}
+
+func (interp *interp) ffiOpen(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("ffi.open expects a library name")
+		return value{}
+	}
+	if !interp.allowed("ffi.open") {
+		return value{}
+	}
+	name := C.CString(args[0].v.(string))
+	defer C.free(unsafe.Pointer(name))
+	h := C.dlopen(name, C.RTLD_NOW)
+	if h == nil {
+		interp.err = fmt.Errorf("ffi.open: %v", C.GoString(C.dlerror()))
+		return value{}
+	}
+	return value{typ: vhandle, v: &ffiLib{name: args[0].v.(string), handle: h}}
+}
+
+func (interp *interp) ffiLib(fn string, v value) *ffiLib {
+	lib, ok := v.v.(*ffiLib)
+	if !ok || v.typ != vhandle {
+		interp.err = fmt.Errorf("%v expects a library returned by ffi.open", fn)
+		return nil
+	}
+	if lib.handle == nil {
+		interp.err = fmt.Errorf("%v: library %v is closed", fn, lib.name)
+		return nil
+	}
+	return lib
+}
+
+func (interp *interp) ffiClose(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("ffi.close expects a library")
+		return value{}
+	}
+	lib := interp.ffiLib("ffi.close", args[0])
+	if lib == nil {
+		return value{}
+	}
+	C.dlclose(lib.handle)
+	lib.handle = nil
+	return value{}
+}
+
+// ffiCall calls a C function. Its arguments are the library, the name of
+// the function, its signature, and the arguments to pass to it.
+//
+// A signature has the form "r(a...)", where r is the result type and each a
+// is the type of an argument:
+//
+//	i  int
+//	l  int64_t or long
+//	p  pointer, passed as a number
+//	s  NUL-terminated string
+//	f  float, passed as a number or float
+//	d  double, passed as a number or float
+//	b  pointer to a copy of bytes or a string (argument only)
+//	v  void (result only)
+//
+// Arguments are passed in registers, as on amd64 and arm64, so a function
+// may take at most six arguments of the integer types (i, l, p, s, and b)
+// and eight of the floating point types (f and d). Functions with a
+// variable number of arguments can't be called.
+func (interp *interp) ffiCall(args []value) value {
+	if len(args) < 3 || args[1].typ != vstring || args[2].typ != vstring {
+		interp.err = fmt.Errorf("ffi.call expects a library, a function name, a signature, and arguments")
+		return value{}
+	}
+	lib := interp.ffiLib("ffi.call", args[0])
+	if lib == nil {
+		return value{}
+	}
+	name, sig, params := args[1].v.(string), args[2].v.(string), args[3:]
+	i, j := strings.IndexByte(sig, '('), strings.LastIndexByte(sig, ')')
+	if i != 1 || j != len(sig)-1 || !strings.ContainsRune("ilpsfdv", rune(sig[0])) {
+		interp.err = fmt.Errorf("ffi.call: invalid signature %q", sig)
+		return value{}
+	}
+	ret, types := sig[0], sig[i+1:j]
+	if len(types) != len(params) {
+		interp.err = fmt.Errorf("ffi.call: %v takes %v arguments, got %v", name, len(types), len(params))
+		return value{}
+	}
+	if n := strings.Count(types, "f") + strings.Count(types, "d"); len(types)-n > maxFFIArgs || n > maxFFIFloats {
+		interp.err = fmt.Errorf("ffi.call: %v takes more than %v integer or %v floating point arguments", name, maxFFIArgs, maxFFIFloats)
+		return value{}
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	f := C.dlsym(lib.handle, cname)
+	if f == nil {
+		interp.err = fmt.Errorf("ffi.call: %v", C.GoString(C.dlerror()))
+		return value{}
+	}
+	var a [maxFFIArgs]C.i64
+	var d [maxFFIFloats]C.double
+	na, nd := 0, 0
+	for k, t := range types {
+		p := params[k]
+		switch {
+		case t == 'd' && isNumeric(p):
+			d[nd] = C.double(toFloat(p))
+			nd++
+			continue
+		case t == 'f' && isNumeric(p):
+			// The callee reads a float from the low half of the register.
+			d[nd] = C.double(math.Float64frombits(uint64(math.Float32bits(float32(toFloat(p))))))
+			nd++
+			continue
+		case t == 's' && p.typ == vstring:
+			cs := C.CString(p.v.(string))
+			defer C.free(unsafe.Pointer(cs))
+			a[na] = C.i64(uintptr(unsafe.Pointer(cs)))
+		case t == 'b' && (p.typ == vbytes || p.typ == vstring):
+			b, _ := interp.binaryArg("ffi.call", []value{p})
+			cb := C.CBytes(b)
+			defer C.free(cb)
+			a[na] = C.i64(uintptr(cb))
+		case strings.ContainsRune("ilp", t) && p.typ == vnum:
+			a[na] = C.i64(p.v.(int))
+		default:
+			interp.err = fmt.Errorf("ffi.call: cannot pass %v as %c", p.typ, t)
+			return value{}
+		}
+		na++
+	}
+	switch ret {
+	case 'd':
+		return value{typ: vfloat, v: float64(C.ffi_calld(f, &a[0], &d[0]))}
+	case 'f':
+		return value{typ: vfloat, v: float64(C.ffi_callf(f, &a[0], &d[0]))}
+	}
+	r := C.ffi_call(f, &a[0], &d[0])
+	switch ret {
+	case 'i':
+		return value{typ: vnum, v: int(int32(r))}
+	case 'l', 'p':
+		return value{typ: vnum, v: int(r)}
+	case 's':
+		if r == 0 {
+			return value{}
+		}
+		return value{typ: vstring, v: C.GoString(C.ffi_str(r))}
+	}
+	return value{}
+}
@@ -0,0 +1,34 @@
+//go:build ffi && !(cgo && (linux || darwin) && (amd64 || arm64))
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func init() {
+	nativeModule("ffi", map[string]builtin{
+		"open":  (*interp).ffiOpen,
+		"call":  ffiUnsupported("ffi.call"),
+		"close": ffiUnsupported("ffi.close"),
+	})
+}
+
+// ffiOpen fails like the other functions, unless the sandbox disallows
+// it, as it does where ffi is supported.
+func (interp *interp) ffiOpen(args []value) value {
+	if !interp.allowed("ffi.open") {
+		return value{}
+	}
+	return ffiUnsupported("ffi.open")(interp, args)
+}
+
+// ffiUnsupported returns a builtin that fails, since calling C functions
+// requires cgo and the calling conventions ffi.go relies on.
+func ffiUnsupported(name string) builtin {
+	return func(interp *interp, args []value) value {
+		interp.err = fmt.Errorf("%v is not supported on %v/%v", name, runtime.GOOS, runtime.GOARCH)
+		return value{}
+	}
+}
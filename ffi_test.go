@@ -0,0 +1,52 @@
+//go:build ffi && cgo && (linux || darwin) && (amd64 || arm64)
+
+package main
+
+import "testing"
+
+// ffiLibOrSkip opens a shared library, skipping the test if it isn't
+// available.
+func ffiLibOrSkip(t *testing.T, name string) value {
+	t.Helper()
+	in := &interp{}
+	lib := lookupBuiltin(t, "ffi.open")(in, []value{str(name)})
+	if in.err != nil {
+		t.Skip(in.err)
+	}
+	t.Cleanup(func() { lookupBuiltin(t, "ffi.close")(in, []value{lib}) })
+	return lib
+}
+
+func TestFFICall(t *testing.T) {
+	libm := ffiLibOrSkip(t, "libm.so.6")
+	libc := ffiLibOrSkip(t, "libc.so.6")
+	num := func(n int) value { return value{typ: vnum, v: n} }
+	float := func(f float64) value { return value{typ: vfloat, v: f} }
+	tests := []struct {
+		lib       value
+		name, sig string
+		args      []value
+		want      value
+	}{
+		{libm, "sqrt", "d(d)", []value{float(2.25)}, float(1.5)},
+		{libm, "sqrt", "d(d)", []value{num(16)}, float(4)},
+		{libm, "sqrtf", "f(f)", []value{float(6.25)}, float(2.5)},
+		{libm, "ldexp", "d(di)", []value{float(1.5), num(3)}, float(12)},
+		{libm, "fma", "d(ddd)", []value{float(2), float(3), float(0.5)}, float(6.5)},
+		{libc, "abs", "i(i)", []value{num(-7)}, num(7)},
+		{libc, "strlen", "l(s)", []value{str("hello")}, num(5)},
+		{libc, "memcmp", "i(bbl)", []value{{typ: vbytes, v: []byte("abc")}, str("abd"), num(3)}, num(-1)},
+		{libc, "memcmp", "i(bbl)", []value{str("abc"), str("abc"), num(3)}, num(0)},
+	}
+	call := lookupBuiltin(t, "ffi.call")
+	for _, tt := range tests {
+		interp := &interp{}
+		got := call(interp, append([]value{tt.lib, str(tt.name), str(tt.sig)}, tt.args...))
+		if tt.name == "memcmp" && got.typ == vnum && got.v.(int) < 0 {
+			got.v = -1
+		}
+		if interp.err != nil || got.typ != tt.want.typ || got.v != tt.want.v {
+			t.Errorf("ffi.call(%v, %q) = %v, %v; want %v", tt.name, tt.sig, got, interp.err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	nativeModule("flags", map[string]builtin{
+		"parse": (*interp).flagsParse,
+		"usage": (*interp).flagsUsage,
+	})
+	builtins["args"] = (*interp).builtinArgs
+}
+
+// builtinArgs returns an array of the script's command-line arguments,
+// which follow its file name.
+func (interp *interp) builtinArgs(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("args expects no arguments")
+		return value{}
+	}
+	r := newArray()
+	for i, a := range interp.args {
+		r.set(value{typ: vnum, v: i}, value{typ: vstring, v: a})
+	}
+	return r
+}
+
+// A flagSpec describes one flag of a script.
+type flagSpec struct {
+	name     string
+	def      value // also gives the flag's type
+	usage    string
+	required bool
+}
+
+var flagTypes = map[string]value{
+	"string": {typ: vstring, v: ""},
+	"int":    {typ: vnum, v: 0},
+	"bool":   {typ: vbool, v: false},
+}
+
+// parseFlagSpecs converts a spec array to flag specs. Each key of the
+// array is a flag name, and its value is either the flag's default, whose
+// type is the flag's type, or an array with the keys "type" ("string",
+// "int", or "bool"), "default", "usage", and "required".
+func parseFlagSpecs(spec value) ([]flagSpec, error) {
+	if spec.typ != varray {
+		return nil, errors.New("spec must be an array")
+	}
+	var specs []flagSpec
+	for _, e := range spec.entries() {
+		if e.k.typ != vstring {
+			return nil, fmt.Errorf("flag name %v is not a string", e.k.elem())
+		}
+		f := flagSpec{name: e.k.v.(string), def: e.v}
+		switch e.v.typ {
+		case vstring, vnum, vbool:
+		case varray:
+			get := func(k string) value { return e.v.get(value{typ: vstring, v: k}) }
+			typ := "string"
+			if t := get("type"); t.typ == vstring {
+				typ = t.v.(string)
+			} else if t.typ != vnil {
+				return nil, fmt.Errorf("flag %v: type must be a string", f.name)
+			}
+			zero, ok := flagTypes[typ]
+			if !ok {
+				return nil, fmt.Errorf("flag %v: unknown type %q", f.name, typ)
+			}
+			f.def = get("default")
+			if f.def.typ == vnil {
+				f.def = zero
+			} else if f.def.typ != zero.typ {
+				return nil, fmt.Errorf("flag %v: default must be a %v", f.name, typ)
+			}
+			if u := get("usage"); u.typ == vstring {
+				f.usage = u.v.(string)
+			}
+			r := get("required")
+			f.required = r.typ == vbool && r.v.(bool)
+		default:
+			return nil, fmt.Errorf("flag %v: invalid spec", f.name)
+		}
+		specs = append(specs, f)
+	}
+	return specs, nil
+}
+
+// flagSet returns a flag set defining the flags in specs, and a function
+// that returns the value of each flag after parsing.
+func (interp *interp) flagSet(specs []flagSpec) (*flag.FlagSet, func(string) value) {
+	fs := flag.NewFlagSet(filepath.Base(interp.main), flag.ContinueOnError)
+	vals := make(map[string]func() value)
+	for _, f := range specs {
+		usage := f.usage
+		if f.required {
+			usage = strings.TrimSpace(usage + " (required)")
+		}
+		switch f.def.typ {
+		case vstring:
+			p := fs.String(f.name, f.def.v.(string), usage)
+			vals[f.name] = func() value { return value{typ: vstring, v: *p} }
+		case vnum:
+			p := fs.Int(f.name, f.def.v.(int), usage)
+			vals[f.name] = func() value { return value{typ: vnum, v: *p} }
+		case vbool:
+			p := fs.Bool(f.name, f.def.v.(bool), usage)
+			vals[f.name] = func() value { return value{typ: vbool, v: *p} }
+		}
+	}
+	return fs, func(name string) value { return vals[name]() }
+}
+
+func usageText(fs *flag.FlagSet) string {
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fmt.Fprintf(&buf, "usage: %v [flags] [args]\n", fs.Name())
+	fs.PrintDefaults()
+	return buf.String()
+}
+
+// flagsParse parses the script's command-line arguments, or the array of
+// strings given as its second argument, according to a spec. It returns
+// an array mapping each flag name to its value, and the indices 0, 1, ...
+// to the remaining arguments. On -h or invalid arguments, it prints the
+// usage to standard error and exits.
+func (interp *interp) flagsParse(args []value) value {
+	if len(args) < 1 || len(args) > 2 || len(args) == 2 && args[1].typ != varray {
+		interp.err = fmt.Errorf("flags.parse expects a spec and an optional array of arguments")
+		return value{}
+	}
+	specs, err := parseFlagSpecs(args[0])
+	if err != nil {
+		interp.err = fmt.Errorf("flags.parse: %v", err)
+		return value{}
+	}
+	argv := interp.args
+	if len(args) == 2 {
+		argv = nil
+		for _, e := range args[1].entries() {
+			if e.v.typ != vstring {
+				interp.err = fmt.Errorf("flags.parse expects an array of string arguments")
+				return value{}
+			}
+			argv = append(argv, e.v.v.(string))
+		}
+	}
+	fs, get := interp.flagSet(specs)
+	usage := usageText(fs)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	if err := fs.Parse(argv); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, f := range specs {
+		if f.required && !set[f.name] {
+			fmt.Fprintf(os.Stderr, "missing required flag -%v\n%v", f.name, usage)
+			os.Exit(2)
+		}
+	}
+	result := newArray()
+	for _, f := range specs {
+		result.set(value{typ: vstring, v: f.name}, get(f.name))
+	}
+	for i, a := range fs.Args() {
+		result.set(value{typ: vnum, v: i}, value{typ: vstring, v: a})
+	}
+	return result
+}
+
+// flagsUsage returns the usage text generated from a spec.
+func (interp *interp) flagsUsage(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("flags.usage expects a spec")
+		return value{}
+	}
+	specs, err := parseFlagSpecs(args[0])
+	if err != nil {
+		interp.err = fmt.Errorf("flags.usage: %v", err)
+		return value{}
+	}
+	fs, _ := interp.flagSet(specs)
+	return value{typ: vstring, v: usageText(fs)}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFlagsParse(t *testing.T) {
+	wantOutput(t, `
+		spec = [
+			"name": "world",
+			"n": 1,
+			"v": false,
+			"mode": ["type": "string", "default": "fast", "usage": "how fast"],
+			"count": ["type": "int"],
+		];
+		o = flags.parse(spec, ["-n", "3", "-v", "-name=x", "a", "b"]);
+		println(o["name"], o["n"], o["v"], o["mode"], o["count"], o[0], o[1]);
+		o = flags.parse(spec, []);
+		println(o["name"], o["n"], o["v"], o["mode"], o["count"], len(o));
+	`, "x 3 true fast 0 a b\nworld 1 false fast 0 5\n")
+}
+
+func TestFlagsSpecErrors(t *testing.T) {
+	for _, tt := range []struct{ spec, want string }{
+		{`5`, "flags.parse: spec must be an array"},
+		{`[1: "x"]`, "flags.parse: flag name 1 is not a string"},
+		{`["n": ["type": "float"]]`, `flags.parse: flag n: unknown type "float"`},
+		{`["n": ["type": 1]]`, "flags.parse: flag n: type must be a string"},
+		{`["n": ["type": "int", "default": "1"]]`, "flags.parse: flag n: default must be a int"},
+		{`["n": nil]`, "flags.parse: flag n: invalid spec"},
+	} {
+		_, err := runScript(t, "main.x", "flags.parse("+tt.spec+", []);\n")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("flags.parse(%v): got error %v, want %v", tt.spec, err, tt.want)
+		}
+	}
+	_, err := runScript(t, "main.x", `flags.parse(["n": 1], [2]);`+"\n")
+	if want := "flags.parse expects an array of string arguments"; err == nil || err.Error() != want {
+		t.Errorf("non-string arguments: got error %v, want %v", err, want)
+	}
+}
+
+func TestFlagsUsage(t *testing.T) {
+	out, err := runScript(t, "tool.x", `
+		print(flags.usage(["out": ["usage": "output file", "required": true], "n": 1]));
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"usage: tool.x [flags] [args]\n", "-n int", "-out string", "output file (required)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("usage %q doesn't contain %q", out, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Floats are IEEE 754 double-precision numbers, written in programs with
+// a point or an exponent, as in 1.5 or 2e10. Arithmetic between a float
+// and a number produces a float, but floats and decimals can't be mixed,
+// since a float can't represent a decimal exactly. Dividing a float by
+// zero fails, as for numbers.
+
+// parseLiteral returns the number or float written as s in a program.
+func parseLiteral(s string) (value, error) {
+	n, err := parseNum(s)
+	if err == nil || s == "" || !strings.ContainsAny(s, ".eE") || !strings.ContainsAny(s[:1], ".0123456789") {
+		return n, err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return value{}, err
+	}
+	return value{typ: vfloat, v: f}, nil
+}
+
+// formatFloat formats f as the shortest float literal that would have
+// the same value, with a point if it would otherwise look like a number.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// isNumeric reports whether v is a number, decimal, or float.
+func isNumeric(v value) bool {
+	return isInteger(v) || v.typ == vdecimal || v.typ == vfloat
+}
+
+// toFloat returns the number, decimal, or float v as the nearest float.
+func toFloat(v value) float64 {
+	switch v.typ {
+	case vfloat:
+		return v.v.(float64)
+	case vnum:
+		return float64(v.v.(int))
+	case vdecimal:
+		d := v.v.(*decimal)
+		f, _ := new(big.Rat).SetFrac(d.unscaled, pow10(d.scale)).Float64()
+		return f
+	}
+	f, _ := new(big.Float).SetInt(v.v.(*big.Int)).Float64()
+	return f
+}
+
+// floatToNum returns the number f, which must be a whole number.
+func (interp *interp) floatToNum(f float64) value {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		interp.err = fmt.Errorf("cannot convert %v to a number", formatFloat(f))
+		return value{}
+	}
+	if f >= math.MinInt && f < math.MaxInt {
+		return value{typ: vnum, v: int(f)}
+	}
+	x, _ := big.NewFloat(f).Int(nil)
+	return normBig(x)
+}
+
+// floatOp applies op to l and r, at least one of which is a float and the
+// other a number.
+func (interp *interp) floatOp(op ttype, l, r value) value {
+	x, y := toFloat(l), toFloat(r)
+	var z float64
+	switch op {
+	case tplus:
+		z = x + y
+	case tsub:
+		z = x - y
+	case tmul:
+		z = x * y
+	case tquo, trem:
+		if y == 0 {
+			interp.err = errDivideByZero
+			return value{}
+		}
+		if op == tquo {
+			z = x / y
+			break
+		}
+		z = math.Mod(x, y)
+		if interp.modulo == moduloEuclidean && z < 0 {
+			z += math.Abs(y)
+		}
+	case tpow:
+		z = math.Pow(x, y)
+	case teql:
+		return value{typ: vbool, v: x == y}
+	case tneq:
+		return value{typ: vbool, v: x != y}
+	case tlss:
+		return value{typ: vbool, v: x < y}
+	case tleq:
+		return value{typ: vbool, v: x <= y}
+	case tgtr:
+		return value{typ: vbool, v: x > y}
+	case tgeq:
+		return value{typ: vbool, v: x >= y}
+	default:
+		interp.err = fmt.Errorf("invalid op %v", op)
+		return value{}
+	}
+	return value{typ: vfloat, v: z}
+}
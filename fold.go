@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// fold replaces operations on literals in the tree rooted at n with their
+// results. Operations that would fail at runtime, like division by zero,
+// are left alone so that they fail when evaluated, and those that would
+// overflow, so that their results are promoted.
+func fold(n *node) *node {
+	if n == nil {
+		return nil
+	}
+	for i := range n.list {
+		n.list[i] = fold(n.list[i])
+	}
+	switch n.kind {
+	case kparenexpr:
+		if x := n.list[0]; x.kind == knumlit || x.kind == kstringlit {
+			return x
+		}
+	case kunaryexpr:
+		x := n.list[0]
+		if x.kind != knumlit {
+			break
+		}
+		switch n.value.ttype {
+		case tplus:
+			return x
+		case tsub:
+			if i, err := strconv.Atoi(x.value.text); err == nil && i != math.MinInt {
+				return numlit(n, -i)
+			}
+		}
+	case kbinaryexpr:
+		x, y := n.list[0], n.list[1]
+		switch {
+		case x.kind == knumlit && y.kind == knumlit:
+			a, err1 := strconv.Atoi(x.value.text)
+			b, err2 := strconv.Atoi(y.value.text)
+			if err1 != nil || err2 != nil {
+				break
+			}
+			switch op := n.value.ttype; op {
+			case tplus, tsub, tmul, tpow:
+				if i, ok := intOp(op, a, b); ok {
+					return numlit(n, i)
+				}
+			case tquo, trem:
+				// The results for negative operands depend on -modulo.
+				if a >= 0 && b > 0 {
+					i, _ := intOp(op, a, b)
+					return numlit(n, i)
+				}
+			}
+		case x.kind == kstringlit && y.kind == kstringlit && n.value.ttype == tplus:
+			a, err1 := strconv.Unquote(x.value.text)
+			b, err2 := strconv.Unquote(y.value.text)
+			if err1 != nil || err2 != nil {
+				break
+			}
+			return &node{kind: kstringlit, pos: n.pos, value: token{ttype: tstring, pos: n.pos, text: strconv.Quote(a + b)}}
+		}
+	}
+	return n
+}
+
+func numlit(n *node, i int) *node {
+	return &node{kind: knumlit, pos: n.pos, value: token{ttype: tnum, pos: n.pos, text: strconv.Itoa(i)}}
+}
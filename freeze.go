@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+func init() {
+	builtins["freeze"] = (*interp).builtinFreeze
+}
+
+// isFrozen reports whether v is a frozen array, map, set, sorted map, or
+// record. A frozen one can't be modified: assigning to one of its entries
+// or fields, or adding or removing one, is an error. Freezing is deep, so
+// the values a frozen one holds are frozen too, and frozen values can be
+// shared safely.
+func isFrozen(v value) bool {
+	switch x := v.v.(type) {
+	case *array:
+		return x.frozen
+	case *hashMap:
+		return x.frozen
+	case *sortedMap:
+		return x.frozen
+	case *record:
+		return x.frozen
+	}
+	return false
+}
+
+// freeze freezes v, along with the values it holds, and returns it.
+func freeze(v value) value {
+	if isFrozen(v) {
+		return v
+	}
+	switch x := v.v.(type) {
+	case *array:
+		// A frozen array is never watched, since it never changes.
+		x.frozen = true
+		x.watchers = nil
+		for i, e := range x.entries {
+			x.entries[i].v = freeze(e.v)
+		}
+	case *hashMap:
+		x.frozen = true
+		for i, e := range x.items() {
+			x.entries[i].v = freeze(e.v)
+		}
+	case *sortedMap:
+		x.frozen = true
+		x.watchers = nil
+		for i, e := range x.entries {
+			x.entries[i].v = freeze(e.v)
+		}
+	case *record:
+		x.frozen = true
+		for i, f := range x.fields {
+			x.fields[i] = freeze(f)
+		}
+	}
+	return v
+}
+
+// builtinFreeze freezes an array, map, set, sorted map, or record and
+// returns it. Other values, which are immutable, are returned as is.
+func (interp *interp) builtinFreeze(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("freeze expects one argument")
+		return value{}
+	}
+	return freeze(args[0])
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The fs module works with files and paths. Since remove is already a
+// builtin for sets and maps, its functions are in a module rather than
+// builtins. Those that use the filesystem aren't allowed in the sandbox,
+// and return error values when the operating system reports an error.
+func init() {
+	nativeModule("fs", map[string]builtin{
+		"listdir":  (*interp).fsListDir,
+		"exists":   (*interp).fsExists,
+		"mkdir":    (*interp).fsMkdir,
+		"remove":   (*interp).fsRemove,
+		"stat":     (*interp).fsStat,
+		"joinpath": (*interp).fsJoinPath,
+		"basename": pathFunc("fs.basename", filepath.Base),
+		"ext":      pathFunc("fs.ext", filepath.Ext),
+	})
+}
+
+// pathArg returns the path that is the only argument in args to the
+// function fn, which uses the filesystem, and whether it may be called.
+func (interp *interp) pathArg(fn string, args []value) (string, bool) {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("%v expects a path", fn)
+		return "", false
+	}
+	if !interp.allowed(fn) {
+		return "", false
+	}
+	return args[0].v.(string), true
+}
+
+// fsListDir returns an array of the names of the entries in a directory,
+// sorted by name.
+func (interp *interp) fsListDir(args []value) value {
+	name, ok := interp.pathArg("fs.listdir", args)
+	if !ok {
+		return value{}
+	}
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return interp.failure(err)
+	}
+	r := newArray()
+	for i, e := range entries {
+		r.set(value{typ: vnum, v: i}, value{typ: vstring, v: e.Name()})
+	}
+	return r
+}
+
+// fsExists reports whether a file or directory exists.
+func (interp *interp) fsExists(args []value) value {
+	name, ok := interp.pathArg("fs.exists", args)
+	if !ok {
+		return value{}
+	}
+	_, err := os.Stat(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return interp.failure(err)
+	}
+	return value{typ: vbool, v: err == nil}
+}
+
+// fsMkdir creates a directory, along with any parents it needs. It does
+// nothing if the directory already exists.
+func (interp *interp) fsMkdir(args []value) value {
+	name, ok := interp.pathArg("fs.mkdir", args)
+	if !ok {
+		return value{}
+	}
+	if err := os.MkdirAll(name, 0777); err != nil {
+		return interp.failure(err)
+	}
+	return value{typ: vnil}
+}
+
+// fsRemove removes a file or an empty directory.
+func (interp *interp) fsRemove(args []value) value {
+	name, ok := interp.pathArg("fs.remove", args)
+	if !ok {
+		return value{}
+	}
+	if err := os.Remove(name); err != nil {
+		return interp.failure(err)
+	}
+	return value{typ: vnil}
+}
+
+// fsStat returns a map describing a file, with the keys "name", "size" in
+// bytes, "mode" as a string like "-rw-r--r--", "modtime" in seconds since
+// the Unix epoch, and "isdir".
+func (interp *interp) fsStat(args []value) value {
+	name, ok := interp.pathArg("fs.stat", args)
+	if !ok {
+		return value{}
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return interp.failure(err)
+	}
+	m := newHashMap()
+	for _, e := range []struct {
+		k string
+		v value
+	}{
+		{"name", value{typ: vstring, v: fi.Name()}},
+		{"size", value{typ: vnum, v: int(fi.Size())}},
+		{"mode", value{typ: vstring, v: fi.Mode().String()}},
+		{"modtime", value{typ: vnum, v: int(fi.ModTime().Unix())}},
+		{"isdir", value{typ: vbool, v: fi.IsDir()}},
+	} {
+		interp.mapSet(m, value{typ: vstring, v: e.k}, e.v)
+	}
+	return value{typ: vmap, v: m}
+}
+
+// fsJoinPath joins any number of path elements with the separator of the
+// operating system, cleaning the result.
+func (interp *interp) fsJoinPath(args []value) value {
+	elems := make([]string, len(args))
+	for i, a := range args {
+		if a.typ != vstring {
+			interp.err = fmt.Errorf("fs.joinpath expects strings")
+			return value{}
+		}
+		elems[i] = a.v.(string)
+	}
+	return value{typ: vstring, v: filepath.Join(elems...)}
+}
+
+// pathFunc returns a builtin named name that applies f to a path. It
+// doesn't use the filesystem.
+func pathFunc(name string, f func(string) string) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) != 1 || args[0].typ != vstring {
+			interp.err = fmt.Errorf("%v expects a path", name)
+			return value{}
+		}
+		return value{typ: vstring, v: f(args[0].v.(string))}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pkgDir is where refgc get puts the packages it fetches. An import path
+// whose first element contains a dot, such as github.com/user/lib/strings,
+// is looked up there after the project root and the search path.
+var pkgDir string
+
+func defaultPkgDir() string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".refgc", "pkg")
+}
+
+// isPackagePath reports whether path names a fetched package rather than
+// a file in the project or on the search path.
+func isPackagePath(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return strings.Contains(first, ".")
+}
+
+// getMain fetches each package named in args, which are repository paths
+// such as github.com/user/lib, optionally followed by @ and a tag or
+// branch. A package is cloned with git into the directory named by its
+// path under pkgDir, replacing any version fetched before.
+func getMain(args []string) {
+	if len(args) == 0 {
+		exitf("usage: refgc get package[@version]...\n")
+	}
+	if pkgDir == "" {
+		exitf("refgc get: no package directory; set -pkgdir\n")
+	}
+	for _, arg := range args {
+		path, version, _ := strings.Cut(arg, "@")
+		path = strings.TrimSuffix(strings.TrimPrefix(path, "https://"), ".git")
+		if !isPackagePath(path) || strings.Contains(path, "..") || strings.Count(path, "/") < 1 {
+			exitf("refgc get: invalid package path %q\n", arg)
+		}
+		if err := fetch(path, version); err != nil {
+			exitf("refgc get %v: %v\n", arg, err)
+		}
+		fmt.Printf("fetched %v into %v\n", arg, filepath.Join(pkgDir, filepath.FromSlash(path)))
+	}
+}
+
+// fetch clones the repository at path into a temporary directory, and
+// then moves it into place, so that a failed fetch leaves the previous
+// version intact.
+func fetch(path, version string) error {
+	dir := filepath.Join(pkgDir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if version != "" {
+		args = append(args, "--branch", version)
+	}
+	args = append(args, "https://"+path, tmp)
+	cmd := exec.Command("git", args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
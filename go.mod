@@ -1,3 +1,3 @@
 module github.com/smasher164/refgc
 
-go 1.13
+go 1.24
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A production is a rule of the grammar in EBNF, as in the Go
+// specification: | separates alternatives, () groups, [] is optional, and
+// {} repeats zero or more times.
+type production struct {
+	name string
+	rule string
+}
+
+// grammar describes the syntax accepted by the parser. It must be kept up
+// to date with the parser, which quotes it in some error messages.
+var grammar = []production{
+	{"File", `{ TopLevelStmt }`},
+	{"TopLevelStmt", `ExportStmt | WhenStmt | Statement`},
+	{"ExportStmt", `"export" ( Assignment | LetStmt | FuncDecl | ClassDecl )`},
+	{"WhenStmt", `"when" Expression Block [ "else" ( WhenStmt | Block ";" ) ] [ ";" ]`},
+	{"Block", `"{" { Statement } "}"`},
+	{"Statement", `Block ";" | FuncDecl | ClassDecl | LetStmt | IfStmt | TryStmt | ThrowStmt | ";" | WhileStmt | ForStmt | ReturnStmt | ImportStmt | WhenStmt | SimpleStmt`},
+	{"FuncDecl", `"func" ident Signature Block ";"`},
+	{"ClassDecl", `"class" ident [ ":" Expression ] "{" { Method | ";" } "}" ";"`},
+	{"Method", `ident Signature Block`},
+	{"IfStmt", `"if" Expression Block ( "else" ( IfStmt | Block ";" ) | ";" )`},
+	{"LetStmt", `"let" ident { "," ident } [ "=" ExpressionList ] ";"`},
+	{"TryStmt", `"try" Block ( "catch" [ ident ] Block [ "finally" Block ] | "finally" Block ) ";"`},
+	{"ThrowStmt", `"throw" Expression ";"`},
+	{"WhileStmt", `"while" Expression Block`},
+	{"ForStmt", `"for" ident [ "," ident ] "in" Expression Block`},
+	{"ReturnStmt", `"return" [ ExpressionList ] ";"`},
+	{"ImportStmt", `"import" [ ident ] string ";"`},
+	{"SimpleStmt", `Expression ";" | Assignment | MultiAssign`},
+	{"Assignment", `Expression ( "=" | "+=" | "-=" | "*=" | "/=" | "%=" ) Expression ";"`},
+	{"MultiAssign", `ExpressionList "=" ExpressionList ";"`},
+	{"ExpressionList", `Expression { "," Expression }`},
+	{"Expression", `UnaryExpr | Expression binary_op Expression`},
+	{"binary_op", `"??" | "||" | "&&" | rel_op | "+" | "-" | "*" | "/" | "%" | "**"`},
+	{"rel_op", `"==" | "!=" | "<" | "<=" | ">" | ">=" | "is" | "as"`},
+	{"UnaryExpr", `PrimaryExpr | ( "+" | "-" | "!" ) UnaryExpr`},
+	{"PrimaryExpr", `Operand | PrimaryExpr ( Selector | Index | Arguments )`},
+	{"Selector", `"." ident`},
+	{"Index", `"[" Expression "]"`},
+	{"Arguments", `"(" [ Argument { "," Argument } [ "," ] ] ")"`},
+	{"Argument", `[ ident ":" ] Expression`},
+	{"Operand", `ident | number | string | "(" Expression ")" | ArrayLit | FuncLit | StructLit | InterfaceLit | MapLit | SetLit`},
+	{"ArrayLit", `"[" [ Element { "," Element } [ "," ] ] "]"`},
+	{"Element", `[ Expression ":" ] Expression`},
+	{"FuncLit", `"func" Signature Block | ( ident | Signature ) "=>" Expression`},
+	{"Signature", `"(" [ ident { "," ident } [ "," ] ] ")"`},
+	{"MapLit", `"{" [ Expression ":" Expression { "," Expression ":" Expression } [ "," ] ] "}"`},
+	{"SetLit", `"#" "{" [ Expression { "," Expression } [ "," ] ] "}"`},
+	{"StructLit", `"struct" "{" [ ident { "," ident } [ "," ] ] "}"`},
+	{"InterfaceLit", `"interface" "{" [ ident { "," ident } [ "," ] ] "}"`},
+}
+
+// grammarRule returns the production named name, formatted as EBNF.
+func grammarRule(name string) string {
+	for _, p := range grammar {
+		if p.name == name {
+			return fmt.Sprintf("%v = %v .", p.name, p.rule)
+		}
+	}
+	panic("no production named " + name)
+}
+
+// grammarMain prints the grammar, or only the productions named in args.
+func grammarMain(args []string) {
+	if len(args) == 0 {
+		for _, p := range grammar {
+			fmt.Println(grammarRule(p.name))
+		}
+		return
+	}
+	var missing []string
+	for _, name := range args {
+		found := false
+		for _, p := range grammar {
+			if p.name == name {
+				fmt.Println(grammarRule(name))
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	if missing != nil {
+		fmt.Fprintf(os.Stderr, "no production named %v\n", strings.Join(missing, ", "))
+		os.Exit(1)
+	}
+}
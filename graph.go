@@ -0,0 +1,251 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+func init() {
+	nativeModule("graph", map[string]builtin{
+		"topo_sort":     (*interp).graphTopoSort,
+		"shortest_path": (*interp).graphShortestPath,
+		"components":    (*interp).graphComponents,
+	})
+}
+
+// A graph is a directed graph built from an array of edges. Vertices are
+// numbered in the order they first appear.
+type graph struct {
+	verts []value
+	index map[string]int // by elem()
+	adj   [][]arc
+}
+
+type arc struct {
+	to, weight int
+}
+
+func (g *graph) vertex(v value) int {
+	k := v.elem()
+	if i, ok := g.index[k]; ok {
+		return i
+	}
+	g.index[k] = len(g.verts)
+	g.verts = append(g.verts, v)
+	g.adj = append(g.adj, nil)
+	return len(g.verts) - 1
+}
+
+// newGraph builds a graph from an array of edges, each an array of the
+// form [from, to] or [from, to, weight]. The default weight is 1.
+func newGraph(edges value) (*graph, error) {
+	if edges.typ != varray {
+		return nil, fmt.Errorf("edges must be an array")
+	}
+	g := &graph{index: make(map[string]int)}
+	for _, e := range edges.entries() {
+		ends := e.v.entries()
+		if e.v.typ != varray || len(ends) != 2 && len(ends) != 3 {
+			return nil, fmt.Errorf("invalid edge %v", e.v.elem())
+		}
+		from, to := g.vertex(ends[0].v), g.vertex(ends[1].v)
+		w := 1
+		if len(ends) == 3 {
+			if ends[2].v.typ != vnum || ends[2].v.v.(int) < 0 {
+				return nil, fmt.Errorf("edge %v has an invalid weight", e.v.elem())
+			}
+			w = ends[2].v.v.(int)
+		}
+		g.adj[from] = append(g.adj[from], arc{to, w})
+	}
+	return g, nil
+}
+
+func (g *graph) list(vs []int) value {
+	r := newArray()
+	for i, v := range vs {
+		r.set(value{typ: vnum, v: i}, g.verts[v])
+	}
+	return r
+}
+
+// topoSort returns the vertices ordered so that every edge goes from an
+// earlier vertex to a later one, or ok is false if there is a cycle.
+func (g *graph) topoSort() (order []int, ok bool) {
+	indeg := make([]int, len(g.verts))
+	for _, arcs := range g.adj {
+		for _, a := range arcs {
+			indeg[a.to]++
+		}
+	}
+	var queue []int
+	for v, d := range indeg {
+		if d == 0 {
+			queue = append(queue, v)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		order = append(order, v)
+		for _, a := range g.adj[v] {
+			if indeg[a.to]--; indeg[a.to] == 0 {
+				queue = append(queue, a.to)
+			}
+		}
+	}
+	return order, len(order) == len(g.verts)
+}
+
+// distQueue is a priority queue of vertices ordered by distance.
+type distQueue struct {
+	verts []int
+	dist  []int
+}
+
+func (q *distQueue) Len() int           { return len(q.verts) }
+func (q *distQueue) Less(i, j int) bool { return q.dist[q.verts[i]] < q.dist[q.verts[j]] }
+func (q *distQueue) Swap(i, j int)      { q.verts[i], q.verts[j] = q.verts[j], q.verts[i] }
+func (q *distQueue) Push(x interface{}) { q.verts = append(q.verts, x.(int)) }
+func (q *distQueue) Pop() interface{} {
+	v := q.verts[len(q.verts)-1]
+	q.verts = q.verts[:len(q.verts)-1]
+	return v
+}
+
+// shortestPath returns the lightest path from src to dst using Dijkstra's
+// algorithm, or nil if there is none.
+func (g *graph) shortestPath(src, dst int) []int {
+	dist := make([]int, len(g.verts))
+	prev := make([]int, len(g.verts))
+	for i := range dist {
+		dist[i], prev[i] = -1, -1
+	}
+	dist[src] = 0
+	q := &distQueue{verts: []int{src}, dist: dist}
+	done := make([]bool, len(g.verts))
+	for q.Len() > 0 {
+		v := heap.Pop(q).(int)
+		if done[v] {
+			continue
+		}
+		done[v] = true
+		if v == dst {
+			break
+		}
+		for _, a := range g.adj[v] {
+			if d := dist[v] + a.weight; dist[a.to] < 0 || d < dist[a.to] {
+				dist[a.to], prev[a.to] = d, v
+				heap.Push(q, a.to)
+			}
+		}
+	}
+	if dist[dst] < 0 {
+		return nil
+	}
+	var path []int
+	for v := dst; v != -1; v = prev[v] {
+		path = append([]int{v}, path...)
+	}
+	return path
+}
+
+// components returns the sets of vertices connected to each other,
+// ignoring the direction of edges.
+func (g *graph) components() [][]int {
+	undirected := make([][]int, len(g.verts))
+	for v, arcs := range g.adj {
+		for _, a := range arcs {
+			undirected[v] = append(undirected[v], a.to)
+			undirected[a.to] = append(undirected[a.to], v)
+		}
+	}
+	seen := make([]bool, len(g.verts))
+	var comps [][]int
+	for v := range g.verts {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		comp := []int{v}
+		for i := 0; i < len(comp); i++ {
+			for _, w := range undirected[comp[i]] {
+				if !seen[w] {
+					seen[w] = true
+					comp = append(comp, w)
+				}
+			}
+		}
+		comps = append(comps, comp)
+	}
+	return comps
+}
+
+func (interp *interp) graphArg(fn string, edges value) *graph {
+	g, err := newGraph(edges)
+	if err != nil {
+		interp.err = fmt.Errorf("%v: %v", fn, err)
+		return nil
+	}
+	return g
+}
+
+// graphTopoSort returns the vertices of a graph in an order in which each
+// edge's source comes before its destination.
+func (interp *interp) graphTopoSort(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("graph.topo_sort expects an array of edges")
+		return value{}
+	}
+	g := interp.graphArg("graph.topo_sort", args[0])
+	if g == nil {
+		return value{}
+	}
+	order, ok := g.topoSort()
+	if !ok {
+		interp.err = fmt.Errorf("graph.topo_sort: graph has a cycle")
+		return value{}
+	}
+	return g.list(order)
+}
+
+// graphShortestPath returns the vertices on the lightest path between two
+// vertices, or nil if there is none.
+func (interp *interp) graphShortestPath(args []value) value {
+	if len(args) != 3 {
+		interp.err = fmt.Errorf("graph.shortest_path expects an array of edges and two vertices")
+		return value{}
+	}
+	g := interp.graphArg("graph.shortest_path", args[0])
+	if g == nil {
+		return value{}
+	}
+	src, ok1 := g.index[args[1].elem()]
+	dst, ok2 := g.index[args[2].elem()]
+	if !ok1 || !ok2 {
+		return value{typ: vnil}
+	}
+	path := g.shortestPath(src, dst)
+	if path == nil {
+		return value{typ: vnil}
+	}
+	return g.list(path)
+}
+
+// graphComponents returns an array of the connected components of a
+// graph, each an array of vertices.
+func (interp *interp) graphComponents(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("graph.components expects an array of edges")
+		return value{}
+	}
+	g := interp.graphArg("graph.components", args[0])
+	if g == nil {
+		return value{}
+	}
+	r := newArray()
+	for i, c := range g.components() {
+		r.set(value{typ: vnum, v: i}, g.list(c))
+	}
+	return r
+}
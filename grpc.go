@@ -0,0 +1,643 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The grpc module makes unary gRPC calls described by a protobuf
+// descriptor set, as written by protoc --descriptor_set_out
+// --include_imports. Messages are arrays keyed by field name. Repeated
+// fields are arrays indexed from 0, map fields are arrays, bytes fields are
+// strings, enums are numbers, and float and double fields are floats.
+
+func init() {
+	nativeModule("grpc", map[string]builtin{
+		"load": (*interp).grpcLoad,
+		"dial": (*interp).grpcDial,
+		"call": (*interp).grpcCall,
+	})
+}
+
+// Field types and labels from google/protobuf/descriptor.proto.
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+
+	protoRepeated = 3
+)
+
+// Wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protoField struct {
+	name     string
+	number   int
+	typ      int
+	repeated bool
+	typeName string // fully qualified, for messages and enums
+}
+
+type protoMsg struct {
+	name     string
+	fields   []*protoField
+	byNumber map[int]*protoField
+	mapEntry bool
+}
+
+type protoMethod struct {
+	in, out string
+}
+
+// protoRegistry holds the messages and services loaded with grpc.load.
+type protoRegistry struct {
+	msgs     map[string]*protoMsg
+	services map[string]map[string]protoMethod
+}
+
+// A protobuf is a buffer of protobuf wire-format data.
+type protobuf struct {
+	b []byte
+}
+
+var errProtoTruncated = errors.New("truncated protobuf")
+
+func (p *protobuf) varint() (uint64, error) {
+	x, n := binary.Uvarint(p.b)
+	if n <= 0 {
+		return 0, errProtoTruncated
+	}
+	p.b = p.b[n:]
+	return x, nil
+}
+
+func (p *protobuf) fixed(n int) (uint64, error) {
+	if len(p.b) < n {
+		return 0, errProtoTruncated
+	}
+	var x uint64
+	if n == 4 {
+		x = uint64(binary.LittleEndian.Uint32(p.b))
+	} else {
+		x = binary.LittleEndian.Uint64(p.b)
+	}
+	p.b = p.b[n:]
+	return x, nil
+}
+
+func (p *protobuf) bytes() ([]byte, error) {
+	n, err := p.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(p.b)) < n {
+		return nil, errProtoTruncated
+	}
+	b := p.b[:n]
+	p.b = p.b[n:]
+	return b, nil
+}
+
+// next returns the next field in p. For wireBytes, the contents are
+// returned in b; otherwise the value is returned in x.
+func (p *protobuf) next() (num, wire int, x uint64, b []byte, err error) {
+	tag, err := p.varint()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	num, wire = int(tag>>3), int(tag&7)
+	switch wire {
+	case wireVarint:
+		x, err = p.varint()
+	case wireFixed64:
+		x, err = p.fixed(8)
+	case wireFixed32:
+		x, err = p.fixed(4)
+	case wireBytes:
+		b, err = p.bytes()
+	default:
+		err = fmt.Errorf("unsupported wire type %v", wire)
+	}
+	return
+}
+
+func appendTag(b []byte, num, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(wire))
+}
+
+func appendBytes(b []byte, num int, data []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// loadDescriptorSet adds the messages and services in the serialized
+// FileDescriptorSet data to r.
+func (r *protoRegistry) loadDescriptorSet(data []byte) error {
+	p := &protobuf{data}
+	for len(p.b) > 0 {
+		num, _, _, file, err := p.next()
+		if err != nil {
+			return err
+		}
+		if num == 1 {
+			if err := r.loadFile(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *protoRegistry) loadFile(data []byte) error {
+	var pkg string
+	var msgs, services [][]byte
+	p := &protobuf{data}
+	for len(p.b) > 0 {
+		num, _, _, b, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch num {
+		case 2:
+			pkg = string(b)
+		case 4:
+			msgs = append(msgs, b)
+		case 6:
+			services = append(services, b)
+		}
+	}
+	prefix := ""
+	if pkg != "" {
+		prefix = "." + pkg
+	}
+	for _, m := range msgs {
+		if err := r.loadMsg(prefix, m); err != nil {
+			return err
+		}
+	}
+	for _, s := range services {
+		if err := r.loadService(pkg, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *protoRegistry) loadMsg(prefix string, data []byte) error {
+	m := &protoMsg{byNumber: make(map[int]*protoField)}
+	var nested [][]byte
+	p := &protobuf{data}
+	for len(p.b) > 0 {
+		num, _, _, b, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch num {
+		case 1:
+			m.name = prefix + "." + string(b)
+		case 2:
+			f, err := loadField(b)
+			if err != nil {
+				return err
+			}
+			m.fields = append(m.fields, f)
+			m.byNumber[f.number] = f
+		case 3:
+			nested = append(nested, b)
+		case 7:
+			opts := &protobuf{b}
+			for len(opts.b) > 0 {
+				num, _, x, _, err := opts.next()
+				if err != nil {
+					return err
+				}
+				if num == 7 {
+					m.mapEntry = x != 0
+				}
+			}
+		}
+	}
+	r.msgs[m.name] = m
+	for _, n := range nested {
+		if err := r.loadMsg(m.name, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadField(data []byte) (*protoField, error) {
+	f := new(protoField)
+	p := &protobuf{data}
+	for len(p.b) > 0 {
+		num, _, x, b, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch num {
+		case 1:
+			f.name = string(b)
+		case 3:
+			f.number = int(x)
+		case 4:
+			f.repeated = x == protoRepeated
+		case 5:
+			f.typ = int(x)
+		case 6:
+			f.typeName = string(b)
+		}
+	}
+	return f, nil
+}
+
+func (r *protoRegistry) loadService(pkg string, data []byte) error {
+	var name string
+	methods := make(map[string]protoMethod)
+	p := &protobuf{data}
+	for len(p.b) > 0 {
+		num, _, _, b, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch num {
+		case 1:
+			name = string(b)
+		case 2:
+			var mname string
+			var m protoMethod
+			mp := &protobuf{b}
+			for len(mp.b) > 0 {
+				num, _, _, b, err := mp.next()
+				if err != nil {
+					return err
+				}
+				switch num {
+				case 1:
+					mname = string(b)
+				case 2:
+					m.in = string(b)
+				case 3:
+					m.out = string(b)
+				}
+			}
+			methods[mname] = m
+		}
+	}
+	if pkg != "" {
+		name = pkg + "." + name
+	}
+	r.services[name] = methods
+	return nil
+}
+
+// encode appends the protobuf encoding of v as a message of type m.
+func (r *protoRegistry) encode(b []byte, m *protoMsg, v value) ([]byte, error) {
+	if v.typ != varray {
+		return nil, fmt.Errorf("cannot encode %v as %v", v.typ, m.name[1:])
+	}
+	for _, f := range m.fields {
+		fv := v.get(value{typ: vstring, v: f.name})
+		if fv.typ == vnil {
+			continue
+		}
+		var err error
+		if !f.repeated {
+			b, err = r.encodeField(b, f, fv)
+		} else if fv.typ != varray {
+			err = fmt.Errorf("field %v must be an array", f.name)
+		} else if entry := r.msgs[f.typeName]; entry != nil && entry.mapEntry {
+			for _, e := range fv.entries() {
+				kv := newArray()
+				kv.set(value{typ: vstring, v: "key"}, e.k)
+				kv.set(value{typ: vstring, v: "value"}, e.v)
+				if b, err = r.encodeField(b, f, kv); err != nil {
+					break
+				}
+			}
+		} else {
+			for _, e := range fv.entries() {
+				if b, err = r.encodeField(b, f, e.v); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (r *protoRegistry) encodeField(b []byte, f *protoField, v value) ([]byte, error) {
+	switch f.typ {
+	case protoString, protoBytes:
+		if v.typ != vstring {
+			return nil, fmt.Errorf("field %v must be a string", f.name)
+		}
+		return appendBytes(b, f.number, []byte(v.v.(string))), nil
+	case protoMessage:
+		m := r.msgs[f.typeName]
+		if m == nil {
+			return nil, fmt.Errorf("unknown message type %v", f.typeName)
+		}
+		data, err := r.encode(nil, m, v)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(b, f.number, data), nil
+	case protoBool:
+		if v.typ != vbool {
+			return nil, fmt.Errorf("field %v must be a bool", f.name)
+		}
+		x := uint64(0)
+		if v.v.(bool) {
+			x = 1
+		}
+		return binary.AppendUvarint(appendTag(b, f.number, wireVarint), x), nil
+	case protoDouble, protoFloat:
+		if v.typ != vfloat && v.typ != vnum {
+			return nil, fmt.Errorf("field %v must be a float", f.name)
+		}
+		if f.typ == protoFloat {
+			return binary.LittleEndian.AppendUint32(appendTag(b, f.number, wireFixed32), math.Float32bits(float32(toFloat(v)))), nil
+		}
+		return binary.LittleEndian.AppendUint64(appendTag(b, f.number, wireFixed64), math.Float64bits(toFloat(v))), nil
+	}
+	if v.typ != vnum {
+		return nil, fmt.Errorf("field %v must be a number", f.name)
+	}
+	n := v.v.(int)
+	switch f.typ {
+	case protoInt64, protoUint64, protoInt32, protoUint32, protoEnum:
+		return binary.AppendUvarint(appendTag(b, f.number, wireVarint), uint64(n)), nil
+	case protoSint32, protoSint64:
+		return binary.AppendVarint(appendTag(b, f.number, wireVarint), int64(n)), nil
+	case protoFixed64, protoSfixed64:
+		return binary.LittleEndian.AppendUint64(appendTag(b, f.number, wireFixed64), uint64(n)), nil
+	case protoFixed32, protoSfixed32:
+		return binary.LittleEndian.AppendUint32(appendTag(b, f.number, wireFixed32), uint32(n)), nil
+	}
+	return nil, fmt.Errorf("field %v has unsupported type %v", f.name, f.typ)
+}
+
+// decode returns the message of type m encoded in data.
+func (r *protoRegistry) decode(m *protoMsg, data []byte) (value, error) {
+	v := newArray()
+	p := &protobuf{data}
+	for len(p.b) > 0 {
+		num, wire, x, b, err := p.next()
+		if err != nil {
+			return value{}, err
+		}
+		f := m.byNumber[num]
+		if f == nil {
+			continue
+		}
+		key := value{typ: vstring, v: f.name}
+		var elems []value
+		if wire == wireBytes && f.typ != protoString && f.typ != protoBytes && f.typ != protoMessage {
+			// Packed repeated scalars.
+			pp := &protobuf{b}
+			for len(pp.b) > 0 {
+				var x uint64
+				switch f.typ {
+				case protoDouble, protoFixed64, protoSfixed64:
+					x, err = pp.fixed(8)
+				case protoFloat, protoFixed32, protoSfixed32:
+					x, err = pp.fixed(4)
+				default:
+					x, err = pp.varint()
+				}
+				if err != nil {
+					return value{}, err
+				}
+				elems = append(elems, decodeScalar(f, x))
+			}
+		} else {
+			e, err := r.decodeField(f, x, b)
+			if err != nil {
+				return value{}, err
+			}
+			elems = append(elems, e)
+		}
+		if !f.repeated {
+			v.set(key, elems[len(elems)-1])
+			continue
+		}
+		list := v.get(key)
+		if list.typ != varray {
+			list = newArray()
+		}
+		for _, e := range elems {
+			if entry := r.msgs[f.typeName]; entry != nil && entry.mapEntry {
+				list.set(e.get(value{typ: vstring, v: "key"}), e.get(value{typ: vstring, v: "value"}))
+			} else {
+				list.set(value{typ: vnum, v: len(list.entries())}, e)
+			}
+		}
+		v.set(key, list)
+	}
+	return v, nil
+}
+
+func (r *protoRegistry) decodeField(f *protoField, x uint64, b []byte) (value, error) {
+	switch f.typ {
+	case protoString, protoBytes:
+		return value{typ: vstring, v: string(b)}, nil
+	case protoMessage:
+		m := r.msgs[f.typeName]
+		if m == nil {
+			return value{}, fmt.Errorf("unknown message type %v", f.typeName)
+		}
+		return r.decode(m, b)
+	}
+	return decodeScalar(f, x), nil
+}
+
+func decodeScalar(f *protoField, x uint64) value {
+	switch f.typ {
+	case protoBool:
+		return value{typ: vbool, v: x != 0}
+	case protoInt32, protoEnum:
+		return value{typ: vnum, v: int(int32(x))}
+	case protoSint32, protoSint64:
+		return value{typ: vnum, v: int(int64(x>>1) ^ -int64(x&1))}
+	case protoSfixed32:
+		return value{typ: vnum, v: int(int32(x))}
+	case protoDouble:
+		return value{typ: vfloat, v: math.Float64frombits(x)}
+	case protoFloat:
+		return value{typ: vfloat, v: float64(math.Float32frombits(uint32(x)))}
+	}
+	return value{typ: vnum, v: int(x)}
+}
+
+// A grpcConn is a connection returned by grpc.dial.
+type grpcConn struct {
+	base   string
+	client *http.Client
+}
+
+func (interp *interp) grpcLoad(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("grpc.load expects the name of a descriptor set file")
+		return value{}
+	}
+	if !interp.allowed("grpc.load") {
+		return value{}
+	}
+	data, err := ioutil.ReadFile(args[0].v.(string))
+	if err != nil {
+		return interp.failure(err)
+	}
+	if interp.protos == nil {
+		interp.protos = &protoRegistry{
+			msgs:     make(map[string]*protoMsg),
+			services: make(map[string]map[string]protoMethod),
+		}
+	}
+	if err := interp.protos.loadDescriptorSet(data); err != nil {
+		return interp.failure(fmt.Errorf("grpc.load: %v", err))
+	}
+	return value{}
+}
+
+// grpcDial returns a connection to the server at addr. Its optional second
+// argument is an array of options; if "tls" is true, the connection is
+// made over TLS.
+func (interp *interp) grpcDial(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("grpc.dial expects an address and optional options")
+		return value{}
+	}
+	if !interp.allowed("grpc.dial") {
+		return value{}
+	}
+	c := &grpcConn{base: "http://" + args[0].v.(string), client: new(http.Client)}
+	if len(args) == 2 && interp.isTrue(args[1].get(value{typ: vstring, v: "tls"})) {
+		c.base = "https://" + args[0].v.(string)
+		c.client.Transport = &http.Transport{ForceAttemptHTTP2: true}
+	} else {
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		c.client.Transport = &http.Transport{Protocols: &protocols}
+	}
+	return value{typ: vhandle, v: c}
+}
+
+// grpcCall makes a unary call: grpc.call(conn, service, method, request).
+// If the call fails, or its response can't be decoded, the result is an
+// error value.
+func (interp *interp) grpcCall(args []value) value {
+	if len(args) != 4 || args[0].typ != vhandle || args[1].typ != vstring || args[2].typ != vstring {
+		interp.err = fmt.Errorf("grpc.call expects a connection, a service, a method, and a request")
+		return value{}
+	}
+	c, ok := args[0].v.(*grpcConn)
+	if !ok {
+		interp.err = fmt.Errorf("grpc.call expects a connection returned by grpc.dial")
+		return value{}
+	}
+	service, method := args[1].v.(string), args[2].v.(string)
+	if interp.protos == nil || interp.protos.services[service] == nil {
+		interp.err = fmt.Errorf("grpc.call: unknown service %v", service)
+		return value{}
+	}
+	md, ok := interp.protos.services[service][method]
+	if !ok {
+		interp.err = fmt.Errorf("grpc.call: service %v has no method %v", service, method)
+		return value{}
+	}
+	in, out := interp.protos.msgs[md.in], interp.protos.msgs[md.out]
+	if in == nil || out == nil {
+		interp.err = fmt.Errorf("grpc.call: missing message types for %v.%v", service, method)
+		return value{}
+	}
+	msg, err := interp.protos.encode(nil, in, args[3])
+	if err != nil {
+		interp.err = fmt.Errorf("grpc.call: %v", err)
+		return value{}
+	}
+	resp, err := c.invoke(service, method, msg)
+	if err != nil {
+		return interp.failure(fmt.Errorf("grpc.call: %v", err))
+	}
+	v, err := interp.protos.decode(out, resp)
+	if err != nil {
+		return interp.failure(fmt.Errorf("grpc.call: %v", err))
+	}
+	return v
+}
+
+// invoke sends the serialized request msg and returns the serialized
+// response.
+func (c *grpcConn) invoke(service, method string, msg []byte) ([]byte, error) {
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+	req, err := http.NewRequest("POST", c.base+"/"+service+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %v", resp.Status)
+	}
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		code, _ := strconv.Atoi(status)
+		return nil, fmt.Errorf("rpc error: code = %v desc = %v", code, strings.TrimSpace(message))
+	}
+	if len(data) < 5 {
+		return nil, errors.New("missing response message")
+	}
+	if data[0] != 0 {
+		return nil, errors.New("compressed responses are not supported")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < n {
+		return nil, errProtoTruncated
+	}
+	return data[5 : 5+n], nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestGRPCCallFailure(t *testing.T) {
+	// A call that fails in transport is an error value, not a failure of
+	// the program.
+	interp := &interp{protos: &protoRegistry{
+		msgs:     map[string]*protoMsg{".Empty": {name: ".Empty"}},
+		services: map[string]map[string]protoMethod{"Svc": {"Get": {in: ".Empty", out: ".Empty"}}},
+	}}
+	c := lookupBuiltin(t, "grpc.dial")(interp, []value{str("127.0.0.1:1")})
+	v := lookupBuiltin(t, "grpc.call")(interp, []value{c, str("Svc"), str("Get"), newArray()})
+	if interp.err != nil || v.typ != verror {
+		t.Errorf("grpc.call to a closed port returned %v with error %v, want an error value", v, interp.err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+func init() {
+	for name, h := range hashes {
+		builtins[name] = func(interp *interp, args []value) value {
+			return interp.hashSum(name, h, args)
+		}
+	}
+	builtins["hmac"] = (*interp).builtinHMAC
+}
+
+// hashes are the names of the hash functions, each of which is a builtin
+// and may be used with hmac. MD5 and SHA-1 are broken, and are only for
+// checksums and interoperating with existing systems.
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hashSum returns the digest of a string or bytes in hexadecimal.
+func (interp *interp) hashSum(fn string, h func() hash.Hash, args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("%v expects a string or bytes", fn)
+		return value{}
+	}
+	b, ok := interp.binaryArg(fn, args)
+	if !ok {
+		return value{}
+	}
+	d := h()
+	d.Write(b)
+	return value{typ: vstring, v: hex.EncodeToString(d.Sum(nil))}
+}
+
+// builtinHMAC returns the HMAC of a message with a key, each a string or
+// bytes, in hexadecimal. The hash function is named by an optional third
+// argument, which defaults to sha256.
+func (interp *interp) builtinHMAC(args []value) value {
+	if len(args) < 2 || len(args) > 3 {
+		interp.err = fmt.Errorf("hmac expects a key, a message, and an optional hash function")
+		return value{}
+	}
+	key, ok := interp.binaryArg("hmac", args[:1])
+	if !ok {
+		return value{}
+	}
+	msg, ok := interp.binaryArg("hmac", args[1:2])
+	if !ok {
+		return value{}
+	}
+	name := "sha256"
+	if len(args) == 3 {
+		if args[2].typ != vstring {
+			interp.err = fmt.Errorf("hmac expects the name of a hash function")
+			return value{}
+		}
+		name = args[2].v.(string)
+	}
+	h, ok := hashes[name]
+	if !ok {
+		interp.err = fmt.Errorf("hmac: unknown hash function %v", name)
+		return value{}
+	}
+	m := hmac.New(h, key)
+	m.Write(msg)
+	return value{typ: vstring, v: hex.EncodeToString(m.Sum(nil))}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A hashMap is the value of a map literal. It keeps its entries in the
+// order their keys were first added, and finds them by the hash keys of
+// their keys. Setting an existing key keeps its position, and removing
+// one keeps the order of the rest, so a key that is removed and added
+// again comes last. Iteration, keys, and printing all use this order, so
+// programs that use maps are deterministic. Like arrays and sorted maps,
+// maps are references: assigning one to another variable doesn't copy it.
+//
+// Removing an entry leaves a hole in entries, with the zero key, which no
+// valid key is, so that it takes constant time. The holes are removed when
+// there are more of them than entries, or when the entries are read in
+// order with items.
+type hashMap struct {
+	entries []struct {
+		k value
+		v value
+	}
+	index  map[string]int // position in entries by hash key
+	frozen bool
+}
+
+func newHashMap() *hashMap {
+	return &hashMap{index: make(map[string]int)}
+}
+
+// hashKey returns a string that is the same for two keys exactly when
+// they are equal. Only nil, numbers, decimals, floats, strings, and bools
+// may be keys, along with objects and classes, which are equal only to
+// themselves.
+func hashKey(k value) (string, bool) {
+	switch k.typ {
+	case vnil:
+		return "nil", true
+	case vnum, vbig, vbool, vstring:
+		return fmt.Sprintf("%d:%v", k.typ, k.v), true
+	case vdecimal:
+		// Decimals equal to a number are still distinct keys from it.
+		return fmt.Sprintf("%d:%v", k.typ, k.v.(*decimal).normalized()), true
+	case vfloat:
+		// So are floats, but 0 and -0 are the same key.
+		f := k.v.(float64)
+		if f == 0 {
+			f = 0
+		}
+		return fmt.Sprintf("%d:%v", k.typ, f), true
+	case vobject, vclass:
+		return fmt.Sprintf("%d:%p", k.typ, k.v), true
+	}
+	return "", false
+}
+
+// put sets the entry of m with the key k, which must be valid, to v. It
+// is for building maps from data rather than in a program.
+func (m *hashMap) put(k, v value) {
+	h, _ := hashKey(k)
+	if i, ok := m.index[h]; ok {
+		m.entries[i].v = v
+		return
+	}
+	m.index[h] = len(m.entries)
+	m.entries = append(m.entries, struct{ k, v value }{k, v})
+}
+
+// lookup returns the value of the entry of m with the key k, which must
+// be valid, and whether there is one.
+func (m *hashMap) lookup(k value) (value, bool) {
+	h, _ := hashKey(k)
+	i, ok := m.index[h]
+	if !ok {
+		return value{}, false
+	}
+	return m.entries[i].v, true
+}
+
+func (interp *interp) mapKey(k value) (string, bool) {
+	h, ok := hashKey(k)
+	if !ok {
+		interp.err = fmt.Errorf("invalid map key type %v", k.typ)
+	}
+	return h, ok
+}
+
+// mapGet returns the value of the entry of m with key k, or nil if there
+// is none.
+func (interp *interp) mapGet(m *hashMap, k value) value {
+	h, ok := interp.mapKey(k)
+	if !ok {
+		return value{}
+	}
+	i, ok := m.index[h]
+	if !ok {
+		return value{typ: vnil}
+	}
+	return m.entries[i].v
+}
+
+func (interp *interp) mapSet(m *hashMap, k, v value) {
+	if m.frozen {
+		interp.err = fmt.Errorf("cannot modify frozen map")
+		return
+	}
+	h, ok := interp.mapKey(k)
+	if !ok {
+		return
+	}
+	if i, ok := m.index[h]; ok {
+		m.entries[i].v = v
+		return
+	}
+	m.index[h] = len(m.entries)
+	m.entries = append(m.entries, struct{ k, v value }{k, v})
+}
+
+// mapDelete removes the entry of m with key k, if there is one, and
+// reports whether there was.
+func (interp *interp) mapDelete(m *hashMap, k value) bool {
+	if m.frozen {
+		interp.err = fmt.Errorf("cannot modify frozen map")
+		return false
+	}
+	h, ok := interp.mapKey(k)
+	if !ok {
+		return false
+	}
+	i, ok := m.index[h]
+	if !ok {
+		return false
+	}
+	delete(m.index, h)
+	m.entries[i] = struct{ k, v value }{}
+	if len(m.entries) > 2*len(m.index) {
+		m.compact()
+	}
+	return true
+}
+
+// items returns the entries of m in order, without the holes left by
+// removing entries.
+func (m *hashMap) items() []struct{ k, v value } {
+	if len(m.entries) != len(m.index) {
+		m.compact()
+	}
+	return m.entries
+}
+
+// compact removes the holes from entries, moving the entries after them
+// back.
+func (m *hashMap) compact() {
+	n := 0
+	for _, e := range m.entries {
+		if e.k.typ == verr {
+			continue
+		}
+		h, _ := hashKey(e.k)
+		m.index[h] = n
+		m.entries[n] = e
+		n++
+	}
+	clear(m.entries[n:])
+	m.entries = m.entries[:n]
+}
+
+// eq reports whether m and o have the same keys, with equal values. The
+// order of their entries doesn't matter. seen is as for value.equal.
+func (m *hashMap) eq(o *hashMap, seen map[visit]bool) bool {
+	if len(m.index) != len(o.index) {
+		return false
+	}
+	for h, i := range m.index {
+		j, ok := o.index[h]
+		if !ok || !m.entries[i].v.equal(o.entries[j].v, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *hashMap) String() string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, e := range m.items() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%s: %s", e.k.elem(), e.v.elem())
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (interp *interp) evalMapLit(nod *node) value {
+	m := newHashMap()
+	for _, e := range nod.list {
+		k := interp.evalRvalue(e.list[0])
+		v := interp.evalRvalue(e.list[1])
+		if interp.err != nil {
+			return value{}
+		}
+		interp.mapSet(m, k, v)
+	}
+	return value{typ: vmap, v: m}
+}
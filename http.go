@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+func init() {
+	builtins["httpget"] = (*interp).builtinHTTPGet
+	builtins["httppost"] = (*interp).builtinHTTPPost
+}
+
+// The HTTP builtins aren't allowed in the sandbox. Each request is limited
+// to the time given by -http-timeout, and one that fails before a response
+// is received, including by timing out, results in an error value. A
+// response with any status is returned as a map with the keys "status", a
+// number, "headers", a map from each header's canonical name to its values
+// joined by commas, sorted by name, and "body", a string.
+
+// builtinHTTPGet makes a GET request to a URL, with an optional map of
+// request headers.
+func (interp *interp) builtinHTTPGet(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring || len(args) == 2 && args[1].typ != vmap {
+		interp.err = fmt.Errorf("httpget expects a URL and an optional map of headers")
+		return value{}
+	}
+	if !interp.allowed("httpget") {
+		return value{}
+	}
+	req, err := http.NewRequest("GET", args[0].v.(string), nil)
+	if err != nil {
+		return interp.failure(fmt.Errorf("httpget: %v", err))
+	}
+	return interp.httpDo("httpget", req, args[1:])
+}
+
+// builtinHTTPPost makes a POST request to a URL with a body, which is a
+// string or bytes, and an optional map of request headers.
+func (interp *interp) builtinHTTPPost(args []value) value {
+	if len(args) < 2 || len(args) > 3 || args[0].typ != vstring || len(args) == 3 && args[2].typ != vmap {
+		interp.err = fmt.Errorf("httppost expects a URL, a body, and an optional map of headers")
+		return value{}
+	}
+	body, ok := interp.binaryArg("httppost", args[1:2])
+	if !ok {
+		return value{}
+	}
+	if !interp.allowed("httppost") {
+		return value{}
+	}
+	req, err := http.NewRequest("POST", args[0].v.(string), strings.NewReader(string(body)))
+	if err != nil {
+		return interp.failure(fmt.Errorf("httppost: %v", err))
+	}
+	return interp.httpDo("httppost", req, args[2:])
+}
+
+// httpDo adds the headers in the map in header, if any, to req, sends it,
+// and returns the response.
+func (interp *interp) httpDo(fn string, req *http.Request, header []value) value {
+	if len(header) == 1 {
+		for _, e := range header[0].v.(*hashMap).items() {
+			if e.k.typ != vstring || e.v.typ != vstring {
+				interp.err = fmt.Errorf("%v expects a map of headers from strings to strings", fn)
+				return value{}
+			}
+			req.Header.Set(e.k.v.(string), e.v.v.(string))
+		}
+	}
+	client := &http.Client{Timeout: interp.httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return interp.failure(fmt.Errorf("%v: %v", fn, err))
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return interp.failure(fmt.Errorf("%v: %v", fn, err))
+	}
+	h := newHashMap()
+	for _, k := range slices.Sorted(maps.Keys(resp.Header)) {
+		h.put(value{typ: vstring, v: k}, value{typ: vstring, v: strings.Join(resp.Header[k], ", ")})
+	}
+	m := newHashMap()
+	m.put(value{typ: vstring, v: "status"}, value{typ: vnum, v: resp.StatusCode})
+	m.put(value{typ: vstring, v: "headers"}, value{typ: vmap, v: h})
+	m.put(value{typ: vstring, v: "body"}, value{typ: vstring, v: string(b)})
+	return value{typ: vmap, v: m}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	nativeModule("i18n", map[string]builtin{
+		"load":   (*interp).i18nLoad,
+		"locale": (*interp).i18nLocale,
+	})
+	builtins["t"] = (*interp).builtinT
+}
+
+// An i18nMessage is a translated string, or its plural forms keyed by CLDR
+// plural category: "zero", "one", "two", "few", "many", and "other".
+type i18nMessage struct {
+	text   string
+	plural map[string]string
+}
+
+// catalogs holds the messages loaded with i18n.load, by locale.
+type catalogs struct {
+	locales map[string]map[string]i18nMessage
+	locale  string // current locale, such as "fr" or "pt-br"
+}
+
+// normLocale converts a locale name such as "pt_BR.UTF-8" to "pt-br".
+func normLocale(s string) string {
+	if i := strings.IndexAny(s, ".@"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.ToLower(strings.ReplaceAll(s, "_", "-"))
+}
+
+// envLocale returns the locale named by the environment, or "en".
+func envLocale() string {
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := normLocale(os.Getenv(k)); v != "" && v != "c" && v != "posix" {
+			return v
+		}
+	}
+	return "en"
+}
+
+// loadCatalog reads a JSON file mapping message keys to strings, or to
+// objects mapping plural categories to strings.
+func loadCatalog(path string) (map[string]i18nMessage, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	msgs := make(map[string]i18nMessage)
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			msgs[k] = i18nMessage{text: v}
+		case map[string]interface{}:
+			m := i18nMessage{plural: make(map[string]string)}
+			for cat, s := range v {
+				str, ok := s.(string)
+				if !ok {
+					return nil, fmt.Errorf("%v: plural form %v of %v is not a string", path, cat, k)
+				}
+				m.plural[cat] = str
+			}
+			msgs[k] = m
+		default:
+			return nil, fmt.Errorf("%v: message %v is not a string or an object", path, k)
+		}
+	}
+	return msgs, nil
+}
+
+// pluralCategory returns the CLDR plural category of the integer n in
+// the language of locale.
+func pluralCategory(locale string, n int) string {
+	lang := locale
+	if i := strings.IndexByte(lang, '-'); i >= 0 {
+		lang = lang[:i]
+	}
+	if n < 0 {
+		n = -n
+	}
+	mod10, mod100 := n%10, n%100
+	switch lang {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "tr":
+		return "other"
+	case "fr", "hi", "fa":
+		if n == 0 || n == 1 {
+			return "one"
+		}
+	case "ru", "uk", "be", "sr", "hr", "bs":
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		}
+		return "many"
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		}
+		return "many"
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case mod100 >= 3 && mod100 <= 10:
+			return "few"
+		case mod100 >= 11:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// lookup returns the message for key in the current locale, falling back
+// to the locale's language and then to English.
+func (c *catalogs) lookup(key string) (i18nMessage, string, bool) {
+	candidates := []string{c.locale}
+	if i := strings.IndexByte(c.locale, '-'); i >= 0 {
+		candidates = append(candidates, c.locale[:i])
+	}
+	candidates = append(candidates, "en")
+	for _, l := range candidates {
+		if m, ok := c.locales[l][key]; ok {
+			return m, l, true
+		}
+	}
+	return i18nMessage{}, "", false
+}
+
+// i18nLoad loads the message catalogs in a directory. Each is a JSON file
+// named for its locale, such as fr.json or pt-BR.json.
+func (interp *interp) i18nLoad(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("i18n.load expects a directory")
+		return value{}
+	}
+	if !interp.allowed("i18n.load") {
+		return value{}
+	}
+	paths, err := filepath.Glob(filepath.Join(args[0].v.(string), "*.json"))
+	if err != nil {
+		return interp.failure(fmt.Errorf("i18n.load: %v", err))
+	}
+	if interp.catalogs == nil {
+		interp.catalogs = &catalogs{locales: make(map[string]map[string]i18nMessage), locale: envLocale()}
+	}
+	for _, p := range paths {
+		msgs, err := loadCatalog(p)
+		if err != nil {
+			return interp.failure(fmt.Errorf("i18n.load: %v", err))
+		}
+		locale := normLocale(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if interp.catalogs.locales[locale] == nil {
+			interp.catalogs.locales[locale] = msgs
+			continue
+		}
+		for k, m := range msgs {
+			interp.catalogs.locales[locale][k] = m
+		}
+	}
+	return value{}
+}
+
+// i18nLocale returns the current locale, and sets it to the optional
+// argument. It is initially taken from the environment.
+func (interp *interp) i18nLocale(args []value) value {
+	if len(args) > 1 || len(args) == 1 && args[0].typ != vstring {
+		interp.err = fmt.Errorf("i18n.locale expects an optional locale")
+		return value{}
+	}
+	if interp.catalogs == nil {
+		interp.catalogs = &catalogs{locales: make(map[string]map[string]i18nMessage), locale: envLocale()}
+	}
+	old := interp.catalogs.locale
+	if len(args) == 1 {
+		interp.catalogs.locale = normLocale(args[0].v.(string))
+	}
+	return value{typ: vstring, v: old}
+}
+
+// builtinT translates a message key. Occurrences of {name} in the message
+// are replaced by the parameter with that name, and if the message has
+// plural forms, the one for the parameter count is chosen. A key without
+// a message translates to itself.
+func (interp *interp) builtinT(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring || len(args) == 2 && args[1].typ != varray {
+		interp.err = fmt.Errorf("t expects a message key and optional parameters")
+		return value{}
+	}
+	key := args[0].v.(string)
+	var params value
+	if len(args) == 2 {
+		params = args[1]
+	}
+	if interp.catalogs == nil {
+		return value{typ: vstring, v: key}
+	}
+	m, locale, ok := interp.catalogs.lookup(key)
+	if !ok {
+		return value{typ: vstring, v: key}
+	}
+	text := m.text
+	if m.plural != nil {
+		count := params.get(value{typ: vstring, v: "count"})
+		if count.typ != vnum {
+			interp.err = fmt.Errorf("t: message %v needs a numeric count parameter", key)
+			return value{}
+		}
+		var ok bool
+		if text, ok = m.plural[pluralCategory(locale, count.v.(int))]; !ok {
+			text = m.plural["other"]
+		}
+	}
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(text, '{')
+		j := strings.IndexByte(text[i+1:], '}')
+		if i < 0 || j < 0 {
+			break
+		}
+		name := text[i+1 : i+1+j]
+		sb.WriteString(text[:i])
+		if v := params.get(value{typ: vstring, v: name}); v.typ != vnil {
+			sb.WriteString(v.String())
+		} else {
+			sb.WriteString(text[i : i+2+j])
+		}
+		text = text[i+2+j:]
+	}
+	sb.WriteString(text)
+	return value{typ: vstring, v: sb.String()}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+func init() {
+	builtins["uuid"] = (*interp).builtinUUID
+	builtins["nanoid"] = (*interp).builtinNanoID
+}
+
+// randomBytes fills b with random bytes, which come from the operating
+// system unless seed has been called, in which case they come from the
+// seeded generator so that a program's IDs repeat.
+func (interp *interp) randomBytes(b []byte) {
+	if !interp.seeded {
+		rand.Read(b)
+		return
+	}
+	for i := range b {
+		b[i] = byte(interp.random().Uint32())
+	}
+}
+
+// builtinUUID returns a random (version 4) UUID as a string like
+// "0b5c4b1e-8c1a-4f0e-9d2b-3e7a6c5d4f21".
+func (interp *interp) builtinUUID(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("uuid expects no arguments")
+		return value{}
+	}
+	var b [16]byte
+	interp.randomBytes(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return value{typ: vstring, v: fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])}
+}
+
+const nanoidAlphabet = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// builtinNanoID returns a random ID of an optional number of characters,
+// 21 by default, from an alphabet of 64 that is safe in URLs.
+func (interp *interp) builtinNanoID(args []value) value {
+	n := 21
+	if len(args) > 1 || len(args) == 1 && (args[0].typ != vnum || args[0].v.(int) <= 0) {
+		interp.err = fmt.Errorf("nanoid expects an optional positive length")
+		return value{}
+	}
+	if len(args) == 1 {
+		n = args[0].v.(int)
+	}
+	b := make([]byte, n)
+	interp.randomBytes(b)
+	for i := range b {
+		b[i] = nanoidAlphabet[b[i]&63]
+	}
+	return value{typ: vstring, v: string(b)}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	builtins["satisfy"] = (*interp).builtinSatisfy
+	builtins["typeof"] = (*interp).builtinTypeOf
+}
+
+// An iface is the value of an interface expression, which names the
+// methods a value must have to satisfy it.
+type iface struct {
+	name    string // the name it was first assigned to, if any
+	methods []string
+}
+
+func (t *iface) String() string {
+	if t.name != "" {
+		return t.name
+	}
+	return "interface {" + strings.Join(t.methods, ", ") + "}"
+}
+
+func (interp *interp) evalInterface(nod *node) value {
+	t := &iface{}
+	for _, m := range nod.list {
+		t.methods = append(t.methods, m.value.text)
+	}
+	return value{typ: vinterface, v: t}
+}
+
+// hasMethod reports whether a selector naming name on v yields a
+// function, without evaluating anything.
+func (interp *interp) hasMethod(v value, name string) bool {
+	var m value
+	switch v.typ {
+	case vobject:
+		o := v.v.(*object)
+		k := value{typ: vstring, v: name}
+		if o.fields.has(k) {
+			m = o.fields.get(k)
+		} else if cm, ok := o.class.method(name); ok {
+			m = cm
+		}
+	case vrecord:
+		r := v.v.(*record)
+		if i := r.typ.fieldIndex(name); i >= 0 {
+			m = r.fields[i]
+		}
+	case vmodule:
+		mod := v.v.(*module)
+		if !mod.exports[name] {
+			return false
+		}
+		m = interp.member(mod, name)
+	case varray:
+		m = v.get(value{typ: vstring, v: name})
+	}
+	return m.typ == vfunc
+}
+
+// missingMethods returns the methods of t that v lacks.
+func (interp *interp) missingMethods(v value, t *iface) []string {
+	var missing []string
+	for _, m := range t.methods {
+		if !interp.hasMethod(v, m) {
+			missing = append(missing, m)
+		}
+	}
+	return missing
+}
+
+// is reports whether v satisfies the interface t, or is an instance of
+// the class or struct type t.
+func (interp *interp) is(v, t value) value {
+	switch t := t.v.(type) {
+	case *iface:
+		return value{typ: vbool, v: len(interp.missingMethods(v, t)) == 0}
+	case *class:
+		if o, ok := v.v.(*object); ok {
+			for c := o.class; c != nil; c = c.parent {
+				if c == t {
+					return value{typ: vbool, v: true}
+				}
+			}
+		}
+		return value{typ: vbool, v: false}
+	case *structType:
+		r, ok := v.v.(*record)
+		return value{typ: vbool, v: ok && r.typ == t}
+	}
+	interp.err = fmt.Errorf("cannot test whether a value is %v", typeName(t.typ))
+	return value{}
+}
+
+// typeNamed returns the type of values named by x, if it is the name of
+// one, like num or array: the vtype without its leading v.
+func typeNamed(x *node) (vtype, bool) {
+	if x.kind != kident {
+		return verr, false
+	}
+	for t := vnil; !strings.HasPrefix(t.String(), "vtype("); t++ {
+		if t != vtuple && typeName(t) == x.value.text {
+			return t, true
+		}
+	}
+	return verr, false
+}
+
+// typeName returns the name of a type of values, as typeof returns it and
+// is tests for it.
+func typeName(t vtype) string {
+	return strings.TrimPrefix(t.String(), "v")
+}
+
+// builtinTypeOf returns the name of the type of a value, such as "num",
+// "string", "bool", "array", "func", or "nil". It is the name that is
+// tests for, so typeof(v) == "num" exactly when v is num. Numbers too
+// large for a num are "big".
+func (interp *interp) builtinTypeOf(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("typeof expects one argument")
+		return value{}
+	}
+	return value{typ: vstring, v: typeName(args[0].typ)}
+}
+
+// evalTypeTest evaluates v is T or v as T. T is either the name of a type
+// of values, which takes precedence over any variable of that name, or an
+// expression evaluating to an interface, class, or struct type. v as T is
+// v if v is T, and otherwise throws an error that can be caught.
+func (interp *interp) evalTypeTest(nod *node) value {
+	v := interp.evalRvalue(nod.list[0])
+	if interp.err != nil {
+		return value{}
+	}
+	var ok bool
+	var want string
+	if t, isName := typeNamed(nod.list[1]); isName {
+		ok, want = v.typ == t, nod.list[1].value.text
+	} else {
+		t := interp.evalRvalue(nod.list[1])
+		if interp.err != nil {
+			return value{}
+		}
+		b := interp.is(v, t)
+		if interp.err != nil {
+			return value{}
+		}
+		ok, want = b.v.(bool), t.String()
+	}
+	if nod.value.ttype == tis {
+		return value{typ: vbool, v: ok}
+	}
+	if !ok {
+		msg := fmt.Sprintf("cannot use %v (%v) as %v", v, typeName(v.typ), want)
+		interp.err = &thrown{&errorValue{msg: msg, pos: nod.pos, value: v}}
+		return value{}
+	}
+	return v
+}
+
+// builtinSatisfy returns its first argument if it satisfies the interface
+// in its second, and otherwise fails, listing the methods it lacks.
+func (interp *interp) builtinSatisfy(args []value) value {
+	if len(args) != 2 || args[1].typ != vinterface {
+		interp.err = fmt.Errorf("satisfy expects a value and an interface")
+		return value{}
+	}
+	t := args[1].v.(*iface)
+	if missing := interp.missingMethods(args[0], t); missing != nil {
+		var what interface{} = typeName(args[0].typ)
+		switch v := args[0].v.(type) {
+		case *object:
+			what = v.class.name
+		case *record:
+			what = v.typ
+		}
+		interp.err = fmt.Errorf("%v does not satisfy %v: missing %v", what, t, strings.Join(missing, ", "))
+		return value{}
+	}
+	return args[0]
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestTypeTestErrors(t *testing.T) {
+	// Errors name types as typeof does.
+	wantOutput(t, `
+		Shape = interface { area };
+		try { satisfy(5, Shape); } catch e { println(e); };
+		try { satisfy("s", Shape); } catch e { println(e); };
+		try { x = 5 is 3; } catch e { println(e); };
+		try { x = 5 as string; } catch e { println(e); };
+	`, "num does not satisfy Shape: missing area\n"+
+		"string does not satisfy Shape: missing area\n"+
+		"cannot test whether a value is num\n"+
+		"cannot use 5 (num) as string\n")
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	nativeModule("image", map[string]builtin{
+		"info":   (*interp).imageInfo,
+		"open":   (*interp).imageOpen,
+		"size":   (*interp).imageSize,
+		"resize": (*interp).imageResize,
+		"crop":   (*interp).imageCrop,
+		"save":   (*interp).imageSave,
+	})
+}
+
+// An img is an image opened by a script, with the format it was decoded
+// from.
+type img struct {
+	m      image.Image
+	format string
+}
+
+func (interp *interp) img(fn string, v value) *img {
+	m, ok := v.v.(*img)
+	if !ok || v.typ != vhandle {
+		interp.err = fmt.Errorf("%v expects an image returned by image.open", fn)
+		return nil
+	}
+	return m
+}
+
+func sizeValue(w, h int) value {
+	r := newArray()
+	r.set(value{typ: vstring, v: "width"}, value{typ: vnum, v: w})
+	r.set(value{typ: vstring, v: "height"}, value{typ: vnum, v: h})
+	return r
+}
+
+// imageInfo returns the width, height, and format of an image file,
+// without decoding all of it.
+func (interp *interp) imageInfo(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("image.info expects a file name")
+		return value{}
+	}
+	if !interp.allowed("image.info") {
+		return value{}
+	}
+	f, err := os.Open(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("image.info: %v", err))
+	}
+	defer f.Close()
+	c, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return interp.failure(fmt.Errorf("image.info: %v: %v", args[0].v.(string), err))
+	}
+	r := sizeValue(c.Width, c.Height)
+	r.set(value{typ: vstring, v: "format"}, value{typ: vstring, v: format})
+	return r
+}
+
+// imageOpen decodes a PNG, JPEG, or GIF file.
+func (interp *interp) imageOpen(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("image.open expects a file name")
+		return value{}
+	}
+	if !interp.allowed("image.open") {
+		return value{}
+	}
+	f, err := os.Open(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("image.open: %v", err))
+	}
+	defer f.Close()
+	m, format, err := image.Decode(f)
+	if err != nil {
+		return interp.failure(fmt.Errorf("image.open: %v: %v", args[0].v.(string), err))
+	}
+	return value{typ: vhandle, v: &img{m: m, format: format}}
+}
+
+func (interp *interp) imageSize(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("image.size expects an image")
+		return value{}
+	}
+	m := interp.img("image.size", args[0])
+	if m == nil {
+		return value{}
+	}
+	b := m.m.Bounds()
+	return sizeValue(b.Dx(), b.Dy())
+}
+
+// resize scales m to w by h pixels with bilinear interpolation.
+func resize(m image.Image, w, h int) image.Image {
+	src := m.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, w, h))
+	// at returns the color of the source pixel nearest x, y.
+	at := func(x, y int) color.RGBA64 {
+		x = min(max(x, 0), src.Dx()-1)
+		y = min(max(y, 0), src.Dy()-1)
+		return color.RGBA64Model.Convert(m.At(src.Min.X+x, src.Min.Y+y)).(color.RGBA64)
+	}
+	lerp := func(a, b uint16, t float64) float64 {
+		return float64(a) + (float64(b)-float64(a))*t
+	}
+	for y := 0; y < h; y++ {
+		// Sample at the centers of the destination pixels.
+		sy := (float64(y)+0.5)*float64(src.Dy())/float64(h) - 0.5
+		y0 := int(sy)
+		if sy < 0 {
+			y0 = -1
+		}
+		ty := sy - float64(y0)
+		for x := 0; x < w; x++ {
+			sx := (float64(x)+0.5)*float64(src.Dx())/float64(w) - 0.5
+			x0 := int(sx)
+			if sx < 0 {
+				x0 = -1
+			}
+			tx := sx - float64(x0)
+			c00, c10 := at(x0, y0), at(x0+1, y0)
+			c01, c11 := at(x0, y0+1), at(x0+1, y0+1)
+			mix := func(a, b, c, d uint16) uint16 {
+				top := lerp(a, b, tx)
+				bot := lerp(c, d, tx)
+				return uint16(top + (bot-top)*ty + 0.5)
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: mix(c00.R, c10.R, c01.R, c11.R),
+				G: mix(c00.G, c10.G, c01.G, c11.G),
+				B: mix(c00.B, c10.B, c01.B, c11.B),
+				A: mix(c00.A, c10.A, c01.A, c11.A),
+			})
+		}
+	}
+	return dst
+}
+
+// imageResize returns a copy of an image scaled to a width and height. If
+// either is 0, it is chosen to preserve the aspect ratio.
+func (interp *interp) imageResize(args []value) value {
+	if len(args) != 3 || args[1].typ != vnum || args[2].typ != vnum {
+		interp.err = fmt.Errorf("image.resize expects an image, a width, and a height")
+		return value{}
+	}
+	m := interp.img("image.resize", args[0])
+	if m == nil {
+		return value{}
+	}
+	b := m.m.Bounds()
+	w, h := args[1].v.(int), args[2].v.(int)
+	switch {
+	case w == 0 && h != 0:
+		w = max(b.Dx()*h/b.Dy(), 1)
+	case h == 0 && w != 0:
+		h = max(b.Dy()*w/b.Dx(), 1)
+	}
+	if w <= 0 || h <= 0 || b.Empty() {
+		interp.err = fmt.Errorf("image.resize: invalid size %vx%v", w, h)
+		return value{}
+	}
+	return value{typ: vhandle, v: &img{m: resize(m.m, w, h), format: m.format}}
+}
+
+// imageCrop returns the part of an image with its top-left corner at x, y
+// and the given width and height.
+func (interp *interp) imageCrop(args []value) value {
+	if len(args) != 5 || args[1].typ != vnum || args[2].typ != vnum || args[3].typ != vnum || args[4].typ != vnum {
+		interp.err = fmt.Errorf("image.crop expects an image, x, y, width, and height")
+		return value{}
+	}
+	m := interp.img("image.crop", args[0])
+	if m == nil {
+		return value{}
+	}
+	b := m.m.Bounds()
+	x, y := b.Min.X+args[1].v.(int), b.Min.Y+args[2].v.(int)
+	r := image.Rect(x, y, x+args[3].v.(int), y+args[4].v.(int))
+	if r.Empty() || !r.In(b) {
+		interp.err = fmt.Errorf("image.crop: rectangle is outside the image")
+		return value{}
+	}
+	dst := image.NewRGBA64(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dst.Set(x-r.Min.X, y-r.Min.Y, m.m.At(x, y))
+		}
+	}
+	return value{typ: vhandle, v: &img{m: dst, format: m.format}}
+}
+
+// imageSave encodes an image to a file. The format is "png", "jpeg", or
+// "gif", and defaults to the one named by the file's extension.
+func (interp *interp) imageSave(args []value) value {
+	if len(args) < 2 || len(args) > 3 || args[1].typ != vstring || len(args) == 3 && args[2].typ != vstring {
+		interp.err = fmt.Errorf("image.save expects an image, a file name, and an optional format")
+		return value{}
+	}
+	m := interp.img("image.save", args[0])
+	if m == nil || !interp.allowed("image.save") {
+		return value{}
+	}
+	name := args[1].v.(string)
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if len(args) == 3 {
+		format = args[2].v.(string)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return interp.failure(fmt.Errorf("image.save: %v", err))
+	}
+	switch format {
+	case "png":
+		err = png.Encode(f, m.m)
+	case "jpeg", "jpg":
+		err = jpeg.Encode(f, m.m, &jpeg.Options{Quality: 90})
+	case "gif":
+		err = gif.Encode(f, m.m, nil)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return interp.failure(fmt.Errorf("image.save: %v", err))
+	}
+	return value{}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rootMarker is the name of the file that marks the root of a project.
+// Import paths that are not relative are first resolved against the
+// nearest enclosing directory containing it.
+const rootMarker = "refgc.root"
+
+// srcExt is the extension tried when an import path doesn't name a file.
+const srcExt = ".x"
+
+type modstate int
+
+const (
+	unloaded modstate = iota
+	loading
+	loaded
+)
+
+type module struct {
+	name    string
+	path    string
+	af      *node
+	env     *env
+	exports map[string]bool
+	state   modstate
+
+	// modTime and size identify the version of the file that af was
+	// parsed from.
+	modTime time.Time
+	size    int64
+}
+
+func exists(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && !fi.IsDir()
+}
+
+// findFile returns name, or name with srcExt appended, if either exists.
+func findFile(name string) (string, bool) {
+	if exists(name) {
+		return name, true
+	}
+	if filepath.Ext(name) == "" && exists(name+srcExt) {
+		return name + srcExt, true
+	}
+	return "", false
+}
+
+// projectRoot returns the nearest directory at or above dir containing
+// rootMarker.
+func projectRoot(dir string) (string, bool) {
+	for {
+		if exists(filepath.Join(dir, rootMarker)) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// resolveImport maps the import path in the file from onto a file name.
+//
+// Absolute paths are used as is. Paths beginning with ./ or ../ are
+// relative to the directory of the importing file. Any other path is
+// looked up in the project root and then in each directory of the search
+// path, in order, and finally among fetched packages if it names one.
+func (interp *interp) resolveImport(from, path string) (string, error) {
+	if packed != nil {
+		if name, ok := packed.Imports[importKey(from, path)]; ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("cannot find module %q in the packed program", path)
+	}
+	if filepath.IsAbs(path) {
+		if name, ok := findFile(path); ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("cannot find module %q", path)
+	}
+	dir, err := filepath.Abs(filepath.Dir(from))
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		if name, ok := findFile(filepath.Join(dir, path)); ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("cannot find module %q relative to %v", path, dir)
+	}
+	var dirs []string
+	if root, ok := projectRoot(dir); ok {
+		dirs = append(dirs, root)
+	}
+	dirs = append(dirs, interp.path...)
+	if isPackagePath(path) && pkgDir != "" {
+		dirs = append(dirs, pkgDir)
+	}
+	for _, d := range dirs {
+		if name, ok := findFile(filepath.Join(d, path)); ok {
+			return filepath.Abs(name)
+		}
+	}
+	return "", fmt.Errorf("cannot find module %q in any of %v", path, dirs)
+}
+
+// load returns the module for the file name, parsing it if it hasn't
+// been seen yet. The module's top level isn't evaluated until one of its
+// members is first used.
+func (interp *interp) load(name string) (*module, error) {
+	if m, ok := interp.modules[name]; ok {
+		return m, nil
+	}
+	base := filepath.Base(name)
+	m := &module{
+		name: strings.TrimSuffix(base, filepath.Ext(base)),
+		path: name,
+	}
+	if err := m.parse(); err != nil {
+		return nil, err
+	}
+	if interp.modules == nil {
+		interp.modules = make(map[string]*module)
+	}
+	interp.modules[name] = m
+	return m, nil
+}
+
+// parse reads the module's source and resets it to its uninitialized
+// state.
+func (m *module) parse() error {
+	if packed == nil {
+		fi, err := os.Stat(m.path)
+		if err != nil {
+			return err
+		}
+		m.modTime, m.size = fi.ModTime(), fi.Size()
+	}
+	af, err := parseFile(m.path)
+	if err != nil {
+		return err
+	}
+	m.af = af
+	m.env = newEnv(nil)
+	m.exports = make(map[string]bool)
+	m.state = unloaded
+	return nil
+}
+
+// dirty reports whether the module's file changed since it was parsed.
+func (m *module) dirty() bool {
+	if packed != nil {
+		return false
+	}
+	fi, err := os.Stat(m.path)
+	return err != nil || !fi.ModTime().Equal(m.modTime) || fi.Size() != m.size
+}
+
+// init evaluates the top level of m if it hasn't been already.
+func (interp *interp) init(m *module) {
+	switch m.state {
+	case loaded:
+		return
+	case loading:
+		interp.err = fmt.Errorf("initialization cycle through module %v", m.name)
+		return
+	}
+	m.state = loading
+	saved, savedMod := interp.env, interp.mod
+	interp.env, interp.mod = m.env, m
+	interp.evalStmts(m.af.list)
+	interp.returning = false
+	interp.env, interp.mod = saved, savedMod
+	m.state = loaded
+}
+
+// reload re-parses the module named name if its file has changed, so that
+// its top level is evaluated again on next use. It reports whether the
+// module was reloaded.
+func (interp *interp) reload(name string) (bool, error) {
+	for _, m := range interp.modules {
+		if m.name != name && m.path != name {
+			continue
+		}
+		if m.state == loading {
+			return false, fmt.Errorf("cannot reload module %v during its initialization", m.name)
+		}
+		if !m.dirty() {
+			return false, nil
+		}
+		return true, m.parse()
+	}
+	return false, fmt.Errorf("no module named %v has been imported", name)
+}
+
+// evalImport binds the module imported by node to its alias, or if it has
+// none, to the base name of its file.
+func (interp *interp) evalImport(node *node) {
+	path, err := strconv.Unquote(node.value.text)
+	if err != nil {
+		interp.err = fmt.Errorf("%v: invalid import path %v", node.pos, node.value.text)
+		return
+	}
+	name, err := interp.resolveImport(node.pos.Filename, path)
+	if err != nil {
+		interp.err = fmt.Errorf("%v: %v", node.pos, err)
+		return
+	}
+	m, err := interp.load(name)
+	if err != nil {
+		interp.err = err
+		return
+	}
+	bind := m.name
+	if len(node.list) > 0 {
+		bind = node.list[0].value.text
+	}
+	interp.env.m[bind] = value{typ: vmodule, v: m}
+}
+
+// member returns the exported binding named sel in the top-level
+// environment of m. Bindings that weren't declared with export, and those
+// whose names begin with _, are private to the module.
+func (interp *interp) member(m *module, sel string) value {
+	interp.init(m)
+	if interp.err != nil {
+		return value{}
+	}
+	if isPrivate(sel) {
+		interp.err = fmt.Errorf("%v is private to module %v", sel, m.name)
+		return value{}
+	}
+	v, ok := m.env.m[sel]
+	if !ok {
+		interp.err = fmt.Errorf("module %v has no member named %v", m.name, sel)
+		return value{}
+	}
+	if !m.exports[sel] {
+		interp.err = fmt.Errorf("%v is not exported by module %v", sel, m.name)
+		return value{}
+	}
+	return v
+}
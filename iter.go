@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+func init() {
+	builtins["iterate"] = (*interp).builtinIterate
+	builtins["map"] = (*interp).builtinMap
+	builtins["filter"] = (*interp).builtinFilter
+	builtins["keys"] = (*interp).builtinKeys
+	builtins["len"] = (*interp).builtinLen
+}
+
+// An object is iterable if its class defines __iter, which returns an
+// iterator, often the object itself. An iterator is an object whose class
+// defines __next, which returns two values: the next element and true, or
+// anything and false once there are no more. The keys of the elements are
+// their positions, counting from 0.
+
+// iterable reports whether xs can be iterated over.
+func iterable(xs value) bool {
+	switch xs.typ {
+	case varray, vsortedmap, vmap, vset, vbytes, vstring, vnum:
+		return true
+	}
+	_, ok := operatorMethod(xs, "__iter")
+	return ok
+}
+
+// iterate calls yield with the key and value of each element of xs, in
+// order, until it returns false, there are no more, or an error occurs.
+// The elements of an array, map, or set are those it had when iteration
+// began.
+func (interp *interp) iterate(xs value, yield func(k, v value) bool) {
+	switch xs.typ {
+	case varray, vsortedmap, vmap:
+		entries := xs.entries()
+		switch xs.typ {
+		case vsortedmap:
+			entries = xs.v.(*sortedMap).entries
+		case vmap:
+			entries = xs.v.(*hashMap).items()
+		}
+		for _, e := range append(entries[:0:0], entries...) {
+			if !yield(e.k, e.v) {
+				return
+			}
+		}
+		return
+	case vset:
+		entries := xs.v.(*hashMap).items()
+		for i, e := range append(entries[:0:0], entries...) {
+			if !yield(value{typ: vnum, v: i}, e.k) {
+				return
+			}
+		}
+		return
+	case vbytes:
+		for i, c := range xs.v.([]byte) {
+			if !yield(value{typ: vnum, v: i}, value{typ: vnum, v: int(c)}) {
+				return
+			}
+		}
+		return
+	case vstring:
+		i := 0
+		for _, r := range xs.v.(string) {
+			if !yield(value{typ: vnum, v: i}, value{typ: vstring, v: string(r)}) {
+				return
+			}
+			i++
+		}
+		return
+	case vnum:
+		for i := 0; i < xs.v.(int); i++ {
+			if !yield(value{typ: vnum, v: i}, value{typ: vnum, v: i}) {
+				return
+			}
+		}
+		return
+	}
+	iter, ok := operatorMethod(xs, "__iter")
+	if !ok {
+		interp.err = fmt.Errorf("cannot iterate over %v", xs.typ)
+		return
+	}
+	it := interp.call(iter, []value{xs})
+	if interp.err != nil {
+		return
+	}
+	next, ok := operatorMethod(it, "__next")
+	if !ok {
+		interp.err = fmt.Errorf("__iter returned %v, which has no __next method", it.typ)
+		return
+	}
+	for i := 0; ; i++ {
+		r := interp.call(next, []value{it})
+		if interp.err != nil {
+			return
+		}
+		vs, _ := r.v.([]value)
+		if r.typ != vtuple || len(vs) != 2 || vs[1].typ != vbool {
+			interp.err = fmt.Errorf("__next must return an element and a bool")
+			return
+		}
+		if !vs[1].v.(bool) || !yield(value{typ: vnum, v: i}, vs[0]) {
+			return
+		}
+	}
+}
+
+// builtinIterate returns an array of the elements of an iterable value.
+func (interp *interp) builtinIterate(args []value) value {
+	if len(args) != 1 || !iterable(args[0]) {
+		interp.err = fmt.Errorf("iterate expects an iterable value")
+		return value{}
+	}
+	r := new(array)
+	interp.iterate(args[0], func(k, v value) bool {
+		r.entries = append(r.entries, struct{ k, v value }{value{typ: vnum, v: len(r.entries)}, v})
+		return true
+	})
+	if interp.err != nil {
+		return value{}
+	}
+	return value{typ: varray, v: r}
+}
+
+// builtinMap returns an array of the results of calling a function on
+// each element of an iterable value.
+func (interp *interp) builtinMap(args []value) value {
+	if len(args) != 2 || args[0].typ != vfunc || !iterable(args[1]) {
+		interp.err = fmt.Errorf("map expects a function and an iterable value")
+		return value{}
+	}
+	r := new(array)
+	interp.iterate(args[1], func(k, v value) bool {
+		x := interp.call(args[0], []value{v})
+		if interp.err != nil {
+			return false
+		}
+		r.entries = append(r.entries, struct{ k, v value }{value{typ: vnum, v: len(r.entries)}, x})
+		return true
+	})
+	if interp.err != nil {
+		return value{}
+	}
+	return value{typ: varray, v: r}
+}
+
+// builtinFilter returns an array of the elements of an iterable value for
+// which a function returns true.
+func (interp *interp) builtinFilter(args []value) value {
+	if len(args) != 2 || args[0].typ != vfunc || !iterable(args[1]) {
+		interp.err = fmt.Errorf("filter expects a function and an iterable value")
+		return value{}
+	}
+	r := new(array)
+	interp.iterate(args[1], func(k, v value) bool {
+		keep := interp.call(args[0], []value{v})
+		if interp.err != nil {
+			return false
+		}
+		if keep.typ != vbool {
+			interp.err = fmt.Errorf("filter's function must return a bool, not %v", keep.typ)
+			return false
+		}
+		if keep.v.(bool) {
+			r.entries = append(r.entries, struct{ k, v value }{value{typ: vnum, v: len(r.entries)}, v})
+		}
+		return true
+	})
+	if interp.err != nil {
+		return value{}
+	}
+	return value{typ: varray, v: r}
+}
+
+// builtinKeys returns an array of the keys of an array or map, in the
+// order they were added, or of a sorted map, in sorted order.
+func (interp *interp) builtinKeys(args []value) value {
+	if len(args) != 1 || args[0].typ != varray && args[0].typ != vmap && args[0].typ != vsortedmap {
+		interp.err = fmt.Errorf("keys expects an array or map")
+		return value{}
+	}
+	r := new(array)
+	interp.iterate(args[0], func(k, v value) bool {
+		r.entries = append(r.entries, struct{ k, v value }{value{typ: vnum, v: len(r.entries)}, k})
+		return true
+	})
+	return value{typ: varray, v: r}
+}
+
+// builtinLen returns the number of characters in a string, bytes in
+// bytes, entries in an array or map, or elements in a set, or what the
+// __len method of an object's class returns. Other values have no length.
+func (interp *interp) builtinLen(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("len expects one argument")
+		return value{}
+	}
+	n := 0
+	switch x := args[0]; x.typ {
+	case vstring:
+		n = utf8.RuneCountInString(x.v.(string))
+	case vbytes:
+		n = len(x.v.([]byte))
+	case varray:
+		n = len(x.entries())
+	case vmap, vset:
+		n = len(x.v.(*hashMap).index)
+	case vsortedmap:
+		n = len(x.v.(*sortedMap).entries)
+	default:
+		if f, ok := operatorMethod(x, "__len"); ok {
+			return interp.call(f, []value{x})
+		}
+		interp.err = fmt.Errorf("%v has no length", x.typ)
+		return value{}
+	}
+	return value{typ: vnum, v: n}
+}
@@ -16,27 +16,41 @@ func _() {
 	_ = x[kexprstmt-5]
 	_ = x[kwhilestmt-6]
 	_ = x[kreturnstmt-7]
-	_ = x[karraylit-8]
-	_ = x[knumlit-9]
-	_ = x[kstringlit-10]
-	_ = x[kfunclit-11]
-	_ = x[kident-12]
-	_ = x[kunaryexpr-13]
-	_ = x[kbinaryexpr-14]
-	_ = x[kindexexpr-15]
-	_ = x[kselectorexpr-16]
-	_ = x[kkvexpr-17]
-	_ = x[kparenexpr-18]
-	_ = x[kcallexpr-19]
+	_ = x[kimportstmt-8]
+	_ = x[kexportstmt-9]
+	_ = x[kforstmt-10]
+	_ = x[kfuncdecl-11]
+	_ = x[kwhenstmt-12]
+	_ = x[kclassdecl-13]
+	_ = x[ktrystmt-14]
+	_ = x[kthrowstmt-15]
+	_ = x[kletstmt-16]
+	_ = x[karraylit-17]
+	_ = x[knumlit-18]
+	_ = x[kstringlit-19]
+	_ = x[kfunclit-20]
+	_ = x[kstructlit-21]
+	_ = x[kinterfacelit-22]
+	_ = x[kmaplit-23]
+	_ = x[ksetlit-24]
+	_ = x[kident-25]
+	_ = x[kunaryexpr-26]
+	_ = x[kbinaryexpr-27]
+	_ = x[kindexexpr-28]
+	_ = x[kselectorexpr-29]
+	_ = x[kkvexpr-30]
+	_ = x[kparenexpr-31]
+	_ = x[kcallexpr-32]
 }
 
-const _kind_name = "kfilekassignstmtkblockstmtkifstmtkemptystmtkexprstmtkwhilestmtkreturnstmtkarraylitknumlitkstringlitkfunclitkidentkunaryexprkbinaryexprkindexexprkselectorexprkkvexprkparenexprkcallexpr"
+const _kind_name = "kfilekassignstmtkblockstmtkifstmtkemptystmtkexprstmtkwhilestmtkreturnstmtkimportstmtkexportstmtkforstmtkfuncdeclkwhenstmtkclassdeclktrystmtkthrowstmtkletstmtkarraylitknumlitkstringlitkfunclitkstructlitkinterfacelitkmaplitksetlitkidentkunaryexprkbinaryexprkindexexprkselectorexprkkvexprkparenexprkcallexpr"
 
-var _kind_index = [...]uint8{0, 5, 16, 26, 33, 43, 52, 62, 73, 82, 89, 99, 107, 113, 123, 134, 144, 157, 164, 174, 183}
+var _kind_index = [...]uint16{0, 5, 16, 26, 33, 43, 52, 62, 73, 84, 95, 103, 112, 121, 131, 139, 149, 157, 166, 173, 183, 191, 201, 214, 221, 228, 234, 244, 255, 265, 278, 285, 295, 304}
 
 func (i kind) String() string {
-	if i < 0 || i >= kind(len(_kind_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_kind_index)-1 {
 		return "kind(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _kind_name[_kind_index[i]:_kind_index[i+1]]
+	return _kind_name[_kind_index[idx]:_kind_index[idx+1]]
 }
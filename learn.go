@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A lesson teaches one part of the language. The learner edits a copy of
+// start until running it prints want.
+type lesson struct {
+	title    string
+	text     string
+	start    string
+	solution string
+	want     string
+}
+
+var lessons = []lesson{
+	{
+		title: "Printing",
+		text: `Programs are a list of statements, each ended by a semicolon.
+println writes values on their own line.
+
+Change the program so that it prints "hello, world".`,
+		start:    "println(\"hello\");\n",
+		solution: "println(\"hello, world\");\n",
+		want:     "hello, world\n",
+	},
+	{
+		title: "Variables",
+		text: `Assigning to a name with = creates a variable. Numbers support
++ - * / % and ** for powers.
+
+Set y to x times 7, then print y.`,
+		start:    "x = 6;\n// Set y here.\nprintln(y);\n",
+		solution: "x = 6;\ny = x * 7;\nprintln(y);\n",
+		want:     "42\n",
+	},
+	{
+		title: "Conditions",
+		text: `if runs its block when a condition is true, and else runs
+another block when it isn't. An if statement ends with a semicolon.
+
+Print "big" if n is more than 100, and "small" otherwise.`,
+		start:    "n = 250;\nif n > 0 {\n\tprintln(\"positive\");\n};\n",
+		solution: "n = 250;\nif n > 100 {\n\tprintln(\"big\");\n} else {\n\tprintln(\"small\");\n};\n",
+		want:     "big\n",
+	},
+	{
+		title: "Loops",
+		text: `while repeats its block for as long as a condition is true.
+
+Print the numbers from 1 to 5, one per line.`,
+		start:    "i = 1;\nwhile i <= 3 {\n\tprintln(i);\n\ti = i + 1;\n}\n",
+		solution: "i = 1;\nwhile i <= 5 {\n\tprintln(i);\n\ti = i + 1;\n}\n",
+		want:     "1\n2\n3\n4\n5\n",
+	},
+	{
+		title: "Arrays",
+		text: `An array maps keys to values. [a, b] has the keys 0 and 1, and
+["k": v] has the key "k". for k, v in a visits each key and value.
+
+Add "cherry" to the fruits, then print each fruit.`,
+		start:    "fruits = [\"apple\", \"banana\"];\nfor k, v in fruits {\n\tprintln(v);\n}\n",
+		solution: "fruits = [\"apple\", \"banana\"];\nfruits[2] = \"cherry\";\nfor k, v in fruits {\n\tprintln(v);\n}\n",
+		want:     "apple\nbanana\ncherry\n",
+	},
+	{
+		title: "Functions",
+		text: `func declares a function, and return gives back its result.
+Functions may call themselves.
+
+Finish fact so that it returns the factorial of n.`,
+		start:    "func fact(n) {\n\treturn 1;\n};\nprintln(fact(5));\n",
+		solution: "func fact(n) {\n\tif n <= 1 {\n\t\treturn 1;\n\t};\n\treturn n * fact(n - 1);\n};\nprintln(fact(5));\n",
+		want:     "120\n",
+	},
+}
+
+// learnDir is the directory in which the learner's exercises are kept.
+const learnDir = "refgc-learn"
+
+// exercisePath returns the file in which the learner solves lesson i.
+func exercisePath(i int) string {
+	name := strings.ToLower(strings.ReplaceAll(lessons[i].title, " ", "-"))
+	return filepath.Join(learnDir, fmt.Sprintf("%02d-%v%v", i+1, name, srcExt))
+}
+
+// runExercise runs the file name in a sandboxed interpreter and returns
+// what it printed.
+func runExercise(parent *interp, name string) (string, error) {
+	var out bytes.Buffer
+	child := &interp{main: name, sandbox: true, maxDepth: parent.maxDepth, stdin: strings.NewReader(""), stdout: &out}
+	af, err := parseFile(name)
+	if err != nil {
+		return "", err
+	}
+	child.evalBlock(af)
+	return out.String(), child.err
+}
+
+// learnMain runs the interactive tutorial, starting at the lesson numbered
+// by args[0], if any. Each exercise is written to learnDir, where the
+// learner edits it before asking for it to be checked.
+func learnMain(parent *interp, args []string) {
+	first := 0
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(lessons) {
+			exitf("usage: refgc learn [lesson], where lesson is 1 to %v\n", len(lessons))
+		}
+		first = n - 1
+	}
+	if err := os.MkdirAll(learnDir, 0777); err != nil {
+		exitf("%v\n", err)
+	}
+	in := bufio.NewScanner(os.Stdin)
+	for i := first; i < len(lessons); i++ {
+		l := lessons[i]
+		path := exercisePath(i)
+		if !exists(path) {
+			if err := os.WriteFile(path, []byte(l.start), 0666); err != nil {
+				exitf("%v\n", err)
+			}
+		}
+		fmt.Printf("\nLesson %v of %v: %v\n\n%v\n\nEdit %v, then press Enter to check it.\n", i+1, len(lessons), l.title, l.text, path)
+		for {
+			fmt.Print("[Enter] check, [s]olution, [n]ext, [q]uit: ")
+			if !in.Scan() {
+				fmt.Println()
+				return
+			}
+			switch strings.TrimSpace(in.Text()) {
+			case "q":
+				return
+			case "n":
+			case "s":
+				fmt.Printf("\n%v\n", l.solution)
+				continue
+			default:
+				got, err := runExercise(parent, path)
+				if err != nil {
+					fmt.Printf("%v\n", err)
+					continue
+				}
+				if got != l.want {
+					fmt.Printf("Not quite. Your program's output differs from what was expected:\n%v", unifiedDiff(got, l.want, 3))
+					continue
+				}
+				fmt.Println("Correct!")
+			}
+			break
+		}
+	}
+	fmt.Println("\nYou have finished every lesson.")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLessons checks that each lesson's solution passes its exercise, and
+// that the program the learner starts with doesn't.
+func TestLessons(t *testing.T) {
+	dir := t.TempDir()
+	for i, l := range lessons {
+		for _, src := range []string{l.solution, l.start} {
+			path := filepath.Join(dir, filepath.Base(exercisePath(i)))
+			if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+				t.Fatal(err)
+			}
+			got, err := runExercise(&interp{}, path)
+			if src == l.start {
+				if err == nil && got == l.want {
+					t.Errorf("lesson %v (%v): the start already passes", i+1, l.title)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("lesson %v (%v): solution failed: %v", i+1, l.title, err)
+			} else if got != l.want {
+				t.Errorf("lesson %v (%v): solution printed %q, want %q", i+1, l.title, got, l.want)
+			}
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
 	"text/scanner"
+	"time"
 	"unicode"
 )
 
@@ -25,7 +29,7 @@ func exitf(format string, v ...interface{}) {
 }
 
 func isnum(s string) bool {
-	_, err := strconv.Atoi(s)
+	_, err := parseLiteral(s)
 	return err == nil
 }
 
@@ -38,7 +42,14 @@ const (
 	tmul
 	tquo
 	trem
+	tpow
 	tassign
+	taddassign
+	tsubassign
+	tmulassign
+	tquoassign
+	tremassign
+	tcoalesce
 	tland
 	tlor
 	teql
@@ -58,32 +69,53 @@ const (
 	trbrace
 	tsemicolon
 	tcolon
+	tarrow
+	thash
 	tif
 	telse
 	tfunc
 	treturn
 	twhile
+	timport
+	texport
+	tfor
+	tin
+	twhen
+	tstruct
+	tclass
+	tinterface
+	tis
+	tas
+	ttry
+	tcatch
+	tfinally
+	tthrow
+	tlet
 	tident
 )
 
 const (
 	lowestPrec  = 0 // non-operators
-	unaryPrec   = 6
-	highestPrec = 7
+	unaryPrec   = 8
+	highestPrec = 9
 )
 
 func (tok token) prec() int {
 	switch tok.ttype {
-	case tlor:
+	case tcoalesce:
 		return 1
-	case tland:
+	case tlor:
 		return 2
-	case teql, tneq, tlss, tleq, tgtr, tgeq:
+	case tland:
 		return 3
-	case tplus, tsub:
+	case teql, tneq, tlss, tleq, tgtr, tgeq, tis, tas:
 		return 4
-	case tmul, tquo, trem:
+	case tplus, tsub:
 		return 5
+	case tmul, tquo, trem:
+		return 6
+	case tpow:
+		return 7
 	}
 	return lowestPrec
 }
@@ -103,20 +135,17 @@ func tokenize(name string, r io.Reader) (tokens []token, err error) {
 		tokens = append(tokens, token{pos: s.Position, text: s.TokenText()})
 	}
 	for i, j := 0, 1; j < len(tokens); i, j = i+1, j+1 {
-		if tokens[i].pos.Offset == tokens[j].pos.Offset-1 && tokens[j].text == "=" {
-			if tokens[i].text == "=" || tokens[i].text == "!" || tokens[i].text == "<" || tokens[i].text == ">" {
-				tokens[i].text += "="
-			}
-			tokens = append(tokens[:j], tokens[j+1:]...)
-		} else if tokens[i].pos.Offset == tokens[j].pos.Offset-1 && tokens[j].text == "&" {
-			if tokens[i].text == "&" {
-				tokens[i].text += "&"
-			}
-			tokens = append(tokens[:j], tokens[j+1:]...)
-		} else if tokens[i].pos.Offset == tokens[j].pos.Offset-1 && tokens[j].text == "|" {
-			if tokens[i].text == "|" {
-				tokens[i].text += "|"
-			}
+		if tokens[i].pos.Offset != tokens[j].pos.Offset-1 {
+			continue
+		}
+		switch a, b := tokens[i].text, tokens[j].text; {
+		case b == "=" && strings.Contains("=!<>+-*/%", a) && len(a) == 1,
+			b == "&" && a == "&",
+			b == "*" && a == "*",
+			b == "?" && a == "?",
+			b == "|" && a == "|",
+			b == ">" && a == "=":
+			tokens[i].text += b
 			tokens = append(tokens[:j], tokens[j+1:]...)
 		}
 	}
@@ -139,8 +168,22 @@ func tokenize(name string, r io.Reader) (tokens []token, err error) {
 			t.ttype = tquo
 		case t.text == "%":
 			t.ttype = trem
+		case t.text == "**":
+			t.ttype = tpow
 		case t.text == "=":
 			t.ttype = tassign
+		case t.text == "+=":
+			t.ttype = taddassign
+		case t.text == "-=":
+			t.ttype = tsubassign
+		case t.text == "*=":
+			t.ttype = tmulassign
+		case t.text == "/=":
+			t.ttype = tquoassign
+		case t.text == "%=":
+			t.ttype = tremassign
+		case t.text == "??":
+			t.ttype = tcoalesce
 		case t.text == "&&":
 			t.ttype = tland
 		case t.text == "||":
@@ -179,6 +222,10 @@ func tokenize(name string, r io.Reader) (tokens []token, err error) {
 			t.ttype = tsemicolon
 		case t.text == ":":
 			t.ttype = tcolon
+		case t.text == "=>":
+			t.ttype = tarrow
+		case t.text == "#":
+			t.ttype = thash
 		case t.text == "if":
 			t.ttype = tif
 		case t.text == "else":
@@ -189,7 +236,37 @@ func tokenize(name string, r io.Reader) (tokens []token, err error) {
 			t.ttype = treturn
 		case t.text == "while":
 			t.ttype = twhile
-		case unicode.IsLetter(rune(t.text[0])):
+		case t.text == "import":
+			t.ttype = timport
+		case t.text == "export":
+			t.ttype = texport
+		case t.text == "for":
+			t.ttype = tfor
+		case t.text == "in":
+			t.ttype = tin
+		case t.text == "when":
+			t.ttype = twhen
+		case t.text == "struct":
+			t.ttype = tstruct
+		case t.text == "class":
+			t.ttype = tclass
+		case t.text == "interface":
+			t.ttype = tinterface
+		case t.text == "is":
+			t.ttype = tis
+		case t.text == "as":
+			t.ttype = tas
+		case t.text == "try":
+			t.ttype = ttry
+		case t.text == "catch":
+			t.ttype = tcatch
+		case t.text == "finally":
+			t.ttype = tfinally
+		case t.text == "throw":
+			t.ttype = tthrow
+		case t.text == "let":
+			t.ttype = tlet
+		case unicode.IsLetter(rune(t.text[0])) || t.text[0] == '_':
 			t.ttype = tident
 		default:
 			return nil, fmt.Errorf("invalid token: %v", *t)
@@ -217,12 +294,25 @@ const (
 	kexprstmt
 	kwhilestmt
 	kreturnstmt
+	kimportstmt
+	kexportstmt
+	kforstmt
+	kfuncdecl
+	kwhenstmt
+	kclassdecl
+	ktrystmt
+	kthrowstmt
+	kletstmt
 
 	// expressions
 	karraylit
 	knumlit
 	kstringlit
 	kfunclit
+	kstructlit
+	kinterfacelit
+	kmaplit
+	ksetlit
 	kident
 	kunaryexpr
 	kbinaryexpr
@@ -242,17 +332,30 @@ type node struct {
 	value token
 
 	// kfile            list of statements
-	// kassignstmt      lhs expression, rhs expression
+	// kassignstmt      lhs expression (array literal to destructure), rhs expression (op in value for compound assignment)
 	// kblockstmt       list of statements
 	// kifstmt          cond expression, block statement, else statement
 	// kemptystmt
 	// kexprstmt        expression
 	// kwhilestmt       cond expression, block statement
-	// kreturnstmt      expression
+	// kreturnstmt      list of expressions
+	// kimportstmt      alias ident (optional) (path in value)
+	// kexportstmt      assign statement
+	// kforstmt         key ident (may be nil), value ident, range expression, block statement
+	// kfuncdecl        name ident, function literal
+	// kwhenstmt        cond expression, block statement, else statement (removed by resolveWhen)
+	// kclassdecl       name ident, parent expression (may be nil), list of kfuncdecl methods
+	// ktrystmt         block statement, catch ident (may be nil), catch block (may be nil), finally block (may be nil)
+	// kthrowstmt       expression
+	// kletstmt         ident or array literal of idents, rhs expression (may be nil)
 	// karraylit        list of kkvexpr
 	// knumlit
 	// kstringlit
 	// kfunclit         list of parameters (ident expressions), block
+	// kstructlit       list of fields (ident expressions)
+	// kinterfacelit    list of method names (ident expressions)
+	// kmaplit          list of kkvexpr
+	// ksetlit          list of expressions
 	// kident
 	// kunaryexpr       expression
 	// kbinaryexpr      X expression, op token, Y expression
@@ -260,14 +363,23 @@ type node struct {
 	// kselectorexpr    X expression, sel ident (expression)
 	// kkvexpr          key expression, value expression
 	// kparenexpr       X expression
-	// kcallexpr        func expression, list of arg expressions
+	// kcallexpr        func expression, list of arg expressions (kkvexpr for named arguments)
 	list []*node
 }
 
 func (p *parser) parseFile() (*node, error) {
 	var stmts []*node
 	for len(p.src) > 0 {
-		s, err := p.parseStmt()
+		var s *node
+		var err error
+		switch p.peek() {
+		case texport:
+			s, err = p.parseExport()
+		case twhen:
+			s, err = p.parseWhen(true)
+		default:
+			s, err = p.parseStmt()
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -309,11 +421,26 @@ func (p *parser) expectSemi() (err error) {
 }
 
 func (p *parser) parseBlock() (*node, error) {
+	return p.parseBody(false)
+}
+
+// parseBody parses a block, in which export statements are allowed if
+// top is true.
+func (p *parser) parseBody(top bool) (*node, error) {
 	pos := p.pos()
 	p.consume()
 	var stmts []*node
 	for p.peek() != tillegal && p.peek() != trbrace {
-		s, err := p.parseStmt()
+		var s *node
+		var err error
+		switch {
+		case top && p.peek() == texport:
+			s, err = p.parseExport()
+		case top && p.peek() == twhen:
+			s, err = p.parseWhen(true)
+		default:
+			s, err = p.parseStmt()
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -326,6 +453,69 @@ func (p *parser) parseBlock() (*node, error) {
 	return &node{kind: kblockstmt, pos: pos, list: stmts}, nil
 }
 
+// isPrivate reports whether name is private to its module by convention,
+// and so can't be exported.
+func isPrivate(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
+
+func (p *parser) parseExport() (*node, error) {
+	pos := p.pos()
+	p.consume()
+	s, err := p.parseStmt()
+	if err != nil {
+		return nil, err
+	}
+	if (s.kind != kassignstmt && s.kind != kletstmt || s.list[0].kind != kident) && s.kind != kfuncdecl && s.kind != kclassdecl {
+		return nil, fmt.Errorf("%v: export must be followed by an assignment to or declaration of an identifier, a function declaration, or a class declaration", pos)
+	}
+	if name := s.list[0].value.text; isPrivate(name) {
+		return nil, fmt.Errorf("%v: cannot export %v, since names beginning with _ are private to their module", pos, name)
+	}
+	return &node{kind: kexportstmt, pos: pos, list: []*node{s}}, nil
+}
+
+// parseWhen parses a when statement, whose blocks may contain exports if
+// it is at top level.
+func (p *parser) parseWhen(top bool) (*node, error) {
+	pos := p.pos()
+	p.consume()
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != tlbrace {
+		return nil, fmt.Errorf("%v: when statement missing body", p.pos())
+	}
+	block, err := p.parseBody(top)
+	if err != nil {
+		return nil, err
+	}
+	list := []*node{cond, block}
+	if p.peek() == telse {
+		p.consume()
+		var els *node
+		switch p.peek() {
+		case twhen:
+			els, err = p.parseWhen(top)
+		case tlbrace:
+			els, err = p.parseBody(top)
+			if err == nil {
+				err = p.expectSemi()
+			}
+		default:
+			return nil, fmt.Errorf("%v: else must be followed by when statement or block", p.pos())
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, els)
+	} else if err := p.expectSemi(); err != nil {
+		return nil, err
+	}
+	return &node{kind: kwhenstmt, pos: pos, list: list}, nil
+}
+
 func (p *parser) parseStmt() (*node, error) {
 	switch p.peek() {
 	case tlbrace:
@@ -337,6 +527,41 @@ func (p *parser) parseStmt() (*node, error) {
 			return nil, err
 		}
 		return block, nil
+	case tfunc:
+		pos := p.pos()
+		p.consume()
+		if p.peek() != tident {
+			return nil, fmt.Errorf("%v: expected function name", p.pos())
+		}
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		f, err := p.parseFunc(pos)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSemi(); err != nil {
+			return nil, err
+		}
+		return &node{kind: kfuncdecl, pos: pos, list: []*node{name, f}}, nil
+	case tclass:
+		return p.parseClass()
+	case ttry:
+		return p.parseTry()
+	case tlet:
+		return p.parseLet()
+	case tthrow:
+		pos := p.pos()
+		p.consume()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSemi(); err != nil {
+			return nil, err
+		}
+		return &node{kind: kthrowstmt, pos: pos, list: []*node{x}}, nil
 	case tif:
 		pos := p.pos()
 		p.consume()
@@ -398,28 +623,93 @@ func (p *parser) parseStmt() (*node, error) {
 			return nil, err
 		}
 		return &node{kind: kwhilestmt, pos: pos, list: []*node{cond, block}}, nil
+	case tfor:
+		pos := p.pos()
+		p.consume()
+		k, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		v := k
+		if p.peek() == tcomma {
+			p.consume()
+			v, err = p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			k = nil
+		}
+		if p.peek() != tin {
+			return nil, fmt.Errorf("%v: expected in", p.pos())
+		}
+		p.consume()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != tlbrace {
+			return nil, fmt.Errorf("for statement missing body")
+		}
+		block, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: kforstmt, pos: pos, list: []*node{k, v, x, block}}, nil
 	case treturn:
 		pos := p.pos()
 		p.consume()
-		var expr *node
-		var err error
+		var list []*node
 		if pt := p.peek(); pt != tsemicolon && pt != trbrace {
-			expr, err = p.parseExpr()
+			for {
+				expr, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, expr)
+				if p.peek() != tcomma {
+					break
+				}
+				p.consume()
+			}
+		}
+		if err := p.expectSemi(); err != nil {
+			return nil, err
+		}
+		return &node{kind: kreturnstmt, pos: pos, list: list}, nil
+	case timport:
+		pos := p.pos()
+		p.consume()
+		var list []*node
+		if p.peek() == tident {
+			alias, err := p.parseIdent()
 			if err != nil {
 				return nil, err
 			}
+			list = append(list, alias)
+		}
+		var tok token
+		if len(p.src) > 0 {
+			tok = p.src[0]
 		}
-		if err = p.expectSemi(); err != nil {
+		if tok.ttype != tstring {
+			return nil, fmt.Errorf("%v: expected import path", p.pos())
+		}
+		p.consume()
+		if err := p.expectSemi(); err != nil {
 			return nil, err
 		}
-		return &node{kind: kreturnstmt, pos: pos, list: []*node{expr}}, nil
+		return &node{kind: kimportstmt, pos: pos, value: tok, list: list}, nil
 	case tident, tlbrack, tlparen:
 		pos := p.pos()
 		x, err := p.parseExpr()
 		if err != nil {
 			return nil, err
 		}
-		if p.peek() == tassign {
+		switch p.peek() {
+		case tcomma:
+			return p.parseMultiAssign(pos, x)
+		case tassign:
 			p.consume()
 			y, err := p.parseExpr()
 			if err != nil {
@@ -429,10 +719,68 @@ func (p *parser) parseStmt() (*node, error) {
 				return nil, err
 			}
 			return &node{kind: kassignstmt, pos: pos, list: []*node{x, y}}, nil
+		case taddassign, tsubassign, tmulassign, tquoassign, tremassign:
+			op := p.src[0]
+			p.consume()
+			y, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSemi(); err != nil {
+				return nil, err
+			}
+			return &node{kind: kassignstmt, pos: pos, value: op, list: []*node{x, y}}, nil
 		}
 		return &node{kind: kexprstmt, pos: pos, list: []*node{x}}, nil
+	case twhen:
+		return p.parseWhen(false)
+	case texport:
+		return nil, fmt.Errorf("%v: export is only allowed at top level", p.pos())
 	}
-	return nil, fmt.Errorf("%v: invalid statement", p.pos())
+	return nil, fmt.Errorf("%v: invalid statement, expected %v", p.pos(), grammarRule("Statement"))
+}
+
+// parseMultiAssign parses the rest of an assignment to a list of
+// expressions beginning with x. It is represented as an assignment to an
+// array literal, which assigns the elements of an array to each expression
+// in turn. If there is more than one expression on the right, they are
+// gathered into an array literal as well.
+func (p *parser) parseMultiAssign(pos scanner.Position, x *node) (*node, error) {
+	lhs := &node{kind: karraylit, pos: pos, list: []*node{x}}
+	for p.peek() == tcomma {
+		p.consume()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		lhs.list = append(lhs.list, x)
+	}
+	if p.peek() != tassign {
+		return nil, fmt.Errorf("%v: expected =", p.pos())
+	}
+	p.consume()
+	rhs := &node{kind: karraylit, pos: p.pos()}
+	for {
+		y, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		rhs.list = append(rhs.list, y)
+		if p.peek() != tcomma {
+			break
+		}
+		p.consume()
+	}
+	if err := p.expectSemi(); err != nil {
+		return nil, err
+	}
+	switch len(rhs.list) {
+	case 1:
+		return &node{kind: kassignstmt, pos: pos, list: []*node{lhs, rhs.list[0]}}, nil
+	case len(lhs.list):
+		return &node{kind: kassignstmt, pos: pos, list: []*node{lhs, rhs}}, nil
+	}
+	return nil, fmt.Errorf("%v: assignment mismatch: %v variables but %v values", pos, len(lhs.list), len(rhs.list))
 }
 
 func (p *parser) parseExpr() (*node, error) {
@@ -454,14 +802,41 @@ func (p *parser) parseBinaryExpr(prec1 int) (*node, error) {
 			return x, nil
 		}
 		p.consume()
-		y, err := p.parseBinaryExpr(oprec + 1)
-		if err != nil {
-			return nil, err
+		if tok.ttype == tpow {
+			// right-associative
+			oprec--
+		}
+		y, ok := p.typeKeyword(tok)
+		if !ok {
+			if y, err = p.parseBinaryExpr(oprec + 1); err != nil {
+				return nil, err
+			}
 		}
 		x = &node{kind: kbinaryexpr, pos: x.pos, value: tok, list: []*node{x, y}}
 	}
 }
 
+// typeKeyword parses the operand of is or as as an identifier if it is a
+// keyword naming a type of values, as in v is func, rather than the
+// beginning of a literal.
+func (p *parser) typeKeyword(op token) (*node, bool) {
+	if op.ttype != tis && op.ttype != tas || len(p.src) == 0 {
+		return nil, false
+	}
+	tok := p.src[0]
+	switch tok.ttype {
+	case tfunc, tstruct, tclass, tinterface:
+	default:
+		return nil, false
+	}
+	if len(p.src) > 1 && (p.src[1].ttype == tlparen || p.src[1].ttype == tlbrace) {
+		return nil, false
+	}
+	p.consume()
+	tok.ttype = tident
+	return &node{kind: kident, pos: tok.pos, value: tok}, true
+}
+
 func (p *parser) parseUnaryExpr() (*node, error) {
 	var op token
 	if len(p.src) > 0 {
@@ -514,12 +889,28 @@ L:
 		case tlparen:
 			p.consume()
 			args := []*node{x}
+			named := false
 			pt := p.peek()
 			for pt != trparen && pt != tillegal {
+				xpos := p.pos()
 				ex, err := p.parseExpr()
 				if err != nil {
 					return nil, err
 				}
+				if p.peek() == tcolon {
+					if ex.kind != kident {
+						return nil, fmt.Errorf("%v: expected parameter name before :", xpos)
+					}
+					p.consume()
+					y, err := p.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+					ex = &node{kind: kkvexpr, pos: xpos, list: []*node{ex, y}}
+					named = true
+				} else if named {
+					return nil, fmt.Errorf("%v: positional argument follows named argument", xpos)
+				}
 				args = append(args, ex)
 				if p.peek() == tcomma {
 					p.consume()
@@ -539,6 +930,9 @@ L:
 }
 
 func (p *parser) parseOperand() (*node, error) {
+	if p.isLambda() {
+		return p.parseLambda()
+	}
 	switch p.peek() {
 	case tident:
 		return p.parseIdent()
@@ -599,38 +993,356 @@ func (p *parser) parseOperand() (*node, error) {
 	case tfunc:
 		pos := p.pos()
 		p.consume()
-		if p.peek() != tlparen {
-			return nil, fmt.Errorf("%v: expected ( at beginning of parameter list", p.pos())
+		return p.parseFunc(pos)
+	case tstruct:
+		return p.parseNames(kstructlit, "struct fields", "field")
+	case tinterface:
+		return p.parseNames(kinterfacelit, "interface methods", "method")
+	case tlbrace:
+		return p.parseMapLit()
+	case thash:
+		return p.parseSetLit()
+	}
+	return nil, fmt.Errorf("%v: bad expression, expected %v", p.pos(), grammarRule("Operand"))
+}
+
+// parseMapLit parses a map literal, which is a list of key-value pairs in
+// braces.
+func (p *parser) parseMapLit() (*node, error) {
+	pos := p.pos()
+	p.consume()
+	var entries []*node
+	pt := p.peek()
+	for pt != trbrace && pt != tillegal {
+		kpos := p.pos()
+		k, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != tcolon {
+			return nil, fmt.Errorf("%v: expected : after map key", p.pos())
 		}
 		p.consume()
-		var list []*node
-		pt := p.peek()
-		for pt != trparen && pt != tillegal {
+		v, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &node{kind: kkvexpr, pos: kpos, list: []*node{k, v}})
+		if p.peek() == tcomma {
+			p.consume()
+		} else if p.peek() != trbrace {
+			return nil, fmt.Errorf("%v: expected , or } in map literal", p.pos())
+		}
+		pt = p.peek()
+	}
+	if pt == tillegal {
+		return nil, fmt.Errorf("%v: expected } at end of map", p.pos())
+	}
+	p.consume()
+	return &node{kind: kmaplit, pos: pos, list: entries}, nil
+}
+
+// parseSetLit parses a set literal, which is a list of elements in braces
+// following #.
+func (p *parser) parseSetLit() (*node, error) {
+	pos := p.pos()
+	p.consume()
+	if p.peek() != tlbrace {
+		return nil, fmt.Errorf("%v: expected { after #", p.pos())
+	}
+	p.consume()
+	var elements []*node
+	pt := p.peek()
+	for pt != trbrace && pt != tillegal {
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, x)
+		if p.peek() == tcomma {
+			p.consume()
+		} else if p.peek() != trbrace {
+			return nil, fmt.Errorf("%v: expected , or } in set literal", p.pos())
+		}
+		pt = p.peek()
+	}
+	if pt == tillegal {
+		return nil, fmt.Errorf("%v: expected } at end of set", p.pos())
+	}
+	p.consume()
+	return &node{kind: ksetlit, pos: pos, list: elements}, nil
+}
+
+// parseNames parses a struct or interface type, which is a keyword
+// followed by a list of distinct names in braces. what describes the list
+// and elem its elements in error messages.
+func (p *parser) parseNames(k kind, what, elem string) (*node, error) {
+	pos := p.pos()
+	p.consume()
+	if p.peek() != tlbrace {
+		return nil, fmt.Errorf("%v: expected { at beginning of %v", p.pos(), what)
+	}
+	p.consume()
+	var list []*node
+	seen := make(map[string]bool)
+	pt := p.peek()
+	for pt != trbrace && pt != tillegal {
+		id, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		if seen[id.value.text] {
+			return nil, fmt.Errorf("%v: duplicate %v %v", id.pos, elem, id.value.text)
+		}
+		seen[id.value.text] = true
+		list = append(list, id)
+		if p.peek() == tcomma {
+			p.consume()
+		}
+		pt = p.peek()
+	}
+	if pt == tillegal {
+		return nil, fmt.Errorf("%v: expected } at end of %v", p.pos(), what)
+	}
+	p.consume()
+	return &node{kind: k, pos: pos, list: list}, nil
+}
+
+// parseFunc parses the parameters and body of a function literal, whose
+// func keyword is at pos.
+func (p *parser) parseFunc(pos scanner.Position) (*node, error) {
+	if p.peek() != tlparen {
+		return nil, fmt.Errorf("%v: expected ( at beginning of parameter list", p.pos())
+	}
+	p.consume()
+	var list []*node
+	pt := p.peek()
+	for pt != trparen && pt != tillegal {
+		id, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, id)
+		if p.peek() == tcomma {
+			p.consume()
+		}
+		pt = p.peek()
+	}
+	if pt == tillegal {
+		return nil, fmt.Errorf("%v: expected ) at end of parameter list", p.pos())
+	}
+	p.consume()
+	if p.peek() != tlbrace {
+		return nil, fmt.Errorf("%v: expected { at beginning of function body", p.pos())
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, body)
+	return &node{kind: kfunclit, pos: pos, list: list}, nil
+}
+
+// isLambda reports whether the tokens that follow begin a short function
+// literal, which is either a parameter or a parenthesized list of them,
+// followed by =>.
+func (p *parser) isLambda() bool {
+	i := 0
+	if len(p.src) > 0 && p.src[0].ttype == tlparen {
+		for i = 1; i < len(p.src) && p.src[i].ttype != trparen; i++ {
+			if p.src[i].ttype != tident && p.src[i].ttype != tcomma {
+				return false
+			}
+		}
+	} else if len(p.src) == 0 || p.src[0].ttype != tident {
+		return false
+	}
+	return i+1 < len(p.src) && p.src[i+1].ttype == tarrow
+}
+
+// parseLambda parses a short function literal, whose body is a single
+// expression, as the function literal returning it.
+func (p *parser) parseLambda() (*node, error) {
+	pos := p.pos()
+	var params []*node
+	if p.peek() == tident {
+		id, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, id)
+	} else {
+		p.consume()
+		for p.peek() != trparen {
 			id, err := p.parseIdent()
 			if err != nil {
 				return nil, err
 			}
-			list = append(list, id)
+			params = append(params, id)
 			if p.peek() == tcomma {
 				p.consume()
 			}
-			pt = p.peek()
 		}
-		if pt == tillegal {
-			return nil, fmt.Errorf("%v: expected ) at end of parameter list", p.pos())
+		p.consume()
+	}
+	p.consume()
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	ret := &node{kind: kreturnstmt, pos: x.pos, list: []*node{x}}
+	body := &node{kind: kblockstmt, pos: x.pos, list: []*node{ret}}
+	return &node{kind: kfunclit, pos: pos, list: append(params, body)}, nil
+}
+
+// parseLet parses a declaration of one or more variables, with optional
+// initial values given as in an assignment.
+func (p *parser) parseLet() (*node, error) {
+	pos := p.pos()
+	p.consume()
+	var names []*node
+	for {
+		id, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, id)
+		if p.peek() != tcomma {
+			break
+		}
+		p.consume()
+	}
+	lhs := names[0]
+	if len(names) > 1 {
+		lhs = &node{kind: karraylit, pos: pos, list: names}
+	}
+	if p.peek() != tassign {
+		if err := p.expectSemi(); err != nil {
+			return nil, err
+		}
+		return &node{kind: kletstmt, pos: pos, list: []*node{lhs, nil}}, nil
+	}
+	p.consume()
+	rhs := &node{kind: karraylit, pos: p.pos()}
+	for {
+		y, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		rhs.list = append(rhs.list, y)
+		if p.peek() != tcomma {
+			break
 		}
 		p.consume()
+	}
+	if err := p.expectSemi(); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(rhs.list) == 1:
+		return &node{kind: kletstmt, pos: pos, list: []*node{lhs, rhs.list[0]}}, nil
+	case len(rhs.list) == len(names):
+		return &node{kind: kletstmt, pos: pos, list: []*node{lhs, rhs}}, nil
+	}
+	return nil, fmt.Errorf("%v: assignment mismatch: %v variables but %v values", pos, len(names), len(rhs.list))
+}
+
+// parseTry parses a try statement, which must have a catch clause, a
+// finally clause, or both.
+func (p *parser) parseTry() (*node, error) {
+	pos := p.pos()
+	p.consume()
+	if p.peek() != tlbrace {
+		return nil, fmt.Errorf("%v: try statement missing body", p.pos())
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	var name, catch, finally *node
+	if p.peek() == tcatch {
+		p.consume()
+		if p.peek() == tident {
+			if name, err = p.parseIdent(); err != nil {
+				return nil, err
+			}
+		}
 		if p.peek() != tlbrace {
-			return nil, fmt.Errorf("%v: expected { at beginning of function body", p.pos())
+			return nil, fmt.Errorf("%v: catch clause missing body", p.pos())
+		}
+		if catch, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek() == tfinally {
+		p.consume()
+		if p.peek() != tlbrace {
+			return nil, fmt.Errorf("%v: finally clause missing body", p.pos())
+		}
+		if finally, err = p.parseBlock(); err != nil {
+			return nil, err
 		}
-		body, err := p.parseBlock()
+	}
+	if catch == nil && finally == nil {
+		return nil, fmt.Errorf("%v: try statement must have a catch or finally clause", pos)
+	}
+	if err := p.expectSemi(); err != nil {
+		return nil, err
+	}
+	return &node{kind: ktrystmt, pos: pos, list: []*node{body, name, catch, finally}}, nil
+}
+
+// parseClass parses a class declaration. Each method is parsed as a
+// function declaration whose first parameter is self.
+func (p *parser) parseClass() (*node, error) {
+	pos := p.pos()
+	p.consume()
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	var parent *node
+	if p.peek() == tcolon {
+		p.consume()
+		if parent, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek() != tlbrace {
+		return nil, fmt.Errorf("%v: expected { at beginning of class body", p.pos())
+	}
+	p.consume()
+	list := []*node{name, parent}
+	seen := make(map[string]bool)
+	for p.peek() != trbrace && p.peek() != tillegal {
+		if p.peek() == tsemicolon {
+			p.consume()
+			continue
+		}
+		mname, err := p.parseIdent()
 		if err != nil {
 			return nil, err
 		}
-		list = append(list, body)
-		return &node{kind: kfunclit, pos: pos, list: list}, nil
+		if seen[mname.value.text] {
+			return nil, fmt.Errorf("%v: duplicate method %v", mname.pos, mname.value.text)
+		}
+		seen[mname.value.text] = true
+		f, err := p.parseFunc(mname.pos)
+		if err != nil {
+			return nil, err
+		}
+		self := &node{kind: kident, pos: mname.pos, value: token{ttype: tident, pos: mname.pos, text: "self"}}
+		f.list = append([]*node{self}, f.list...)
+		list = append(list, &node{kind: kfuncdecl, pos: mname.pos, list: []*node{mname, f}})
+	}
+	if p.peek() == tillegal {
+		return nil, fmt.Errorf("%v: expected } at end of class body", p.pos())
+	}
+	p.consume()
+	if err := p.expectSemi(); err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("%v: bad expression", p.pos())
+	return &node{kind: kclassdecl, pos: pos, list: list}, nil
 }
 
 func (p *parser) parseIdent() (*node, error) {
@@ -646,28 +1358,133 @@ func (p *parser) parseIdent() (*node, error) {
 	return &node{kind: kident, pos: tok.pos, value: tok}, nil
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		exitf("missing filename argument\n")
-	}
-	name := os.Args[1]
-	f, err := os.Open(name)
+// parseFile returns the constant-folded tree for the file name, from the
+// cache if possible.
+func parseFile(name string) (*node, error) {
+	src, err := readSource(name)
 	if err != nil {
-		exitf("%v\n", err)
+		return nil, err
 	}
-	defer f.Close()
-	tokens, err := tokenize(name, f)
+	key := cacheKey(name, src)
+	if af, ok := readCache(key); ok {
+		return af, nil
+	}
+	tokens, err := tokenize(name, bytes.NewReader(src))
 	if err != nil {
-		exitf("%v\n", err)
+		return nil, err
 	}
 	p := &parser{src: tokens, name: name}
 	af, err := p.parseFile()
+	if err != nil {
+		return nil, err
+	}
+	if af, err = resolveWhen(af); err != nil {
+		return nil, err
+	}
+	af = fold(af)
+	writeCache(key, af)
+	return af, nil
+}
+
+var (
+	importPath  = flag.String("path", "", "list of directories to search for imports")
+	cacheFlag   = flag.String("cachedir", defaultCacheDir(), "directory in which to cache compiled files, or empty to disable caching")
+	pkgFlag     = flag.String("pkgdir", defaultPkgDir(), "directory into which refgc get fetches packages")
+	sandbox     = flag.Bool("sandbox", false, "disallow builtins that access the system outside the interpreter")
+	maxDepth    = flag.Int("max-depth", 50000, "maximum depth of function calls, or 0 for no limit")
+	strict      = flag.Bool("strict", false, "make assigning to a variable that wasn't declared with let an error")
+	overflow    = flag.String("overflow", "big", "what arithmetic whose result doesn't fit in an int does: promote it to a big number (big), wrap around (wrap), or fail (error)")
+	modulo      = flag.String("modulo", "truncated", "how / and % round with negative operands: toward zero (truncated), or so that remainders aren't negative (euclidean)")
+	httpTimeout = flag.Duration("http-timeout", 30*time.Second, "time limit for HTTP requests made with httpget and httppost, or 0 for no limit")
+	reportFlag  = flag.Bool("report", false, "print a summary of the language features and resources the program used")
+)
+
+func run(interp *interp, name string) {
+	interp.main = name
+	af, err := parseFile(name)
 	if err != nil {
 		exitf("%v\n", err)
 	}
-	interp := new(interp)
 	interp.evalBlock(af)
+	if interp.report != nil {
+		interp.report.write(os.Stderr, interp, af)
+	}
+	if e, ok := interp.err.(*exitStatus); ok {
+		os.Exit(e.code)
+	}
 	if interp.err != nil {
 		log.Fatal(interp.err)
 	}
 }
+
+func main() {
+	b, err := unpack()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	if b != nil {
+		packed = b
+		cacheDir = defaultCacheDir()
+		run(&interp{
+			args:        os.Args[1:],
+			sandbox:     b.Sandbox,
+			maxDepth:    *maxDepth,
+			strict:      b.Strict,
+			overflow:    b.Overflow,
+			modulo:      b.Modulo,
+			httpTimeout: *httpTimeout,
+		}, b.Main)
+		return
+	}
+	flag.Parse()
+	cacheDir = *cacheFlag
+	pkgDir = *pkgFlag
+	pruneCache()
+	interp := &interp{sandbox: *sandbox, maxDepth: *maxDepth, strict: *strict, httpTimeout: *httpTimeout}
+	mode, ok := overflowModes[*overflow]
+	if !ok {
+		exitf("invalid -overflow %q: must be big, wrap, or error\n", *overflow)
+	}
+	interp.overflow = mode
+	if interp.modulo, ok = moduloModes[*modulo]; !ok {
+		exitf("invalid -modulo %q: must be truncated or euclidean\n", *modulo)
+	}
+	if *reportFlag {
+		interp.report = newReport()
+	}
+	interp.path = append(filepath.SplitList(*importPath), filepath.SplitList(os.Getenv("REFGC_PATH"))...)
+	if flag.Arg(0) == "pack" {
+		packMain(interp, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "get" {
+		getMain(flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "check" {
+		checkMain(flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "grammar" {
+		grammarMain(flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "learn" {
+		learnMain(interp, flag.Args()[1:])
+		return
+	}
+	// refgc run file is the same as refgc file, and the arguments for the
+	// script may be separated from its name by --.
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	}
+	if len(args) < 1 {
+		exitf("missing filename argument\n")
+	}
+	interp.args = args[1:]
+	if len(interp.args) > 0 && interp.args[0] == "--" {
+		interp.args = interp.args[1:]
+	}
+	run(interp, args[0])
+}
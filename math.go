@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+func init() {
+	builtins["abs"] = (*interp).builtinAbs
+	builtins["min"] = extremum("min", tlss)
+	builtins["max"] = extremum("max", tgtr)
+	builtins["pow"] = (*interp).builtinPow
+	builtins["floor"] = rounding("floor", math.Floor)
+	builtins["ceil"] = rounding("ceil", math.Ceil)
+	builtins["atan2"] = (*interp).builtinAtan2
+	for name, f := range map[string]func(float64) float64{
+		"sqrt": math.Sqrt,
+		"log":  math.Log,
+		"exp":  math.Exp,
+		"sin":  math.Sin,
+		"cos":  math.Cos,
+		"tan":  math.Tan,
+		"asin": math.Asin,
+		"acos": math.Acos,
+		"atan": math.Atan,
+	} {
+		builtins[name] = floatFunc(name, f)
+	}
+	constants["PI"] = value{typ: vfloat, v: math.Pi}
+	constants["E"] = value{typ: vfloat, v: math.E}
+}
+
+// The math builtins accept numbers, decimals, and floats, and keep their
+// type where that is exact: abs, min, max, and pow of numbers are
+// numbers. The others are computed with floats.
+
+// numericArgs fails unless args are n numbers, decimals, or floats.
+func (interp *interp) numericArgs(fn string, args []value, n int) bool {
+	if len(args) != n {
+		interp.err = fmt.Errorf("%v expects %v arguments", fn, n)
+		return false
+	}
+	for _, a := range args {
+		if !isNumeric(a) {
+			interp.err = fmt.Errorf("%v expects numbers, not %v", fn, a.typ)
+			return false
+		}
+	}
+	return true
+}
+
+// builtinAbs returns the absolute value of a number, decimal, or float.
+func (interp *interp) builtinAbs(args []value) value {
+	if !interp.numericArgs("abs", args, 1) {
+		return value{}
+	}
+	switch x := args[0]; x.typ {
+	case vfloat:
+		return value{typ: vfloat, v: math.Abs(x.v.(float64))}
+	case vdecimal:
+		if x.v.(*decimal).unscaled.Sign() >= 0 {
+			return x
+		}
+	case vbig:
+		if x.v.(*big.Int).Sign() >= 0 {
+			return x
+		}
+	default:
+		if x.v.(int) >= 0 {
+			return x
+		}
+	}
+	return interp.negate(args[0])
+}
+
+// extremum returns a builtin named name that returns the argument x for
+// which x op y is true for each other y, or the first of those that are
+// equal.
+func extremum(name string, op ttype) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) == 0 {
+			interp.err = fmt.Errorf("%v expects at least one number", name)
+			return value{}
+		}
+		if !interp.numericArgs(name, args, len(args)) {
+			return value{}
+		}
+		r := args[0]
+		for _, x := range args[1:] {
+			b := interp.binaryOp(op, x, r)
+			if interp.err != nil {
+				return value{}
+			}
+			if b.v.(bool) {
+				r = x
+			}
+		}
+		return r
+	}
+}
+
+// builtinPow returns its first argument raised to the power of its
+// second, as ** does.
+func (interp *interp) builtinPow(args []value) value {
+	if !interp.numericArgs("pow", args, 2) {
+		return value{}
+	}
+	return interp.binaryOp(tpow, args[0], args[1])
+}
+
+// rounding returns a builtin named name that rounds a decimal or float to
+// a number by f, which is math.Floor or math.Ceil. A number is returned as
+// is.
+func rounding(name string, f func(float64) float64) builtin {
+	mode := roundModes["floor"]
+	if name == "ceil" {
+		mode = roundModes["ceiling"]
+	}
+	return func(interp *interp, args []value) value {
+		if !interp.numericArgs(name, args, 1) {
+			return value{}
+		}
+		switch x := args[0]; x.typ {
+		case vfloat:
+			return interp.floatToNum(f(x.v.(float64)))
+		case vdecimal:
+			return normBig(x.v.(*decimal).round(0, mode).unscaled)
+		}
+		return args[0]
+	}
+}
+
+// floatFunc returns a builtin named name that applies f to a number,
+// decimal, or float as a float.
+func floatFunc(name string, f func(float64) float64) builtin {
+	return func(interp *interp, args []value) value {
+		if !interp.numericArgs(name, args, 1) {
+			return value{}
+		}
+		return value{typ: vfloat, v: f(toFloat(args[0]))}
+	}
+}
+
+// builtinAtan2 returns the arc tangent of y/x, using the signs of both to
+// find the quadrant.
+func (interp *interp) builtinAtan2(args []value) value {
+	if !interp.numericArgs("atan2", args, 2) {
+		return value{}
+	}
+	return value{typ: vfloat, v: math.Atan2(toFloat(args[0]), toFloat(args[1]))}
+}
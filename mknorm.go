@@ -0,0 +1,143 @@
+//go:build ignore
+
+// mknorm generates normtables.go, the tables used by nfc and nfd, from
+// UnicodeData.txt and CompositionExclusions.txt in the Unicode Character
+// Database. The files are read from the directory given by -ucd, or
+// fetched from unicode.org for the version given by -version.
+//
+//	go run mknorm.go -version 15.0.0
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	version = flag.String("version", unicode.Version, "version of the Unicode Character Database")
+	ucd     = flag.String("ucd", "", "directory holding the database files, instead of fetching them")
+	out     = flag.String("o", "normtables.go", "file to write")
+)
+
+// open returns the contents of a file of the database.
+func open(name string) io.ReadCloser {
+	if *ucd != "" {
+		f, err := os.Open(filepath.Join(*ucd, name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return f
+	}
+	url := fmt.Sprintf("https://www.unicode.org/Public/%v/ucd/%v", *version, name)
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("%v: %v", url, resp.Status)
+	}
+	return resp.Body
+}
+
+// lines calls fn with the fields of each line of a database file, without
+// comments, that isn't blank.
+func lines(name string, fn func(fields []string)) {
+	f := open(name)
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		fn(fields)
+	}
+	if err := s.Err(); err != nil {
+		log.Fatalf("%v: %v", name, err)
+	}
+}
+
+func parseRune(s string) rune {
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rune(r)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mknorm: ")
+	flag.Parse()
+
+	ccc := make(map[rune]uint8)
+	decomp := make(map[rune][]rune)
+	lines("UnicodeData.txt", func(f []string) {
+		r := parseRune(f[0])
+		if c, _ := strconv.Atoi(f[3]); c != 0 {
+			ccc[r] = uint8(c)
+		}
+		// Compatibility decompositions start with a tag like <font>.
+		if f[5] != "" && !strings.HasPrefix(f[5], "<") {
+			for _, d := range strings.Fields(f[5]) {
+				decomp[r] = append(decomp[r], parseRune(d))
+			}
+		}
+	})
+	excluded := make(map[rune]bool)
+	lines("CompositionExclusions.txt", func(f []string) {
+		excluded[parseRune(f[0])] = true
+	})
+
+	// A character is a primary composite, which composition produces, if
+	// it has a canonical decomposition into two characters, the first a
+	// starter, and isn't excluded from composition.
+	comp := make(map[[2]rune]rune)
+	for r, d := range decomp {
+		if len(d) == 2 && ccc[r] == 0 && ccc[d[0]] == 0 && !excluded[r] {
+			comp[[2]rune(d)] = r
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mknorm.go from Unicode %v; DO NOT EDIT.\n\npackage main\n\n", *version)
+	fmt.Fprintf(&buf, "// combiningClass maps characters to their nonzero canonical combining\n// classes.\nvar combiningClass = map[rune]uint8{\n")
+	for _, r := range slices.Sorted(maps.Keys(ccc)) {
+		fmt.Fprintf(&buf, "\t%#04x: %v,\n", r, ccc[r])
+	}
+	fmt.Fprintf(&buf, "}\n\n// canonicalDecomp maps characters to their canonical decompositions,\n// which may themselves decompose.\nvar canonicalDecomp = map[rune]string{\n")
+	for _, r := range slices.Sorted(maps.Keys(decomp)) {
+		fmt.Fprintf(&buf, "\t%#04x: %+q,\n", r, string(decomp[r]))
+	}
+	fmt.Fprintf(&buf, "}\n\n// canonicalComp maps pairs of characters to the primary composites\n// they decompose to.\nvar canonicalComp = map[[2]rune]rune{\n")
+	pairs := slices.Collect(maps.Keys(comp))
+	slices.SortFunc(pairs, func(a, b [2]rune) int { return int(comp[a] - comp[b]) })
+	for _, p := range pairs {
+		fmt.Fprintf(&buf, "\t{%#04x, %#04x}: %#04x,\n", p[0], p[1], comp[p])
+	}
+	fmt.Fprintf(&buf, "}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
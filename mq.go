@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The mq module connects scripts to message brokers. Brokers are chosen
+// by the scheme of the URL passed to mq.connect: mqtt:// for an MQTT 3.1.1
+// server and mem:// for a broker within the process. Embedders can add
+// others with registerBroker.
+//
+// Messages are delivered to the callbacks given to mq.subscribe only while
+// the script is in mq.poll or mq.run, so callbacks never run concurrently
+// with the rest of the script.
+
+func init() {
+	nativeModule("mq", map[string]builtin{
+		"connect":   (*interp).mqConnect,
+		"publish":   (*interp).mqPublish,
+		"subscribe": (*interp).mqSubscribe,
+		"poll":      (*interp).mqPoll,
+		"run":       (*interp).mqRun,
+		"close":     (*interp).mqClose,
+	})
+	registerBroker("mqtt", dialMQTT)
+	registerBroker("mem", dialMem)
+}
+
+// A message is a message received from a broker.
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// A broker is a connection to a publish/subscribe message broker.
+type broker interface {
+	Publish(topic string, payload []byte) error
+	// Subscribe asks for messages on topics matching filter, which may
+	// contain MQTT-style + and # wildcards, to be sent on Messages.
+	Subscribe(filter string) error
+	// Messages returns the channel on which messages are received. It is
+	// closed when the connection is.
+	Messages() <-chan message
+	Close() error
+}
+
+var brokers = make(map[string]func(u *url.URL) (broker, error))
+
+// registerBroker makes dial the way to connect to brokers whose URLs have
+// the given scheme.
+func registerBroker(scheme string, dial func(u *url.URL) (broker, error)) {
+	brokers[scheme] = dial
+}
+
+// topicMatch reports whether topic matches the subscription filter.
+func topicMatch(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, p := range f {
+		if p == "#" {
+			return true
+		}
+		if i >= len(t) || p != "+" && p != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// An mqConn is a broker connection along with the script callbacks
+// subscribed through it.
+type mqConn struct {
+	b    broker
+	subs []mqSub
+}
+
+type mqSub struct {
+	filter string
+	fn     value
+}
+
+func (interp *interp) mqConn(fn string, v value) *mqConn {
+	c, ok := v.v.(*mqConn)
+	if !ok || v.typ != vhandle {
+		interp.err = fmt.Errorf("%v expects a connection returned by mq.connect", fn)
+		return nil
+	}
+	return c
+}
+
+// mqConnect connects to the broker at a URL, whose scheme selects the kind
+// of broker. If the connection can't be made, the result is an error
+// value. So it is for mq.publish, mq.subscribe, and mq.close if the
+// broker fails.
+func (interp *interp) mqConnect(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("mq.connect expects a URL")
+		return value{}
+	}
+	u, err := url.Parse(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("mq.connect: %v", err))
+	}
+	dial, ok := brokers[u.Scheme]
+	if !ok {
+		interp.err = fmt.Errorf("mq.connect: no broker for scheme %q", u.Scheme)
+		return value{}
+	}
+	// Brokers within the process don't reach outside the interpreter.
+	if u.Scheme != "mem" && !interp.allowed("mq.connect") {
+		return value{}
+	}
+	b, err := dial(u)
+	if err != nil {
+		return interp.failure(fmt.Errorf("mq.connect: %v", err))
+	}
+	return value{typ: vhandle, v: &mqConn{b: b}}
+}
+
+func (interp *interp) mqPublish(args []value) value {
+	if len(args) != 3 || args[1].typ != vstring || args[2].typ != vstring {
+		interp.err = fmt.Errorf("mq.publish expects a connection, a topic, and a payload")
+		return value{}
+	}
+	c := interp.mqConn("mq.publish", args[0])
+	if c == nil {
+		return value{}
+	}
+	if err := c.b.Publish(args[1].v.(string), []byte(args[2].v.(string))); err != nil {
+		return interp.failure(fmt.Errorf("mq.publish: %v", err))
+	}
+	return value{}
+}
+
+// mqSubscribe arranges for fn(topic, payload) to be called for messages on
+// topics matching a filter.
+func (interp *interp) mqSubscribe(args []value) value {
+	if len(args) != 3 || args[1].typ != vstring || args[2].typ != vfunc {
+		interp.err = fmt.Errorf("mq.subscribe expects a connection, a topic filter, and a function")
+		return value{}
+	}
+	c := interp.mqConn("mq.subscribe", args[0])
+	if c == nil {
+		return value{}
+	}
+	filter := args[1].v.(string)
+	if err := c.b.Subscribe(filter); err != nil {
+		return interp.failure(fmt.Errorf("mq.subscribe: %v", err))
+	}
+	c.subs = append(c.subs, mqSub{filter, args[2]})
+	return value{}
+}
+
+func (interp *interp) dispatch(c *mqConn, m message) {
+	for _, s := range c.subs {
+		if topicMatch(s.filter, m.topic) {
+			interp.call(s.fn, []value{{typ: vstring, v: m.topic}, {typ: vstring, v: string(m.payload)}})
+		}
+	}
+}
+
+// mqPoll delivers messages until none arrive within the given number of
+// milliseconds, and returns how many were received.
+func (interp *interp) mqPoll(args []value) value {
+	if len(args) != 2 || args[1].typ != vnum {
+		interp.err = fmt.Errorf("mq.poll expects a connection and a timeout")
+		return value{}
+	}
+	c := interp.mqConn("mq.poll", args[0])
+	if c == nil {
+		return value{}
+	}
+	timeout := time.Duration(args[1].v.(int)) * time.Millisecond
+	n := 0
+	for interp.err == nil {
+		select {
+		case m, ok := <-c.b.Messages():
+			if !ok {
+				return value{typ: vnum, v: n}
+			}
+			interp.dispatch(c, m)
+			n++
+		case <-time.After(timeout):
+			return value{typ: vnum, v: n}
+		}
+	}
+	return value{}
+}
+
+// mqRun delivers messages until the connection is closed.
+func (interp *interp) mqRun(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("mq.run expects a connection")
+		return value{}
+	}
+	c := interp.mqConn("mq.run", args[0])
+	if c == nil {
+		return value{}
+	}
+	for m := range c.b.Messages() {
+		interp.dispatch(c, m)
+		if interp.err != nil {
+			break
+		}
+	}
+	return value{}
+}
+
+func (interp *interp) mqClose(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("mq.close expects a connection")
+		return value{}
+	}
+	c := interp.mqConn("mq.close", args[0])
+	if c == nil {
+		return value{}
+	}
+	if err := c.b.Close(); err != nil {
+		return interp.failure(fmt.Errorf("mq.close: %v", err))
+	}
+	return value{}
+}
+
+// memHub is an in-process broker shared by the mem:// connections with the
+// same host name.
+type memHub struct {
+	mu    sync.Mutex
+	conns map[*memBroker]bool
+}
+
+var (
+	memHubsMu sync.Mutex
+	memHubs   = make(map[string]*memHub)
+)
+
+type memBroker struct {
+	hub  *memHub
+	msgs chan message
+
+	mu      sync.Mutex // guards filters and closed
+	filters []string
+	closed  bool
+}
+
+func dialMem(u *url.URL) (broker, error) {
+	memHubsMu.Lock()
+	defer memHubsMu.Unlock()
+	h := memHubs[u.Host]
+	if h == nil {
+		h = &memHub{conns: make(map[*memBroker]bool)}
+		memHubs[u.Host] = h
+	}
+	b := &memBroker{hub: h, msgs: make(chan message, 64)}
+	h.mu.Lock()
+	h.conns[b] = true
+	h.mu.Unlock()
+	return b, nil
+}
+
+// Publish delivers the message to every matching subscriber, dropping it
+// for subscribers whose queues are full.
+func (b *memBroker) Publish(topic string, payload []byte) error {
+	b.hub.mu.Lock()
+	defer b.hub.mu.Unlock()
+	for c := range b.hub.conns {
+		c.mu.Lock()
+		for _, f := range c.filters {
+			if topicMatch(f, topic) {
+				select {
+				case c.msgs <- message{topic, payload}:
+				default:
+				}
+				break
+			}
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+func (b *memBroker) Subscribe(filter string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.filters = append(b.filters, filter)
+	return nil
+}
+
+func (b *memBroker) Messages() <-chan message {
+	return b.msgs
+}
+
+func (b *memBroker) Close() error {
+	b.hub.mu.Lock()
+	delete(b.hub.conns, b)
+	b.hub.mu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.msgs)
+	}
+	return nil
+}
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttDisconnect  = 14
+	mqttKeepAlive   = 60 * time.Second
+	mqttDefaultPort = "1883"
+)
+
+type mqttBroker struct {
+	conn net.Conn
+	msgs chan message
+	done chan struct{}
+
+	mu     sync.Mutex // guards writes to conn, nextID, and closed
+	nextID uint16
+	closed bool
+}
+
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func (b *mqttBroker) write(typ, flags byte, body []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writeLocked(typ, flags, body)
+}
+
+func (b *mqttBroker) writeLocked(typ, flags byte, body []byte) error {
+	if b.closed {
+		return errors.New("use of closed connection")
+	}
+	pkt := []byte{typ<<4 | flags}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		pkt = append(pkt, d)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := b.conn.Write(append(pkt, body...))
+	return err
+}
+
+func mqttRead(r *bufio.Reader) (typ, flags byte, body []byte, err error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		n += int(d&0x7f) * mult
+		mult *= 128
+		if d&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, 0, nil, errors.New("malformed packet length")
+		}
+	}
+	body = make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return h >> 4, h & 0xf, body, err
+}
+
+func dialMQTT(u *url.URL) (broker, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), mqttDefaultPort)
+	}
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	flags := byte(0x02) // clean session
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, 0) // protocol level, flags
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = mqttString(body, "refgc-"+hex.EncodeToString(id))
+	if u.User != nil {
+		flags |= 0x80
+		body = mqttString(body, u.User.Username())
+		if pw, ok := u.User.Password(); ok {
+			flags |= 0x40
+			body = mqttString(body, pw)
+		}
+	}
+	body[7] = flags
+	b := &mqttBroker{conn: conn, msgs: make(chan message, 64), done: make(chan struct{})}
+	if err := b.write(mqttConnect, 0, body); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	typ, _, ack, err := mqttRead(r)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if typ != mqttConnack || len(ack) != 2 || ack[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused")
+	}
+	go b.readLoop(r)
+	go b.pingLoop()
+	return b, nil
+}
+
+func (b *mqttBroker) readLoop(r *bufio.Reader) {
+	defer close(b.msgs)
+	for {
+		typ, flags, body, err := mqttRead(r)
+		if err != nil {
+			return
+		}
+		if typ != mqttPublish || len(body) < 2 {
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(body))
+		if len(body) < 2+n {
+			return
+		}
+		topic, rest := string(body[2:2+n]), body[2+n:]
+		if qos := flags >> 1 & 3; qos > 0 {
+			if len(rest) < 2 {
+				return
+			}
+			b.write(mqttPuback, 0, rest[:2])
+			rest = rest[2:]
+		}
+		b.msgs <- message{topic, rest}
+	}
+}
+
+func (b *mqttBroker) pingLoop() {
+	t := time.NewTicker(mqttKeepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if b.write(mqttPingreq, 0, nil) != nil {
+				return
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *mqttBroker) Publish(topic string, payload []byte) error {
+	return b.write(mqttPublish, 0, append(mqttString(nil, topic), payload...))
+}
+
+func (b *mqttBroker) Subscribe(filter string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	body := binary.BigEndian.AppendUint16(nil, b.nextID)
+	body = append(mqttString(body, filter), 0)
+	return b.writeLocked(mqttSubscribe, 2, body)
+}
+
+func (b *mqttBroker) Messages() <-chan message {
+	return b.msgs
+}
+
+func (b *mqttBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.writeLocked(mqttDisconnect, 0, nil)
+	b.closed = true
+	close(b.done)
+	return b.conn.Close()
+}
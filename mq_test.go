@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestMQ(t *testing.T) {
+	// A broker that can't be reached is an error value, not a failure of
+	// the program.
+	wantOutput(t, `
+		println(iserror(mq.connect("mqtt://127.0.0.1:1")));
+		c = mq.connect("mem://test");
+		got = [];
+		mq.subscribe(c, "a/+", func(topic, payload) { got[len(got)] = topic + " " + payload; });
+		mq.publish(c, "a/b", "hi");
+		mq.publish(c, "b/c", "skipped");
+		mq.poll(c, 10);
+		mq.close(c);
+		println(got);
+	`, "true\n[0:\"a/b hi\"]\n")
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+func init() {
+	builtins["mpencode"] = (*interp).builtinMPEncode
+	builtins["mpdecode"] = (*interp).builtinMPDecode
+}
+
+// Values are encoded in MessagePack as follows: nil, bools, numbers,
+// floats, strings, and bytes as the corresponding types; arrays whose keys
+// are 0 through n-1, in order, as well as tuples and sets, as arrays; other
+// arrays and maps as maps. Big numbers that don't fit in 64 bits and
+// decimals use the extension types below, with their text as the data, so
+// that they are decoded exactly. Other values, and maps that contain
+// themselves, can't be encoded.
+const (
+	mpExtBig     = 1
+	mpExtDecimal = 2
+)
+
+// builtinMPEncode returns the MessagePack encoding of a value as bytes.
+func (interp *interp) builtinMPEncode(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("mpencode expects one argument")
+		return value{}
+	}
+	b, err := args[0].MarshalMsgpack()
+	if err != nil {
+		interp.err = fmt.Errorf("mpencode: %v", err)
+		return value{}
+	}
+	return value{typ: vbytes, v: b}
+}
+
+// builtinMPDecode returns the value encoded in MessagePack by bytes. Bytes
+// that aren't valid MessagePack, or that use an extension type other than
+// those of mpencode, result in an error value.
+func (interp *interp) builtinMPDecode(args []value) value {
+	if len(args) != 1 || args[0].typ != vbytes {
+		interp.err = fmt.Errorf("mpdecode expects bytes")
+		return value{}
+	}
+	var v value
+	if err := v.UnmarshalMsgpack(args[0].v.([]byte)); err != nil {
+		return interp.failure(fmt.Errorf("mpdecode: %v", err))
+	}
+	return v
+}
+
+// MarshalMsgpack returns the MessagePack encoding of v, so that values can
+// be exchanged with programs embedding the interpreter.
+func (v value) MarshalMsgpack() ([]byte, error) {
+	e := &mpEncoder{seen: make(map[*hashMap]bool)}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.b, nil
+}
+
+// UnmarshalMsgpack sets v to the value whose MessagePack encoding is b.
+func (v *value) UnmarshalMsgpack(b []byte) error {
+	d := &mpDecoder{b: b}
+	r, err := d.decode()
+	if err != nil {
+		return err
+	}
+	if d.i != len(d.b) {
+		return fmt.Errorf("%v extra bytes after value", len(d.b)-d.i)
+	}
+	*v = r
+	return nil
+}
+
+type mpEncoder struct {
+	b []byte
+	// seen holds the maps being encoded, to detect cycles.
+	seen map[*hashMap]bool
+}
+
+// header appends the header of a string, bytes, array, or map of n
+// elements, using the fixed form with prefix fix if n fits in bits.
+func (e *mpEncoder) header(n int, fix byte, bits uint, b8, b16, b32 byte) error {
+	switch {
+	case fix != 0 && n < 1<<bits:
+		e.b = append(e.b, fix|byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		e.b = append(e.b, b8, byte(n))
+	case n <= math.MaxUint16:
+		e.b = binary.BigEndian.AppendUint16(append(e.b, b16), uint16(n))
+	case uint64(n) <= math.MaxUint32:
+		e.b = binary.BigEndian.AppendUint32(append(e.b, b32), uint32(n))
+	default:
+		return fmt.Errorf("too many elements")
+	}
+	return nil
+}
+
+func (e *mpEncoder) int(n int64) {
+	switch {
+	case n >= 0 && n <= math.MaxInt8, n < 0 && n >= -32:
+		e.b = append(e.b, byte(n))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		e.b = append(e.b, 0xd0, byte(n))
+	case n >= 0 && n <= math.MaxUint8:
+		e.b = append(e.b, 0xcc, byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		e.b = binary.BigEndian.AppendUint16(append(e.b, 0xd1), uint16(n))
+	case n >= 0 && n <= math.MaxUint16:
+		e.b = binary.BigEndian.AppendUint16(append(e.b, 0xcd), uint16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		e.b = binary.BigEndian.AppendUint32(append(e.b, 0xd2), uint32(n))
+	case n >= 0 && n <= math.MaxUint32:
+		e.b = binary.BigEndian.AppendUint32(append(e.b, 0xce), uint32(n))
+	default:
+		e.b = binary.BigEndian.AppendUint64(append(e.b, 0xd3), uint64(n))
+	}
+}
+
+func (e *mpEncoder) str(s string) error {
+	if err := e.header(len(s), 0xa0, 5, 0xd9, 0xda, 0xdb); err != nil {
+		return err
+	}
+	e.b = append(e.b, s...)
+	return nil
+}
+
+func (e *mpEncoder) ext(typ int8, data string) error {
+	if err := e.header(len(data), 0, 0, 0xc7, 0xc8, 0xc9); err != nil {
+		return err
+	}
+	e.b = append(append(e.b, byte(typ)), data...)
+	return nil
+}
+
+// values appends an array of n values, with value i given by at.
+func (e *mpEncoder) values(n int, at func(int) value) error {
+	if err := e.header(n, 0x90, 4, 0, 0xdc, 0xdd); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := e.encode(at(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entries appends a map of n entries, with key and value i given by at.
+func (e *mpEncoder) entries(n int, at func(int) (value, value)) error {
+	if err := e.header(n, 0x80, 4, 0, 0xde, 0xdf); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		k, v := at(i)
+		if err := e.encode(k); err != nil {
+			return err
+		}
+		if err := e.encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isList reports whether the keys of the array v are 0 through n-1, in
+// order.
+func isList(v value) bool {
+	for i, e := range v.entries() {
+		if e.k.typ != vnum || e.k.v.(int) != i {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *mpEncoder) encode(v value) error {
+	switch v.typ {
+	case vnil:
+		e.b = append(e.b, 0xc0)
+	case vbool:
+		if v.v.(bool) {
+			e.b = append(e.b, 0xc3)
+		} else {
+			e.b = append(e.b, 0xc2)
+		}
+	case vnum:
+		e.int(int64(v.v.(int)))
+	case vbig:
+		x := v.v.(*big.Int)
+		switch {
+		case x.IsInt64():
+			e.int(x.Int64())
+		case x.IsUint64():
+			e.b = binary.BigEndian.AppendUint64(append(e.b, 0xcf), x.Uint64())
+		default:
+			return e.ext(mpExtBig, x.String())
+		}
+	case vdecimal:
+		return e.ext(mpExtDecimal, v.v.(*decimal).String())
+	case vfloat:
+		e.b = binary.BigEndian.AppendUint64(append(e.b, 0xcb), math.Float64bits(v.v.(float64)))
+	case vstring:
+		return e.str(v.v.(string))
+	case vbytes:
+		b := v.v.([]byte)
+		if err := e.header(len(b), 0, 0, 0xc4, 0xc5, 0xc6); err != nil {
+			return err
+		}
+		e.b = append(e.b, b...)
+	case vtuple:
+		vs := v.v.([]value)
+		return e.values(len(vs), func(i int) value { return vs[i] })
+	case varray:
+		if isList(v) {
+			es := v.entries()
+			return e.values(len(es), func(i int) value { return es[i].v })
+		}
+		es := v.entries()
+		return e.entries(len(es), func(i int) (value, value) { return es[i].k, es[i].v })
+	case vset, vmap:
+		m := v.v.(*hashMap)
+		if e.seen[m] {
+			return fmt.Errorf("cannot encode a %v that contains itself", v.typ)
+		}
+		e.seen[m] = true
+		defer delete(e.seen, m)
+		es := m.items()
+		if v.typ == vset {
+			return e.values(len(es), func(i int) value { return es[i].k })
+		}
+		return e.entries(len(es), func(i int) (value, value) { return es[i].k, es[i].v })
+	default:
+		return fmt.Errorf("cannot encode %v", v.typ)
+	}
+	return nil
+}
+
+var errMPShort = errors.New("unexpected end of data")
+
+type mpDecoder struct {
+	b []byte
+	i int
+}
+
+// next returns the next n bytes.
+func (d *mpDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.i < n {
+		return nil, errMPShort
+	}
+	b := d.b[d.i : d.i+n]
+	d.i += n
+	return b, nil
+}
+
+// uint returns the big-endian unsigned integer in the next n bytes, which
+// is 1, 2, 4, or 8.
+func (d *mpDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// length returns a length stored in the next n bytes.
+func (d *mpDecoder) length(n int) (int, error) {
+	u, err := d.uint(n)
+	return int(u), err
+}
+
+func (d *mpDecoder) decode() (value, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return value{}, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return value{typ: vnum, v: int(c)}, nil
+	case c >= 0xe0:
+		return value{typ: vnum, v: int(int8(c))}, nil
+	case c&0xf0 == 0x80:
+		return d.mapOf(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.arrayOf(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.strOf(int(c & 0x1f))
+	}
+	var n int
+	switch c {
+	case 0xc0:
+		return value{typ: vnil}, nil
+	case 0xc2, 0xc3:
+		return value{typ: vbool, v: c == 0xc3}, nil
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = d.length(1 << (c - 0xc4)); err != nil {
+			return value{}, err
+		}
+		b, err := d.next(n)
+		return value{typ: vbytes, v: append([]byte(nil), b...)}, err
+	case 0xc7, 0xc8, 0xc9:
+		if n, err = d.length(1 << (c - 0xc7)); err != nil {
+			return value{}, err
+		}
+		return d.extOf(n)
+	case 0xca:
+		u, err := d.uint(4)
+		return value{typ: vfloat, v: float64(math.Float32frombits(uint32(u)))}, err
+	case 0xcb:
+		u, err := d.uint(8)
+		return value{typ: vfloat, v: math.Float64frombits(u)}, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return value{}, err
+		}
+		if u > math.MaxInt {
+			return value{typ: vbig, v: new(big.Int).SetUint64(u)}, nil
+		}
+		return value{typ: vnum, v: int(u)}, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return value{}, err
+		}
+		// Sign-extend the value from its size.
+		shift := 64 - 8*size
+		return value{typ: vnum, v: int(int64(u<<shift) >> shift)}, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.extOf(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		if n, err = d.length(1 << (c - 0xd9)); err != nil {
+			return value{}, err
+		}
+		return d.strOf(n)
+	case 0xdc, 0xdd:
+		if n, err = d.length(2 << (c - 0xdc)); err != nil {
+			return value{}, err
+		}
+		return d.arrayOf(n)
+	case 0xde, 0xdf:
+		if n, err = d.length(2 << (c - 0xde)); err != nil {
+			return value{}, err
+		}
+		return d.mapOf(n)
+	}
+	return value{}, fmt.Errorf("invalid type byte %#x", c)
+}
+
+func (d *mpDecoder) strOf(n int) (value, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return value{}, err
+	}
+	return value{typ: vstring, v: string(b)}, nil
+}
+
+func (d *mpDecoder) arrayOf(n int) (value, error) {
+	// Each element takes at least a byte, which bounds n before the
+	// array is allocated.
+	if n > len(d.b)-d.i {
+		return value{}, errMPShort
+	}
+	es := make([]struct{ k, v value }, n)
+	for i := range es {
+		v, err := d.decode()
+		if err != nil {
+			return value{}, err
+		}
+		es[i].k = value{typ: vnum, v: i}
+		es[i].v = v
+	}
+	return arrayOf(es), nil
+}
+
+func (d *mpDecoder) mapOf(n int) (value, error) {
+	m := newHashMap()
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return value{}, err
+		}
+		if _, ok := hashKey(k); !ok {
+			return value{}, fmt.Errorf("invalid map key of type %v", k.typ)
+		}
+		v, err := d.decode()
+		if err != nil {
+			return value{}, err
+		}
+		m.put(k, v)
+	}
+	return value{typ: vmap, v: m}, nil
+}
+
+// extOf decodes an extension value with n bytes of data.
+func (d *mpDecoder) extOf(n int) (value, error) {
+	t, err := d.next(1)
+	if err != nil {
+		return value{}, err
+	}
+	b, err := d.next(n)
+	if err != nil {
+		return value{}, err
+	}
+	switch int8(t[0]) {
+	case mpExtBig:
+		if x, ok := new(big.Int).SetString(string(b), 10); ok {
+			return value{typ: vbig, v: x}, nil
+		}
+	case mpExtDecimal:
+		if x, ok := parseDecimal(string(b)); ok {
+			return value{typ: vdecimal, v: x}, nil
+		}
+	default:
+		return value{}, fmt.Errorf("unsupported extension type %v", int8(t[0]))
+	}
+	return value{}, fmt.Errorf("invalid data for extension type %v", int8(t[0]))
+}
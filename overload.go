@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+)
+
+// operatorMethods are the names of the methods a class defines to
+// overload operators on its objects. != is the negation of __eq.
+var operatorMethods = map[ttype]string{
+	tplus: "__add",
+	tsub:  "__sub",
+	tmul:  "__mul",
+	tquo:  "__div",
+	trem:  "__mod",
+	tpow:  "__pow",
+	teql:  "__eq",
+	tneq:  "__eq",
+	tlss:  "__lt",
+	tleq:  "__le",
+	tgtr:  "__gt",
+	tgeq:  "__ge",
+}
+
+// operatorMethod returns the method of v's class named name, if v is an
+// object whose class or one of its ancestors defines it.
+func operatorMethod(v value, name string) (value, bool) {
+	o, ok := v.v.(*object)
+	if !ok {
+		return value{}, false
+	}
+	return o.class.method(name)
+}
+
+// overloadedOp applies the binary operator op to l and r using the
+// method of l's class that overloads it, and reports whether there was
+// one. Objects that don't overload == are equal only to themselves.
+func (interp *interp) overloadedOp(op ttype, l, r value) (value, bool) {
+	name, ok := operatorMethods[op]
+	if !ok {
+		return value{}, false
+	}
+	m, ok := operatorMethod(l, name)
+	if !ok {
+		return value{}, false
+	}
+	v := interp.call(m, []value{l, r})
+	if op == teql || op == tneq {
+		if v.typ != vbool {
+			if interp.err == nil {
+				interp.err = fmt.Errorf("%v must return a bool, not %v", name, v.typ)
+			}
+			return value{}, true
+		}
+		v.v = v.v.(bool) == (op == teql)
+	}
+	return v, true
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A packed executable is a copy of the interpreter followed by a gob-encoded
+// bundle and a trailer holding packMagic and the length of the bundle.
+const packMagic = "refgcpk1"
+
+const trailerLen = len(packMagic) + 8
+
+// bundle holds a program's sources along with how each of its imports was
+// resolved when it was packed, and the resources packed with it. It also
+// records the -sandbox, -strict, -overflow, and -modulo flags it was
+// packed with, since a packed program's arguments are all its own.
+type bundle struct {
+	Main      string
+	Files     map[string][]byte
+	Imports   map[string]string // importing file + "\x00" + import path
+	Resources map[string][]byte // keyed by slash-separated path relative to Main
+	Sandbox   bool
+	Strict    bool
+	Overflow  overflowMode
+	Modulo    moduloMode
+}
+
+// packed is the bundle the running executable was packed with, if any.
+var packed *bundle
+
+func importKey(from, path string) string {
+	return from + "\x00" + path
+}
+
+func readSource(name string) ([]byte, error) {
+	if packed != nil {
+		if src, ok := packed.Files[name]; ok {
+			return src, nil
+		}
+		return nil, fmt.Errorf("%v is not in the packed program", name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// splitExecutable returns the length of the interpreter in the executable f
+// and the bundle appended to it, if any.
+func splitExecutable(f *os.File) (int64, *bundle, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	size := fi.Size()
+	if size < int64(trailerLen) {
+		return size, nil, nil
+	}
+	trailer := make([]byte, trailerLen)
+	if _, err := f.ReadAt(trailer, size-int64(trailerLen)); err != nil {
+		return 0, nil, err
+	}
+	if string(trailer[:len(packMagic)]) != packMagic {
+		return size, nil, nil
+	}
+	n := int64(binary.LittleEndian.Uint64(trailer[len(packMagic):]))
+	start := size - int64(trailerLen) - n
+	if start < 0 {
+		return 0, nil, errors.New("corrupt packed program")
+	}
+	b := new(bundle)
+	if err := gob.NewDecoder(io.NewSectionReader(f, start, n)).Decode(b); err != nil {
+		return 0, nil, fmt.Errorf("corrupt packed program: %v", err)
+	}
+	return start, b, nil
+}
+
+// unpack returns the bundle appended to the running executable, if any.
+func unpack() (*bundle, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, b, err := splitExecutable(f)
+	return b, err
+}
+
+// collect adds the file name and everything it imports to b.
+func (interp *interp) collect(b *bundle, name string) error {
+	if _, ok := b.Files[name]; ok {
+		return nil
+	}
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	b.Files[name] = src
+	af, err := parseFile(name)
+	if err != nil {
+		return err
+	}
+	var imports []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n == nil {
+			return
+		}
+		if n.kind == kimportstmt {
+			imports = append(imports, n)
+		}
+		for _, x := range n.list {
+			walk(x)
+		}
+	}
+	walk(af)
+	for _, n := range imports {
+		path, err := strconv.Unquote(n.value.text)
+		if err != nil {
+			return fmt.Errorf("%v: invalid import path %v", n.pos, n.value.text)
+		}
+		dep, err := interp.resolveImport(name, path)
+		if err != nil {
+			return fmt.Errorf("%v: %v", n.pos, err)
+		}
+		b.Imports[importKey(name, path)] = dep
+		if err := interp.collect(b, dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addResources adds the file name, or every file under it if it's a
+// directory, to the resources in b.
+func addResources(b *bundle, name string) error {
+	dir := filepath.Dir(b.Main)
+	return filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		b.Resources[filepath.ToSlash(rel)] = data
+		return nil
+	})
+}
+
+// pack writes an executable to out that runs the program in the file main
+// without needing its sources or the interpreter to be installed. The files
+// named by resources are included for access through resource.read. The
+// program runs with the same sandbox, strictness, and arithmetic modes as
+// interp.
+func (interp *interp) pack(main, out string, resources []string) error {
+	main, err := filepath.Abs(main)
+	if err != nil {
+		return err
+	}
+	b := &bundle{
+		Main:      main,
+		Files:     make(map[string][]byte),
+		Imports:   make(map[string]string),
+		Resources: make(map[string][]byte),
+		Sandbox:   interp.sandbox,
+		Strict:    interp.strict,
+		Overflow:  interp.overflow,
+		Modulo:    interp.modulo,
+	}
+	if err := interp.collect(b, main); err != nil {
+		return err
+	}
+	for _, r := range resources {
+		if err := addResources(b, r); err != nil {
+			return err
+		}
+	}
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(b); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, _, err := splitExecutable(f)
+	if err != nil {
+		return err
+	}
+	w, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(f, 0, n)); err != nil {
+		w.Close()
+		return err
+	}
+	trailer := make([]byte, trailerLen)
+	copy(trailer, packMagic)
+	binary.LittleEndian.PutUint64(trailer[len(packMagic):], uint64(payload.Len()))
+	payload.Write(trailer)
+	if _, err := w.Write(payload.Bytes()); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func packMain(interp *interp, args []string) {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	out := fs.String("o", "", "output file")
+	var resources stringList
+	fs.Var(&resources, "r", "file or directory to include as a resource (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: refgc pack [-o output] [-r resource]... file\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	var files []string
+	for fs.NArg() > 0 {
+		files = append(files, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		base := filepath.Base(files[0])
+		*out = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if err := interp.pack(files[0], *out, resources); err != nil {
+		exitf("%v\n", err)
+	}
+}
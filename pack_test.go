@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackModes(t *testing.T) {
+	// A packed program keeps the modes it was packed with, since it can't
+	// take the interpreter's flags.
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.x")
+	if err := os.WriteFile(main, []byte("println(1);\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "main")
+	interp := &interp{sandbox: true, strict: true, overflow: overflowError, modulo: moduloEuclidean}
+	if err := interp.pack(main, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, b, err := splitExecutable(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == nil || !b.Sandbox || !b.Strict || b.Overflow != overflowError || b.Modulo != moduloEuclidean {
+		t.Errorf("packed bundle %+v doesn't keep the modes it was packed with", b)
+	}
+}
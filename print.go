@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+func init() {
+	builtins["print"] = (*interp).builtinPrint
+	builtins["println"] = (*interp).builtinPrintln
+	builtins["printf"] = (*interp).builtinPrintf
+	builtins["format"] = (*interp).builtinFormat
+}
+
+// sprint formats values as print shows them, separated by spaces.
+func sprint(args []value) string {
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = a.String()
+	}
+	return strings.Join(s, " ")
+}
+
+// builtinPrint writes its arguments, separated by spaces.
+func (interp *interp) builtinPrint(args []value) value {
+	fmt.Fprint(interp.out(), sprint(args))
+	return value{typ: vnil}
+}
+
+// builtinPrintln writes its arguments, separated by spaces, on a line.
+func (interp *interp) builtinPrintln(args []value) value {
+	fmt.Fprintln(interp.out(), sprint(args))
+	return value{typ: vnil}
+}
+
+// builtinPrintf writes its arguments formatted by a format string, as
+// formatted by sprintf.
+func (interp *interp) builtinPrintf(args []value) value {
+	if len(args) < 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("printf expects a format string and values")
+		return value{}
+	}
+	s, ok := interp.sprintf("printf", args[0].v.(string), args[1:])
+	if !ok {
+		return value{}
+	}
+	fmt.Fprint(interp.out(), s)
+	return value{typ: vnil}
+}
+
+// builtinFormat returns its arguments formatted by a format string, as
+// printf would write them, for building messages and names.
+func (interp *interp) builtinFormat(args []value) value {
+	if len(args) < 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("format expects a format string and values")
+		return value{}
+	}
+	s, ok := interp.sprintf("format", args[0].v.(string), args[1:])
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: s}
+}
+
+// sprintf formats args by the format string f, in which each verb is a %,
+// optional flags, width, and precision as in Go, and one of
+//
+//	%d	a number in base 10
+//	%f	a number, decimal, or float as a float with a point and no exponent
+//	%g	a number, decimal, or float as a float, with an exponent if it is large
+//	%s	a string, or any other value as print shows it
+//	%q	a value as an element of an array shows it, so strings are quoted
+//	%v	the same as %s
+//
+// and %% is a literal %. It fails if a verb has no argument or one of the
+// wrong type, or there are arguments left over.
+func (interp *interp) sprintf(fn, f string, args []value) (string, bool) {
+	var sb strings.Builder
+	n := 0
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			sb.WriteByte(f[i])
+			continue
+		}
+		j := i + 1
+		for j < len(f) && strings.IndexByte("+-# 0123456789.", f[j]) >= 0 {
+			j++
+		}
+		if j == len(f) {
+			interp.err = fmt.Errorf("%v: format %q ends in an incomplete verb", fn, f)
+			return "", false
+		}
+		spec, verb := f[i:j], f[j]
+		i = j
+		if verb == '%' {
+			sb.WriteByte('%')
+			continue
+		}
+		if n == len(args) {
+			interp.err = fmt.Errorf("%v: missing argument for %%%c", fn, verb)
+			return "", false
+		}
+		a := args[n]
+		n++
+		var x any
+		switch verb {
+		case 'd':
+			switch a.typ {
+			case vnum:
+				x = a.v.(int)
+			case vbig:
+				x = a.v.(*big.Int)
+			default:
+				interp.err = fmt.Errorf("%v: %%d expects a number, not %v", fn, a.typ)
+				return "", false
+			}
+		case 'f', 'g':
+			if !isNumeric(a) {
+				interp.err = fmt.Errorf("%v: %%%c expects a number, not %v", fn, verb, a.typ)
+				return "", false
+			}
+			x = toFloat(a)
+		case 's', 'v':
+			verb, x = 's', a.String()
+		case 'q':
+			verb, x = 's', a.elem()
+		default:
+			interp.err = fmt.Errorf("%v: unknown verb %%%c", fn, verb)
+			return "", false
+		}
+		fmt.Fprintf(&sb, spec+string(verb), x)
+	}
+	if n < len(args) {
+		interp.err = fmt.Errorf("%v: %v extra arguments", fn, len(args)-n)
+		return "", false
+	}
+	return sb.String(), true
+}
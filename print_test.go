@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		f    string
+		args []value
+		want string
+	}{
+		{"%d|%5d", []value{{typ: vnum, v: 42}, {typ: vnum, v: -7}}, "42|   -7"},
+		{"%s %q %%", []value{str("a"), str("b")}, `a "b" %`},
+		{"%f", []value{{typ: vfloat, v: 1.5}}, "1.500000"},
+		{"%.2f", []value{{typ: vfloat, v: -3.14159}}, "-3.14"},
+		{"%8.3f", []value{{typ: vnum, v: 2}}, "   2.000"},
+		{"%g", []value{{typ: vfloat, v: 1e21}}, "1e+21"},
+		{"%g", []value{{typ: vfloat, v: 0.25}}, "0.25"},
+		{"%g", []value{{typ: vnum, v: 3}}, "3"},
+	}
+	for _, tt := range tests {
+		interp := &interp{}
+		got, ok := interp.sprintf("sprintf", tt.f, tt.args)
+		if !ok || got != tt.want {
+			t.Errorf("sprintf(%q) = %q, %v; want %q", tt.f, got, interp.err, tt.want)
+		}
+	}
+	interp := &interp{}
+	if _, ok := interp.sprintf("sprintf", "%f", []value{str("x")}); ok {
+		t.Errorf(`sprintf("%%f", "x") succeeded`)
+	}
+}
+
+func TestTableRows(t *testing.T) {
+	// Map rows are keyed like arrays with string keys, and markdown
+	// tables of positional rows use the first row as the header.
+	wantOutput(t, `
+		print(table([{"n": 1, "s": "a"}, {"s": "bb", "x": true}]));
+		print(table([["n", "s"], [22, "bb"]], "markdown"));
+	`, "n  s   x\n-  --  ----\n1  a\n   bb  true\n| n   | s   |\n| --- | --- |\n|  22 | bb  |\n")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	nativeModule("prompt", map[string]builtin{
+		"ask":      (*interp).promptAsk,
+		"confirm":  (*interp).promptConfirm,
+		"select":   (*interp).promptSelect,
+		"password": (*interp).promptPassword,
+	})
+}
+
+// readLine writes question to standard output and reads a line of input,
+// without its line ending. ok is false if the input ended before a line
+// was read.
+func (interp *interp) readLine(question string) (line string, ok bool) {
+	if question != "" {
+		fmt.Fprint(interp.out(), question+" ")
+	}
+	line, err := interp.in().ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// promptAsk asks a question and returns the answer. If the answer is empty
+// or the input has ended, it returns the optional default, or else nil.
+func (interp *interp) promptAsk(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("prompt.ask expects a question and an optional default")
+		return value{}
+	}
+	q := args[0].v.(string)
+	if len(args) == 2 {
+		q += " [" + args[1].String() + "]"
+	}
+	if line, ok := interp.readLine(q); ok && line != "" {
+		return value{typ: vstring, v: line}
+	}
+	if len(args) == 2 {
+		return args[1]
+	}
+	return value{typ: vnil}
+}
+
+// promptConfirm asks a yes-or-no question until it is answered, and
+// returns whether the answer was yes. An empty answer, or the end of
+// input, chooses the optional default, which is false if not given.
+func (interp *interp) promptConfirm(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring || len(args) == 2 && args[1].typ != vbool {
+		interp.err = fmt.Errorf("prompt.confirm expects a question and an optional default")
+		return value{}
+	}
+	def := len(args) == 2 && args[1].v.(bool)
+	q := args[0].v.(string) + " [y/N]"
+	if def {
+		q = args[0].v.(string) + " [Y/n]"
+	}
+	for {
+		line, ok := interp.readLine(q)
+		if !ok {
+			return value{typ: vbool, v: def}
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return value{typ: vbool, v: def}
+		case "y", "yes":
+			return value{typ: vbool, v: true}
+		case "n", "no":
+			return value{typ: vbool, v: false}
+		}
+	}
+}
+
+// promptSelect lists the values of an array, numbered from 1, and asks
+// for a number until a valid one is entered. It returns the chosen value,
+// or nil if the input ended.
+func (interp *interp) promptSelect(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != varray || len(args) == 2 && args[1].typ != vstring {
+		interp.err = fmt.Errorf("prompt.select expects an array of options and an optional question")
+		return value{}
+	}
+	opts := args[0].entries()
+	if len(opts) == 0 {
+		interp.err = fmt.Errorf("prompt.select: no options")
+		return value{}
+	}
+	q := "Choose 1-" + strconv.Itoa(len(opts)) + ":"
+	if len(args) == 2 {
+		q = args[1].v.(string)
+	}
+	for i, e := range opts {
+		fmt.Fprintf(interp.out(), "%d) %v\n", i+1, e.v)
+	}
+	for {
+		line, ok := interp.readLine(q)
+		if !ok {
+			return value{typ: vnil}
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && n >= 1 && n <= len(opts) {
+			return opts[n-1].v
+		}
+	}
+}
+
+// promptPassword reads a line without echoing it, if standard input is a
+// terminal. It returns nil if the input ended.
+func (interp *interp) promptPassword(args []value) value {
+	if len(args) > 1 || len(args) == 1 && args[0].typ != vstring {
+		interp.err = fmt.Errorf("prompt.password expects an optional question")
+		return value{}
+	}
+	q := "Password:"
+	if len(args) == 1 {
+		q = args[0].v.(string)
+	}
+	if interp.stdin == nil && isatty(os.Stdin) && stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(interp.out())
+		}()
+	}
+	line, ok := interp.readLine(q)
+	if !ok {
+		return value{typ: vnil}
+	}
+	return value{typ: vstring, v: line}
+}
+
+func stty(mode string) error {
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
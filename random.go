@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+func init() {
+	builtins["rand"] = (*interp).builtinRand
+	builtins["randint"] = (*interp).builtinRandInt
+	builtins["shuffle"] = (*interp).builtinShuffle
+	builtins["choice"] = (*interp).builtinChoice
+	builtins["seed"] = (*interp).builtinSeed
+}
+
+// The random builtins share a generator, which is seeded randomly unless
+// seed is called, so that a program can be made to repeat its choices.
+// They are not suitable for cryptography.
+
+func (interp *interp) random() *rand.Rand {
+	if interp.rng == nil {
+		interp.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return interp.rng
+}
+
+// builtinSeed seeds the generator with a number, so that the random
+// builtins, uuid, and nanoid return the same results each time the
+// program runs.
+func (interp *interp) builtinSeed(args []value) value {
+	if len(args) != 1 || args[0].typ != vnum {
+		interp.err = fmt.Errorf("seed expects a number")
+		return value{}
+	}
+	interp.rng = rand.New(rand.NewPCG(uint64(args[0].v.(int)), 0))
+	interp.seeded = true
+	return value{typ: vnil}
+}
+
+// builtinRand returns a random float at least 0 and less than 1.
+func (interp *interp) builtinRand(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("rand expects no arguments")
+		return value{}
+	}
+	return value{typ: vfloat, v: interp.random().Float64()}
+}
+
+// builtinRandInt returns a random number from a through b, inclusive.
+func (interp *interp) builtinRandInt(args []value) value {
+	if len(args) != 2 || args[0].typ != vnum || args[1].typ != vnum || args[0].v.(int) > args[1].v.(int) {
+		interp.err = fmt.Errorf("randint expects two numbers, the first no greater than the second")
+		return value{}
+	}
+	a, b := args[0].v.(int), args[1].v.(int)
+	// The range is computed unsigned, since it may not fit in an int.
+	var n uint64
+	if span := uint64(b-a) + 1; span == 0 {
+		n = interp.random().Uint64()
+	} else {
+		n = interp.random().Uint64N(span)
+	}
+	return value{typ: vnum, v: a + int(n)}
+}
+
+// builtinShuffle returns a new array of the values of an array in a
+// random order.
+func (interp *interp) builtinShuffle(args []value) value {
+	if len(args) != 1 || args[0].typ != varray {
+		interp.err = fmt.Errorf("shuffle expects an array")
+		return value{}
+	}
+	vs := make([]value, len(args[0].entries()))
+	for i, e := range args[0].entries() {
+		vs[i] = e.v
+	}
+	interp.random().Shuffle(len(vs), func(i, j int) { vs[i], vs[j] = vs[j], vs[i] })
+	r := newArray()
+	for i, v := range vs {
+		r.set(value{typ: vnum, v: i}, v)
+	}
+	return r
+}
+
+// builtinChoice returns a random value of a non-empty array.
+func (interp *interp) builtinChoice(args []value) value {
+	if len(args) != 1 || args[0].typ != varray || len(args[0].entries()) == 0 {
+		interp.err = fmt.Errorf("choice expects a non-empty array")
+		return value{}
+	}
+	return args[0].entries()[interp.random().IntN(len(args[0].entries()))].v
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+func init() {
+	builtins["rematch"] = (*interp).builtinReMatch
+	builtins["refind"] = (*interp).builtinReFind
+	builtins["refindall"] = (*interp).builtinReFindAll
+	builtins["rereplace"] = (*interp).builtinReReplace
+	builtins["resplit"] = (*interp).builtinReSplit
+}
+
+// Patterns use the syntax of Go's regexp package. Each is compiled once
+// and cached, so a pattern can be used in a loop without recompiling it.
+var regexps struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}
+
+// regexpArgs returns the compiled pattern and the string that are the
+// first two of the n args to the builtin fn.
+func (interp *interp) regexpArgs(fn string, args []value, n int) (*regexp.Regexp, string, bool) {
+	if len(args) != n || args[0].typ != vstring || args[1].typ != vstring {
+		interp.err = fmt.Errorf("%v expects a pattern and a string", fn)
+		return nil, "", false
+	}
+	p := args[0].v.(string)
+	regexps.Lock()
+	defer regexps.Unlock()
+	re, ok := regexps.m[p]
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(p); err != nil {
+			interp.err = fmt.Errorf("%v: %v", fn, err)
+			return nil, "", false
+		}
+		if regexps.m == nil {
+			regexps.m = make(map[string]*regexp.Regexp)
+		}
+		regexps.m[p] = re
+	}
+	return re, args[1].v.(string), true
+}
+
+// matchArray returns an array of the match of re in s at loc: the text
+// that matched at 0, and that of each group at its number and, if it is
+// named, also at its name. A group that didn't match is nil.
+func matchArray(re *regexp.Regexp, s string, loc []int) value {
+	r := newArray()
+	names := re.SubexpNames()
+	for i := 0; i < len(loc)/2; i++ {
+		g := value{typ: vnil}
+		if loc[2*i] >= 0 {
+			g = value{typ: vstring, v: s[loc[2*i]:loc[2*i+1]]}
+		}
+		r.set(value{typ: vnum, v: i}, g)
+		if names[i] != "" {
+			r.set(value{typ: vstring, v: names[i]}, g)
+		}
+	}
+	return r
+}
+
+// builtinReMatch reports whether a pattern matches any of a string.
+func (interp *interp) builtinReMatch(args []value) value {
+	re, s, ok := interp.regexpArgs("rematch", args, 2)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vbool, v: re.MatchString(s)}
+}
+
+// builtinReFind returns the first match of a pattern in a string, as an
+// array described by matchArray, or nil if there is none.
+func (interp *interp) builtinReFind(args []value) value {
+	re, s, ok := interp.regexpArgs("refind", args, 2)
+	if !ok {
+		return value{}
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return value{typ: vnil}
+	}
+	return matchArray(re, s, loc)
+}
+
+// builtinReFindAll returns an array of the successive matches of a
+// pattern in a string, each as refind returns it.
+func (interp *interp) builtinReFindAll(args []value) value {
+	re, s, ok := interp.regexpArgs("refindall", args, 2)
+	if !ok {
+		return value{}
+	}
+	r := newArray()
+	for i, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		r.set(value{typ: vnum, v: i}, matchArray(re, s, loc))
+	}
+	return r
+}
+
+// builtinReReplace returns a string with each match of a pattern replaced
+// by a replacement, in which $1 or ${name} stands for the text matched by
+// a group.
+func (interp *interp) builtinReReplace(args []value) value {
+	if len(args) != 3 || args[2].typ != vstring {
+		interp.err = fmt.Errorf("rereplace expects a pattern, a string, and a replacement")
+		return value{}
+	}
+	re, s, ok := interp.regexpArgs("rereplace", args, 3)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: re.ReplaceAllString(s, args[2].v.(string))}
+}
+
+// builtinReSplit returns an array of the parts of a string separated by
+// matches of a pattern.
+func (interp *interp) builtinReSplit(args []value) value {
+	re, s, ok := interp.regexpArgs("resplit", args, 2)
+	if !ok {
+		return value{}
+	}
+	return stringArray(re.Split(s, -1))
+}
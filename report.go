@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A report collects counters while a program runs, for the summary printed
+// by the -report flag. Nothing in it leaves the machine.
+type report struct {
+	start    time.Time
+	builtins map[string]int
+	calls    int
+	maxDepth int
+	peakObjs uint64
+	sample   []metrics.Sample
+}
+
+// sampleEvery is the number of calls between samples of the live heap
+// objects, which is too costly to read on every call.
+const sampleEvery = 256
+
+func newReport() *report {
+	r := &report{
+		start:    time.Now(),
+		builtins: make(map[string]int),
+		sample:   []metrics.Sample{{Name: "/gc/heap/objects:objects"}},
+	}
+	r.sampleHeap()
+	return r
+}
+
+func (r *report) sampleHeap() {
+	metrics.Read(r.sample)
+	if r.sample[0].Value.Kind() == metrics.KindUint64 {
+		r.peakObjs = max(r.peakObjs, r.sample[0].Value.Uint64())
+	}
+}
+
+// called records a call at depth to the function named name, which may be
+// a builtin.
+func (r *report) called(name string, isBuiltin bool, depth int) {
+	r.calls++
+	r.maxDepth = max(r.maxDepth, depth)
+	if isBuiltin {
+		r.builtins[name]++
+	}
+	if r.calls%sampleEvery == 0 {
+		r.sampleHeap()
+	}
+}
+
+// countKinds adds the number of nodes of each kind in the tree rooted at
+// n to counts.
+func countKinds(n *node, counts map[kind]int) {
+	if n == nil {
+		return
+	}
+	counts[n.kind]++
+	for _, c := range n.list {
+		countKinds(c, counts)
+	}
+}
+
+// write prints the report for a run of interp, whose main file is af.
+func (r *report) write(w io.Writer, interp *interp, af *node) {
+	r.sampleHeap()
+	counts := make(map[kind]int)
+	countKinds(af, counts)
+	files := 1
+	for _, m := range interp.modules {
+		if m.af != nil {
+			files++
+			countKinds(m.af, counts)
+		}
+	}
+	fmt.Fprintf(w, "report for %v\n", interp.main)
+	fmt.Fprintf(w, "  time:              %v\n", time.Since(r.start).Round(time.Microsecond))
+	fmt.Fprintf(w, "  files:             %v\n", files)
+	fmt.Fprintf(w, "  calls:             %v\n", r.calls)
+	fmt.Fprintf(w, "  max call depth:    %v\n", r.maxDepth)
+	fmt.Fprintf(w, "  peak heap objects: %v\n", r.peakObjs)
+	fmt.Fprintf(w, "  syntax used:\n")
+	var kinds []kind
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	for _, k := range kinds {
+		fmt.Fprintf(w, "    %-14v %v\n", strings.TrimPrefix(k.String(), "k"), counts[k])
+	}
+	if len(r.builtins) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  builtins called:\n")
+	var names []string
+	for name := range r.builtins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.builtins[names[i]] != r.builtins[names[j]] {
+			return r.builtins[names[i]] > r.builtins[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		fmt.Fprintf(w, "    %-14v %v\n", name, r.builtins[name])
+	}
+}
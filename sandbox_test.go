@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func str(s string) value { return value{typ: vstring, v: s} }
+
+func strs(ss ...string) value { return stringArray(ss) }
+
+// lookupBuiltin returns the builtin with a name like "exec" or
+// "archive.zip".
+func lookupBuiltin(t *testing.T, name string) builtin {
+	t.Helper()
+	if mod, fn, ok := strings.Cut(name, "."); ok {
+		m, ok := natives[mod]
+		if !ok {
+			t.Fatalf("no module %v", mod)
+		}
+		v, ok := m.env.m[fn]
+		if !ok {
+			t.Fatalf("no function %v", name)
+		}
+		return v.v.(*builtinFunc).fn
+	}
+	fn, ok := builtins[name]
+	if !ok {
+		t.Fatalf("no builtin %v", name)
+	}
+	return fn
+}
+
+func TestSandbox(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) value { return str(filepath.Join(dir, name)) }
+
+	var zb bytes.Buffer
+	zw := zip.NewWriter(&zb)
+	w, _ := zw.Create("a.txt")
+	w.Write([]byte("a"))
+	zw.Close()
+	zipBytes := value{typ: vbytes, v: zb.Bytes()}
+	pic := value{typ: vhandle, v: &img{m: image.NewRGBA(image.Rect(0, 0, 1, 1)), format: "png"}}
+
+	tests := []struct {
+		fn   string
+		args []value
+	}{
+		{"clipboard.read", nil},
+		{"clipboard.write", []value{str("x")}},
+		{"notify", []value{str("title"), str("message")}},
+		{"getenv", []value{str("HOME")}},
+		{"setenv", []value{str("REFGC_SANDBOX_TEST"), str("x")}},
+		{"exec", []value{str("true")}},
+		{"fs.listdir", []value{str(dir)}},
+		{"fs.exists", []value{str(dir)}},
+		{"fs.mkdir", []value{path("d")}},
+		{"fs.remove", []value{str(dir)}},
+		{"fs.stat", []value{str(dir)}},
+		{"resource.read", []value{str("x")}},
+		{"resource.readbytes", []value{str("x")}},
+		{"httpget", []value{str("http://127.0.0.1:1/")}},
+		{"httppost", []value{str("http://127.0.0.1:1/"), str("body")}},
+		{"tcp.dial", []value{str("127.0.0.1:1")}},
+		{"tcp.listen", []value{str("127.0.0.1:0")}},
+		{"archive.zip", []value{path("a.zip"), strs(dir)}},
+		{"archive.tar", []value{path("a.tar"), strs(dir)}},
+		{"archive.unzip", []value{path("a.zip"), path("out")}},
+		{"archive.unzip", []value{zipBytes, path("out")}},
+		{"archive.untar", []value{path("a.tar"), path("out")}},
+		{"archive.ziplist", []value{path("a.zip")}},
+		{"archive.zipread", []value{path("a.zip"), str("a.txt")}},
+		{"image.info", []value{path("a.png")}},
+		{"image.open", []value{path("a.png")}},
+		{"image.save", []value{pic, path("a.png")}},
+		{"i18n.load", []value{str(dir)}},
+		{"ws.connect", []value{str("ws://127.0.0.1:1/")}},
+		{"grpc.load", []value{path("a.pb")}},
+		{"grpc.dial", []value{str("127.0.0.1:1")}},
+		{"mq.connect", []value{str("mqtt://127.0.0.1:1")}},
+	}
+	if _, ok := natives["ffi"]; ok {
+		tests = append(tests, struct {
+			fn   string
+			args []value
+		}{"ffi.open", []value{str("libc.so.6")}})
+	}
+	for _, tt := range tests {
+		interp := &interp{sandbox: true, main: path("main.x").v.(string)}
+		lookupBuiltin(t, tt.fn)(interp, tt.args)
+		if interp.err == nil || !strings.Contains(interp.err.Error(), "not allowed in the sandbox") {
+			t.Errorf("%v in the sandbox: got error %v", tt.fn, interp.err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	builtins["schedule"] = (*interp).builtinSchedule
+	builtins["unschedule"] = (*interp).builtinUnschedule
+	builtins["run_scheduler"] = (*interp).builtinRunScheduler
+}
+
+// A cronSpec is a parsed cron expression. Each field is a bit set of the
+// values it matches.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields were *, since a
+	// day matches if either restricted field does.
+	domStar, dowStar bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a standard five-field cron expression: minute, hour,
+// day of month, month, and day of week (0 is Sunday). Each field is a
+// comma-separated list of *, n, or a-b, optionally followed by /step.
+func parseCron(s string) (*cronSpec, error) {
+	if m, ok := cronMacros[s]; ok {
+		s = m
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", s)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", s, err)
+		}
+		sets[i] = set
+	}
+	// 7 is another name for Sunday.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSpec{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(f string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(f, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step, part = n, part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			var err error
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(part)
+				hi = lo
+				if step > 1 {
+					hi = max
+				}
+			}
+			if err != nil || lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time after t matched by c, or the zero time if
+// there isn't one within five years.
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// A job is a function scheduled to run at the times matched by spec.
+type job struct {
+	id   int
+	spec *cronSpec
+	fn   value
+	next time.Time
+}
+
+// builtinSchedule registers a function to be called by run_scheduler at
+// the times matched by a cron expression, and returns an id that can be
+// passed to unschedule.
+func (interp *interp) builtinSchedule(args []value) value {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vfunc {
+		interp.err = fmt.Errorf("schedule expects a cron expression and a function")
+		return value{}
+	}
+	spec, err := parseCron(args[0].v.(string))
+	if err != nil {
+		interp.err = err
+		return value{}
+	}
+	interp.nextJob++
+	interp.jobs = append(interp.jobs, &job{id: interp.nextJob, spec: spec, fn: args[1]})
+	return value{typ: vnum, v: interp.nextJob}
+}
+
+func (interp *interp) builtinUnschedule(args []value) value {
+	if len(args) != 1 || args[0].typ != vnum {
+		interp.err = fmt.Errorf("unschedule expects a job id")
+		return value{}
+	}
+	for i, j := range interp.jobs {
+		if j.id == args[0].v.(int) {
+			interp.jobs = append(interp.jobs[:i], interp.jobs[i+1:]...)
+			return value{typ: vbool, v: true}
+		}
+	}
+	return value{typ: vbool, v: false}
+}
+
+// builtinRunScheduler runs scheduled jobs until none are left, or until
+// the optional number of milliseconds has passed. Jobs due at the same
+// time run in the order they were scheduled.
+func (interp *interp) builtinRunScheduler(args []value) value {
+	if len(args) > 1 || len(args) == 1 && args[0].typ != vnum {
+		interp.err = fmt.Errorf("run_scheduler expects an optional duration")
+		return value{}
+	}
+	var deadline time.Time
+	if len(args) == 1 {
+		deadline = time.Now().Add(time.Duration(args[0].v.(int)) * time.Millisecond)
+	}
+	for interp.err == nil {
+		now := time.Now()
+		var due time.Time
+		for _, j := range interp.jobs {
+			if j.next.IsZero() {
+				j.next = j.spec.next(now)
+			}
+			if j.next.IsZero() {
+				continue
+			}
+			if due.IsZero() || j.next.Before(due) {
+				due = j.next
+			}
+		}
+		if due.IsZero() {
+			break
+		}
+		if !deadline.IsZero() && due.After(deadline) {
+			time.Sleep(time.Until(deadline))
+			break
+		}
+		time.Sleep(time.Until(due))
+		for _, j := range append([]*job(nil), interp.jobs...) {
+			if !j.next.IsZero() && !j.next.After(due) {
+				j.next = time.Time{}
+				interp.call(j.fn, nil)
+			}
+		}
+	}
+	return value{}
+}
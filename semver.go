@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	nativeModule("semver", map[string]builtin{
+		"parse":     (*interp).semverParse,
+		"compare":   (*interp).semverCompare,
+		"satisfies": (*interp).semverSatisfies,
+	})
+}
+
+// A version is a semantic version, as described at https://semver.org.
+type version struct {
+	major, minor, patch int
+	pre                 []string // dot-separated prerelease identifiers
+	build               string
+}
+
+// parseVersion parses a version, with an optional leading "v". If partial
+// is true, trailing components may be omitted or written as x or *, and n
+// is the number of components given.
+func parseVersion(s string, partial bool) (v version, n int, err error) {
+	orig := s
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s, v.build = s[:i], s[i+1:]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		var pre string
+		s, pre = s[:i], s[i+1:]
+		v.pre = strings.Split(pre, ".")
+		for _, id := range v.pre {
+			if id == "" {
+				return version{}, 0, fmt.Errorf("invalid version %q", orig)
+			}
+		}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || !partial && len(parts) != 3 {
+		return version{}, 0, fmt.Errorf("invalid version %q", orig)
+	}
+	nums := [3]*int{&v.major, &v.minor, &v.patch}
+	for i, p := range parts {
+		if partial && (p == "x" || p == "X" || p == "*") {
+			break
+		}
+		x, err := strconv.Atoi(p)
+		if err != nil || x < 0 || p[0] == '+' || len(p) > 1 && p[0] == '0' {
+			return version{}, 0, fmt.Errorf("invalid version %q", orig)
+		}
+		*nums[i] = x
+		n++
+	}
+	if n < 3 && v.pre != nil {
+		return version{}, 0, fmt.Errorf("invalid version %q", orig)
+	}
+	return v, n, nil
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare returns -1, 0, or 1 as v has lower, equal, or higher precedence
+// than w. Build metadata is ignored, and a prerelease precedes the release.
+func (v version) compare(w version) int {
+	if c := cmpInt(v.major, w.major); c != 0 {
+		return c
+	}
+	if c := cmpInt(v.minor, w.minor); c != 0 {
+		return c
+	}
+	if c := cmpInt(v.patch, w.patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.pre == nil && w.pre == nil:
+		return 0
+	case v.pre == nil:
+		return 1
+	case w.pre == nil:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, erra := strconv.Atoi(v.pre[i])
+		b, errb := strconv.Atoi(w.pre[i])
+		var c int
+		switch {
+		case erra == nil && errb == nil:
+			c = cmpInt(a, b)
+		case erra == nil:
+			c = -1
+		case errb == nil:
+			c = 1
+		default:
+			c = strings.Compare(v.pre[i], w.pre[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(v.pre), len(w.pre))
+}
+
+// A comparator matches versions v for which v.compare(ver) is one of ok.
+type comparator struct {
+	ver version
+	ok  []int
+}
+
+func (c comparator) matches(v version) bool {
+	r := v.compare(c.ver)
+	for _, ok := range c.ok {
+		if r == ok {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	verLT = []int{-1}
+	verLE = []int{-1, 0}
+	verEQ = []int{0}
+	verGE = []int{0, 1}
+	verGT = []int{1}
+)
+
+// bump returns the lowest version above every version matching the first
+// n components of v.
+func bump(v version, n int) version {
+	switch n {
+	case 0:
+		return version{major: math.MaxInt}
+	case 1:
+		return version{major: v.major + 1}
+	case 2:
+		return version{major: v.major, minor: v.minor + 1}
+	}
+	return version{major: v.major, minor: v.minor, patch: v.patch + 1}
+}
+
+var rangeOps = []string{">=", "<=", ">", "<", "=", "^", "~"}
+
+// parseComparator parses one comparator of a range, which may expand to
+// two bounds. Versions in comparators may be partial.
+func parseComparator(s string) ([]comparator, error) {
+	op := ""
+	for _, o := range rangeOps {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	v, n, err := parseVersion(s[len(op):], true)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "", "=":
+		if n == 3 {
+			return []comparator{{v, verEQ}}, nil
+		}
+		return []comparator{{v, verGE}, {bump(v, n), verLT}}, nil
+	case ">=":
+		return []comparator{{v, verGE}}, nil
+	case ">":
+		if n == 3 {
+			return []comparator{{v, verGT}}, nil
+		}
+		return []comparator{{bump(v, n), verGE}}, nil
+	case "<":
+		return []comparator{{v, verLT}}, nil
+	case "<=":
+		if n == 3 {
+			return []comparator{{v, verLE}}, nil
+		}
+		return []comparator{{bump(v, n), verLT}}, nil
+	case "~":
+		// Patch releases are allowed, or minor releases if no minor
+		// version is given.
+		return []comparator{{v, verGE}, {bump(v, min(n, 2)), verLT}}, nil
+	}
+	// ^: releases that don't change the first nonzero component given
+	// are allowed.
+	k := 3
+	switch {
+	case v.major != 0 || n <= 1:
+		k = min(n, 1)
+	case v.minor != 0 || n == 2:
+		k = 2
+	}
+	return []comparator{{v, verGE}, {bump(v, k), verLT}}, nil
+}
+
+// parseRange parses a range: sets of comparators separated by ||, where
+// a version satisfies a set if it satisfies every comparator in it.
+func parseRange(s string) ([][]comparator, error) {
+	var sets [][]comparator
+	for _, alt := range strings.Split(s, "||") {
+		set := []comparator{}
+		fields := strings.Fields(alt)
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			// Allow a space between an operator and its version.
+			for _, o := range rangeOps {
+				if f == o && i+1 < len(fields) {
+					i++
+					f += fields[i]
+					break
+				}
+			}
+			cs, err := parseComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: %v", s, err)
+			}
+			set = append(set, cs...)
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+func satisfies(v version, sets [][]comparator) bool {
+	for _, set := range sets {
+		ok := true
+		for _, c := range set {
+			ok = ok && c.matches(v)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (interp *interp) versionArg(fn string, v value) (version, bool) {
+	if v.typ != vstring {
+		interp.err = fmt.Errorf("%v expects a version string", fn)
+		return version{}, false
+	}
+	ver, _, err := parseVersion(v.v.(string), false)
+	if err != nil {
+		interp.err = fmt.Errorf("%v: %v", fn, err)
+		return version{}, false
+	}
+	return ver, true
+}
+
+// semverParse returns an array with the keys "major", "minor", "patch",
+// "pre", and "build".
+func (interp *interp) semverParse(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("semver.parse expects a version string")
+		return value{}
+	}
+	if args[0].typ != vstring {
+		interp.err = fmt.Errorf("semver.parse expects a version string")
+		return value{}
+	}
+	v, _, err := parseVersion(args[0].v.(string), false)
+	if err != nil {
+		return interp.failure(fmt.Errorf("semver.parse: %v", err))
+	}
+	r := newArray()
+	r.set(value{typ: vstring, v: "major"}, value{typ: vnum, v: v.major})
+	r.set(value{typ: vstring, v: "minor"}, value{typ: vnum, v: v.minor})
+	r.set(value{typ: vstring, v: "patch"}, value{typ: vnum, v: v.patch})
+	r.set(value{typ: vstring, v: "pre"}, value{typ: vstring, v: strings.Join(v.pre, ".")})
+	r.set(value{typ: vstring, v: "build"}, value{typ: vstring, v: v.build})
+	return r
+}
+
+// semverCompare returns -1, 0, or 1 as the first version has lower, equal,
+// or higher precedence than the second.
+func (interp *interp) semverCompare(args []value) value {
+	if len(args) != 2 {
+		interp.err = fmt.Errorf("semver.compare expects two version strings")
+		return value{}
+	}
+	v, ok1 := interp.versionArg("semver.compare", args[0])
+	w, ok2 := interp.versionArg("semver.compare", args[1])
+	if !ok1 || !ok2 {
+		return value{}
+	}
+	return value{typ: vnum, v: v.compare(w)}
+}
+
+// semverSatisfies reports whether a version is in a range such as
+// ">=1.2 <2", "^1.4.0 || ~2.1", or "1.x".
+func (interp *interp) semverSatisfies(args []value) value {
+	if len(args) != 2 || args[1].typ != vstring {
+		interp.err = fmt.Errorf("semver.satisfies expects a version and a range")
+		return value{}
+	}
+	v, ok := interp.versionArg("semver.satisfies", args[0])
+	if !ok {
+		return value{}
+	}
+	sets, err := parseRange(args[1].v.(string))
+	if err != nil {
+		interp.err = fmt.Errorf("semver.satisfies: %v", err)
+		return value{}
+	}
+	return value{typ: vbool, v: satisfies(v, sets)}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	builtins["set"] = (*interp).builtinSet
+	builtins["has"] = (*interp).builtinHas
+	builtins["add"] = (*interp).builtinAdd
+	builtins["remove"] = (*interp).builtinRemove
+	builtins["union"] = setCombine("union", func(a, b bool) bool { return a || b })
+	builtins["intersect"] = setCombine("intersect", func(a, b bool) bool { return a && b })
+	builtins["difference"] = setCombine("difference", func(a, b bool) bool { return a && !b })
+}
+
+// A set is a hashMap from its elements to true, so its elements may be
+// values of the same types as map keys. Its elements are kept in the
+// order they were added, and iterating over it yields them with their
+// positions as keys.
+
+func newSet() value {
+	return value{typ: vset, v: newHashMap()}
+}
+
+func setString(m *hashMap) string {
+	var sb strings.Builder
+	sb.WriteString("#{")
+	for i, e := range m.items() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(e.k.elem())
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (interp *interp) evalSetLit(nod *node) value {
+	s := newSet()
+	for _, e := range nod.list {
+		x := interp.evalRvalue(e)
+		if interp.err != nil {
+			return value{}
+		}
+		interp.mapSet(s.v.(*hashMap), x, value{typ: vbool, v: true})
+	}
+	return s
+}
+
+// builtinSet returns a set of the elements of an optional iterable value.
+func (interp *interp) builtinSet(args []value) value {
+	if len(args) > 1 || len(args) == 1 && !iterable(args[0]) {
+		interp.err = fmt.Errorf("set expects an optional iterable value")
+		return value{}
+	}
+	s := newSet()
+	if len(args) == 1 {
+		interp.iterate(args[0], func(k, v value) bool {
+			interp.mapSet(s.v.(*hashMap), v, value{typ: vbool, v: true})
+			return interp.err == nil
+		})
+	}
+	if interp.err != nil {
+		return value{}
+	}
+	return s
+}
+
+// builtinHas reports whether a set has an element, or a map a key.
+func (interp *interp) builtinHas(args []value) value {
+	if len(args) != 2 || args[0].typ != vset && args[0].typ != vmap {
+		interp.err = fmt.Errorf("has expects a set or map and a value")
+		return value{}
+	}
+	h, ok := interp.mapKey(args[1])
+	if !ok {
+		return value{}
+	}
+	_, ok = args[0].v.(*hashMap).index[h]
+	return value{typ: vbool, v: ok}
+}
+
+// builtinAdd adds an element to a set.
+func (interp *interp) builtinAdd(args []value) value {
+	if len(args) != 2 || args[0].typ != vset {
+		interp.err = fmt.Errorf("add expects a set and a value")
+		return value{}
+	}
+	interp.mapSet(args[0].v.(*hashMap), args[1], value{typ: vbool, v: true})
+	return value{}
+}
+
+// builtinRemove removes an element from a set, or an entry from a map,
+// and reports whether it was there.
+func (interp *interp) builtinRemove(args []value) value {
+	if len(args) != 2 || args[0].typ != vset && args[0].typ != vmap {
+		interp.err = fmt.Errorf("remove expects a set or map and a value")
+		return value{}
+	}
+	ok := interp.mapDelete(args[0].v.(*hashMap), args[1])
+	if interp.err != nil {
+		return value{}
+	}
+	return value{typ: vbool, v: ok}
+}
+
+// setCombine returns a builtin that returns a new set of the elements of
+// two sets for which keep, given whether each set has it, returns true.
+// The elements of the first set come first.
+func setCombine(name string, keep func(a, b bool) bool) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) != 2 || args[0].typ != vset || args[1].typ != vset {
+			interp.err = fmt.Errorf("%v expects two sets", name)
+			return value{}
+		}
+		a, b := args[0].v.(*hashMap), args[1].v.(*hashMap)
+		r := newHashMap()
+		for _, s := range []*hashMap{a, b} {
+			for _, e := range s.items() {
+				h, _ := hashKey(e.k)
+				_, inA := a.index[h]
+				_, inB := b.index[h]
+				if _, done := r.index[h]; !done && keep(inA, inB) {
+					r.index[h] = len(r.entries)
+					r.entries = append(r.entries, e)
+				}
+			}
+		}
+		return value{typ: vset, v: r}
+	}
+}
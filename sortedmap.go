@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	builtins["sortedmap"] = (*interp).builtinSortedMap
+	builtins["between"] = (*interp).builtinBetween
+}
+
+// A sortedMap is a map whose entries are kept sorted by key, so that they
+// can be iterated in order and ranges of keys found by binary search.
+type sortedMap struct {
+	cmp     value // comparison function, or nil for the natural order
+	entries []struct {
+		k value
+		v value
+	}
+	watchers *watchers
+	frozen   bool
+}
+
+// compare returns a negative number, zero, or a positive number as a is
+// less than, equal to, or greater than b. ok is false if an error
+// occurred.
+func (interp *interp) compare(m *sortedMap, a, b value) (c int, ok bool) {
+	if m.cmp.typ == vfunc {
+		r := interp.call(m.cmp, []value{a, b})
+		if interp.err != nil {
+			return 0, false
+		}
+		if r.typ != vnum {
+			interp.err = fmt.Errorf("sortedmap comparison function returned %v, not a number", r.typ)
+			return 0, false
+		}
+		return r.v.(int), true
+	}
+	switch {
+	case a.typ == vnum && b.typ == vnum:
+		return cmpInt(a.v.(int), b.v.(int)), true
+	case a.typ == vstring && b.typ == vstring:
+		return strings.Compare(a.v.(string), b.v.(string)), true
+	}
+	interp.err = fmt.Errorf("sortedmap cannot compare %v and %v", a.typ, b.typ)
+	return 0, false
+}
+
+// search returns the index of the first entry whose key is not less than
+// k, and whether its key equals k.
+func (interp *interp) search(m *sortedMap, k value) (i int, found bool) {
+	lo, hi := 0, len(m.entries)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		c, ok := interp.compare(m, m.entries[mid].k, k)
+		if !ok {
+			return 0, false
+		}
+		if c < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(m.entries) {
+		c, ok := interp.compare(m, m.entries[lo].k, k)
+		return lo, ok && c == 0
+	}
+	return lo, false
+}
+
+func (interp *interp) sortedGet(m *sortedMap, k value) value {
+	i, found := interp.search(m, k)
+	if !found {
+		return value{typ: vnil}
+	}
+	return m.entries[i].v
+}
+
+func (interp *interp) sortedSet(m *sortedMap, k, v value) {
+	if m.frozen {
+		interp.err = fmt.Errorf("cannot modify frozen sorted map")
+		return
+	}
+	i, found := interp.search(m, k)
+	switch {
+	case interp.err != nil:
+	case found:
+		m.entries[i].v = v
+	default:
+		m.entries = append(m.entries, struct{ k, v value }{})
+		copy(m.entries[i+1:], m.entries[i:])
+		m.entries[i].k, m.entries[i].v = k, v
+	}
+}
+
+// builtinSortedMap returns an empty sorted map. Its keys are ordered by
+// an optional comparison function, which returns a negative number, zero,
+// or a positive number as its first argument is less than, equal to, or
+// greater than its second. By default, numbers and strings are ordered
+// naturally.
+func (interp *interp) builtinSortedMap(args []value) value {
+	if len(args) > 1 || len(args) == 1 && args[0].typ != vfunc {
+		interp.err = fmt.Errorf("sortedmap expects an optional comparison function")
+		return value{}
+	}
+	m := new(sortedMap)
+	if len(args) == 1 {
+		m.cmp = args[0]
+	}
+	return value{typ: vsortedmap, v: m}
+}
+
+// builtinBetween returns an array of the entries of a sorted map whose
+// keys are between lo and hi inclusive, in order.
+func (interp *interp) builtinBetween(args []value) value {
+	if len(args) != 3 || args[0].typ != vsortedmap {
+		interp.err = fmt.Errorf("between expects a sorted map and two keys")
+		return value{}
+	}
+	m := args[0].v.(*sortedMap)
+	lo, _ := interp.search(m, args[1])
+	r := new(array)
+	for _, e := range m.entries[lo:] {
+		if c, ok := interp.compare(m, e.k, args[2]); !ok || c > 0 {
+			break
+		}
+		r.entries = append(r.entries, e)
+	}
+	if interp.err != nil {
+		return value{}
+	}
+	return value{typ: varray, v: r}
+}
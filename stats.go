@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+func init() {
+	nativeModule("stats", map[string]builtin{
+		"mean":       (*interp).statsMean,
+		"median":     (*interp).statsMedian,
+		"stddev":     (*interp).statsStddev,
+		"percentile": (*interp).statsPercentile,
+		"histogram":  (*interp).statsHistogram,
+	})
+}
+
+// Since numbers are integers, results that may be fractional are rounded
+// to the nearest integer.
+
+// nums returns the numbers in the array v, which must not be empty.
+func (interp *interp) nums(fn string, v value) []int {
+	if v.typ != varray || len(v.entries()) == 0 {
+		interp.err = fmt.Errorf("%v expects a non-empty array of numbers", fn)
+		return nil
+	}
+	ns := make([]int, len(v.entries()))
+	for i, e := range v.entries() {
+		if e.v.typ != vnum {
+			interp.err = fmt.Errorf("%v expects a non-empty array of numbers", fn)
+			return nil
+		}
+		ns[i] = e.v.v.(int)
+	}
+	return ns
+}
+
+func round(f float64) value {
+	return value{typ: vnum, v: int(math.Round(f))}
+}
+
+// meanVar returns the mean and population variance of ns in one pass,
+// using Welford's algorithm.
+func meanVar(ns []int) (mean, variance float64) {
+	var m2 float64
+	for i, n := range ns {
+		d := float64(n) - mean
+		mean += d / float64(i+1)
+		m2 += d * (float64(n) - mean)
+	}
+	return mean, m2 / float64(len(ns))
+}
+
+// percentile returns the pth percentile of the sorted numbers ns,
+// interpolating linearly between the closest ranks.
+func percentile(ns []int, p float64) float64 {
+	r := p / 100 * float64(len(ns)-1)
+	i := int(r)
+	if i+1 >= len(ns) {
+		return float64(ns[len(ns)-1])
+	}
+	return float64(ns[i]) + (r-float64(i))*float64(ns[i+1]-ns[i])
+}
+
+func (interp *interp) statsMean(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("stats.mean expects an array of numbers")
+		return value{}
+	}
+	ns := interp.nums("stats.mean", args[0])
+	if ns == nil {
+		return value{}
+	}
+	mean, _ := meanVar(ns)
+	return round(mean)
+}
+
+// statsStddev returns the population standard deviation.
+func (interp *interp) statsStddev(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("stats.stddev expects an array of numbers")
+		return value{}
+	}
+	ns := interp.nums("stats.stddev", args[0])
+	if ns == nil {
+		return value{}
+	}
+	_, variance := meanVar(ns)
+	return round(math.Sqrt(variance))
+}
+
+func (interp *interp) statsMedian(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("stats.median expects an array of numbers")
+		return value{}
+	}
+	ns := interp.nums("stats.median", args[0])
+	if ns == nil {
+		return value{}
+	}
+	sort.Ints(ns)
+	return round(percentile(ns, 50))
+}
+
+// statsPercentile returns the pth percentile, for p from 0 to 100.
+func (interp *interp) statsPercentile(args []value) value {
+	if len(args) != 2 || args[1].typ != vnum {
+		interp.err = fmt.Errorf("stats.percentile expects an array of numbers and a percentile")
+		return value{}
+	}
+	ns := interp.nums("stats.percentile", args[0])
+	if ns == nil {
+		return value{}
+	}
+	p := args[1].v.(int)
+	if p < 0 || p > 100 {
+		interp.err = fmt.Errorf("stats.percentile: percentile %v is not between 0 and 100", p)
+		return value{}
+	}
+	sort.Ints(ns)
+	return round(percentile(ns, float64(p)))
+}
+
+// statsHistogram divides the range of the numbers into a number of buckets
+// of equal width, 10 by default, and returns an array of buckets, each an
+// array with the keys "lo" and "hi", its inclusive bounds, and "count".
+func (interp *interp) statsHistogram(args []value) value {
+	if len(args) < 1 || len(args) > 2 || len(args) == 2 && args[1].typ != vnum {
+		interp.err = fmt.Errorf("stats.histogram expects an array of numbers and an optional number of buckets")
+		return value{}
+	}
+	ns := interp.nums("stats.histogram", args[0])
+	if ns == nil {
+		return value{}
+	}
+	buckets := 10
+	if len(args) == 2 {
+		buckets = args[1].v.(int)
+	}
+	if buckets <= 0 {
+		interp.err = fmt.Errorf("stats.histogram: invalid number of buckets %v", buckets)
+		return value{}
+	}
+	lo, hi := ns[0], ns[0]
+	for _, n := range ns {
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	width := (hi - lo + buckets) / buckets // ceil((hi-lo+1) / buckets)
+	counts := make([]int, buckets)
+	for _, n := range ns {
+		counts[(n-lo)/width]++
+	}
+	r := newArray()
+	for i, c := range counts {
+		b := newArray()
+		b.set(value{typ: vstring, v: "lo"}, value{typ: vnum, v: lo + i*width})
+		b.set(value{typ: vstring, v: "hi"}, value{typ: vnum, v: lo + (i+1)*width - 1})
+		b.set(value{typ: vstring, v: "count"}, value{typ: vnum, v: c})
+		r.set(value{typ: vnum, v: i}, b)
+	}
+	return r
+}
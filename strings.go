@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	builtins["split"] = (*interp).builtinSplit
+	builtins["join"] = (*interp).builtinJoin
+	builtins["trim"] = (*interp).builtinTrim
+	builtins["upper"] = stringFunc("upper", strings.ToUpper)
+	builtins["lower"] = stringFunc("lower", strings.ToLower)
+	builtins["replace"] = (*interp).builtinReplace
+	builtins["contains"] = stringTest("contains", strings.Contains)
+	builtins["startswith"] = stringTest("startswith", strings.HasPrefix)
+	builtins["endswith"] = stringTest("endswith", strings.HasSuffix)
+	builtins["indexof"] = (*interp).builtinIndexOf
+	builtins["repeat"] = (*interp).builtinRepeat
+}
+
+// Like len and slice, the string builtins count positions in characters,
+// not bytes.
+
+// stringArgs returns args, which must be n strings followed by up to
+// optional more, as Go strings.
+func (interp *interp) stringArgs(fn string, args []value, n, optional int) ([]string, bool) {
+	if len(args) < n || len(args) > n+optional {
+		interp.err = fmt.Errorf("%v expects %v string arguments", fn, n)
+		return nil, false
+	}
+	ss := make([]string, len(args))
+	for i, a := range args {
+		if a.typ != vstring {
+			interp.err = fmt.Errorf("%v expects strings, not %v", fn, a.typ)
+			return nil, false
+		}
+		ss[i] = a.v.(string)
+	}
+	return ss, true
+}
+
+// stringFunc returns a builtin named name that returns f of a string.
+func stringFunc(name string, f func(string) string) builtin {
+	return func(interp *interp, args []value) value {
+		ss, ok := interp.stringArgs(name, args, 1, 0)
+		if !ok {
+			return value{}
+		}
+		return value{typ: vstring, v: f(ss[0])}
+	}
+}
+
+// stringTest returns a builtin named name that reports f of two strings.
+func stringTest(name string, f func(s, t string) bool) builtin {
+	return func(interp *interp, args []value) value {
+		ss, ok := interp.stringArgs(name, args, 2, 0)
+		if !ok {
+			return value{}
+		}
+		return value{typ: vbool, v: f(ss[0], ss[1])}
+	}
+}
+
+// builtinSplit returns an array of the parts of a string separated by a
+// separator, or by runs of white space if none is given.
+func (interp *interp) builtinSplit(args []value) value {
+	ss, ok := interp.stringArgs("split", args, 1, 1)
+	if !ok {
+		return value{}
+	}
+	if len(ss) == 1 {
+		return stringArray(strings.Fields(ss[0]))
+	}
+	return stringArray(strings.Split(ss[0], ss[1]))
+}
+
+// builtinJoin returns the strings of an array joined by a separator.
+func (interp *interp) builtinJoin(args []value) value {
+	if len(args) != 2 || args[0].typ != varray || args[1].typ != vstring {
+		interp.err = fmt.Errorf("join expects an array of strings and a separator")
+		return value{}
+	}
+	ss := make([]string, len(args[0].entries()))
+	for i, e := range args[0].entries() {
+		if e.v.typ != vstring {
+			interp.err = fmt.Errorf("join expects an array of strings, not %v", e.v.typ)
+			return value{}
+		}
+		ss[i] = e.v.v.(string)
+	}
+	return value{typ: vstring, v: strings.Join(ss, args[1].v.(string))}
+}
+
+// builtinTrim returns a string without the characters of an optional
+// set at its start and end, or without white space if none is given.
+func (interp *interp) builtinTrim(args []value) value {
+	ss, ok := interp.stringArgs("trim", args, 1, 1)
+	if !ok {
+		return value{}
+	}
+	if len(ss) == 1 {
+		return value{typ: vstring, v: strings.TrimSpace(ss[0])}
+	}
+	return value{typ: vstring, v: strings.Trim(ss[0], ss[1])}
+}
+
+// builtinReplace returns a string with each occurrence of old replaced by
+// new, or only the first n if a number n is given.
+func (interp *interp) builtinReplace(args []value) value {
+	n := -1
+	if len(args) == 4 {
+		if args[3].typ != vnum {
+			interp.err = fmt.Errorf("replace expects a number of replacements")
+			return value{}
+		}
+		n, args = args[3].v.(int), args[:3]
+	}
+	ss, ok := interp.stringArgs("replace", args, 3, 0)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: strings.Replace(ss[0], ss[1], ss[2], n)}
+}
+
+// builtinIndexOf returns the position of the first occurrence of a
+// substring in a string, or -1 if there is none.
+func (interp *interp) builtinIndexOf(args []value) value {
+	ss, ok := interp.stringArgs("indexof", args, 2, 0)
+	if !ok {
+		return value{}
+	}
+	i := strings.Index(ss[0], ss[1])
+	if i > 0 {
+		i = utf8.RuneCountInString(ss[0][:i])
+	}
+	return value{typ: vnum, v: i}
+}
+
+// builtinRepeat returns a string repeated a number of times.
+func (interp *interp) builtinRepeat(args []value) value {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vnum || args[1].v.(int) < 0 {
+		interp.err = fmt.Errorf("repeat expects a string and a non-negative number")
+		return value{}
+	}
+	s, n := args[0].v.(string), args[1].v.(int)
+	if n > 0 && len(s)*n/n != len(s) {
+		interp.err = fmt.Errorf("repeat: result is too long")
+		return value{}
+	}
+	return value{typ: vstring, v: strings.Repeat(s, n)}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A structType is the value of a struct expression. Calling it creates a
+// record with its fields.
+type structType struct {
+	name   string // the name it was first assigned to, if any
+	fields []string
+}
+
+// A record is a value with the fields of a struct type. Unlike an array,
+// it has a fixed set of keys, and using any other is an error.
+type record struct {
+	typ    *structType
+	fields []value
+	frozen bool
+}
+
+func (t *structType) String() string {
+	if t.name != "" {
+		return t.name
+	}
+	return "struct {" + strings.Join(t.fields, ", ") + "}"
+}
+
+func (r *record) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.typ.String())
+	sb.WriteString("{")
+	for i, f := range r.typ.fields {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%v: %v", f, r.fields[i].elem())
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// eq reports whether r and r2 have the same type and equal fields. seen
+// is as for value.equal.
+func (r *record) eq(r2 *record, seen map[visit]bool) bool {
+	if r.typ != r2.typ {
+		return false
+	}
+	for i := range r.fields {
+		if !r.fields[i].equal(r2.fields[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldIndex returns the position of the field name in t, or -1.
+func (t *structType) fieldIndex(name string) int {
+	for i, f := range t.fields {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (interp *interp) evalStruct(nod *node) value {
+	t := &structType{}
+	for _, f := range nod.list {
+		t.fields = append(t.fields, f.value.text)
+	}
+	return value{typ: vstruct, v: t}
+}
+
+// newRecord returns a record of type t whose fields are set to args in
+// order. Fields without an argument are nil.
+func (interp *interp) newRecord(t *structType, args []value) value {
+	if len(args) > len(t.fields) {
+		interp.err = fmt.Errorf("too many fields for %v: %v > %v", t, len(args), len(t.fields))
+		return value{}
+	}
+	r := &record{typ: t, fields: make([]value, len(t.fields))}
+	for i := range r.fields {
+		r.fields[i] = value{typ: vnil}
+		if i < len(args) && args[i].typ != verr {
+			r.fields[i] = args[i]
+		}
+	}
+	return value{typ: vrecord, v: r}
+}
+
+// field returns the field name of r.
+func (interp *interp) field(r *record, name string) value {
+	i := r.typ.fieldIndex(name)
+	if i < 0 {
+		interp.err = fmt.Errorf("%v has no field %v", r.typ, name)
+		return value{}
+	}
+	return r.fields[i]
+}
+
+// setField sets the field name of r to v.
+func (interp *interp) setField(r *record, name string, v value) {
+	i := r.typ.fieldIndex(name)
+	if i < 0 {
+		interp.err = fmt.Errorf("%v has no field %v", r.typ, name)
+		return
+	}
+	if r.frozen {
+		interp.err = fmt.Errorf("cannot modify frozen %v", r.typ)
+		return
+	}
+	r.fields[i] = v
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	builtins["table"] = (*interp).builtinTable
+	builtins["sparkline"] = (*interp).builtinSparkline
+}
+
+// tableCells returns the header and rows of a table built from rows, which
+// is an array of rows. If the rows are arrays or maps with string keys,
+// the header is the keys in the order they were first seen, and missing
+// cells are empty. Otherwise, there is no header and each row's values
+// are its cells. Numeric cells are marked in num so they can be
+// right-aligned.
+func tableCells(rows value) (header []string, cells [][]string, num [][]bool) {
+	keyed := false
+	var keys []value
+	seen := make(map[string]bool)
+	for _, r := range rows.entries() {
+		for _, e := range rowEntries(r.v) {
+			if e.k.typ != vstring {
+				continue
+			}
+			keyed = true
+			if k := e.k.v.(string); !seen[k] {
+				seen[k] = true
+				keys = append(keys, e.k)
+				header = append(header, k)
+			}
+		}
+	}
+	for _, r := range rows.entries() {
+		var row []string
+		var isnum []bool
+		add := func(v value) {
+			if v.typ == vnil {
+				row = append(row, "")
+			} else {
+				row = append(row, v.String())
+			}
+			isnum = append(isnum, v.typ == vnum)
+		}
+		switch {
+		case keyed:
+			for _, k := range keys {
+				add(rowCell(r.v, k))
+			}
+		case r.v.typ == varray || r.v.typ == vmap:
+			for _, e := range rowEntries(r.v) {
+				add(e.v)
+			}
+		default:
+			add(r.v)
+		}
+		cells = append(cells, row)
+		num = append(num, isnum)
+	}
+	return header, cells, num
+}
+
+// rowEntries returns the entries of a row that is an array or a map.
+func rowEntries(r value) []struct{ k, v value } {
+	if m, ok := r.v.(*hashMap); ok {
+		return m.items()
+	}
+	return r.entries()
+}
+
+// rowCell returns the value of the column k in a row, or nil if the row
+// has no such column.
+func rowCell(r value, k value) value {
+	if m, ok := r.v.(*hashMap); ok {
+		if v, ok := m.lookup(k); ok {
+			return v
+		}
+		return value{typ: vnil}
+	}
+	return r.get(k)
+}
+
+// builtinTable formats an array of rows as a table. Its optional second
+// argument selects the format: "text" (the default) for aligned columns,
+// "csv", or "markdown".
+func (interp *interp) builtinTable(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != varray || len(args) == 2 && args[1].typ != vstring {
+		interp.err = fmt.Errorf("table expects an array of rows and an optional format")
+		return value{}
+	}
+	format := "text"
+	if len(args) == 2 {
+		format = args[1].v.(string)
+	}
+	header, cells, num := tableCells(args[0])
+	switch format {
+	case "text", "markdown":
+		return value{typ: vstring, v: formatTable(header, cells, num, format == "markdown")}
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if header != nil {
+			w.Write(header)
+		}
+		w.WriteAll(cells)
+		return value{typ: vstring, v: buf.String()}
+	}
+	interp.err = fmt.Errorf("table: unknown format %q", format)
+	return value{}
+}
+
+func formatTable(header []string, cells [][]string, num [][]bool, markdown bool) string {
+	if markdown && header == nil && len(cells) > 0 {
+		// Markdown tables must have a header, so the first row is used.
+		header, cells, num = cells[0], cells[1:], num[1:]
+	}
+	var widths []int
+	measure := func(row []string) {
+		for i, c := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(c); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	measure(header)
+	for _, row := range cells {
+		measure(row)
+	}
+	if markdown {
+		for i := range widths {
+			if widths[i] < 3 {
+				widths[i] = 3
+			}
+		}
+	}
+	var sb strings.Builder
+	line := func(row []string, isnum []bool) {
+		var lb strings.Builder
+		if markdown {
+			lb.WriteString("| ")
+		}
+		for i, w := range widths {
+			c := ""
+			if i < len(row) {
+				c = row[i]
+			}
+			pad := strings.Repeat(" ", w-utf8.RuneCountInString(c))
+			if i < len(isnum) && isnum[i] {
+				lb.WriteString(pad + c)
+			} else {
+				lb.WriteString(c + pad)
+			}
+			if i < len(widths)-1 {
+				if markdown {
+					lb.WriteString(" | ")
+				} else {
+					lb.WriteString("  ")
+				}
+			}
+		}
+		if markdown {
+			lb.WriteString(" |")
+		}
+		sb.WriteString(strings.TrimRight(lb.String(), " "))
+		sb.WriteString("\n")
+	}
+	if header != nil || markdown {
+		line(header, nil)
+		var rule []string
+		for _, w := range widths {
+			rule = append(rule, strings.Repeat("-", w))
+		}
+		line(rule, nil)
+	}
+	for i, row := range cells {
+		line(row, num[i])
+	}
+	return sb.String()
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// builtinSparkline returns a line of block characters whose heights are
+// proportional to the numbers in an array.
+func (interp *interp) builtinSparkline(args []value) value {
+	if len(args) != 1 || args[0].typ != varray {
+		interp.err = fmt.Errorf("sparkline expects an array of numbers")
+		return value{}
+	}
+	var nums []int
+	for _, e := range args[0].entries() {
+		if e.v.typ != vnum {
+			interp.err = fmt.Errorf("sparkline expects an array of numbers")
+			return value{}
+		}
+		nums = append(nums, e.v.v.(int))
+	}
+	if len(nums) == 0 {
+		return value{typ: vstring, v: ""}
+	}
+	lo, hi := nums[0], nums[0]
+	for _, n := range nums {
+		if n < lo {
+			lo = n
+		}
+		if n > hi {
+			hi = n
+		}
+	}
+	var sb strings.Builder
+	for _, n := range nums {
+		i := len(sparks) / 2
+		if hi > lo {
+			i = (n - lo) * (len(sparks) - 1) / (hi - lo)
+		}
+		sb.WriteRune(sparks[i])
+	}
+	return value{typ: vstring, v: sb.String()}
+}
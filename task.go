@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+)
+
+// A task is a script running in its own interpreter.
+type task struct {
+	done   chan struct{}
+	result value
+	err    error
+}
+
+// runTask evaluates the file name in a fresh interpreter that shares nothing
+// with the calling one except for its settings: the import search path,
+// whether it is sandboxed, the call depth limit, strict mode, the
+// overflow and modulo modes, and the HTTP timeout. A copy of input is
+// bound to the name input in the script, and a copy of the value the
+// script exports as result is returned.
+func runTask(parent *interp, name string, input value) (value, error) {
+	child := &interp{path: parent.path, main: name, sandbox: parent.sandbox, maxDepth: parent.maxDepth, strict: parent.strict, overflow: parent.overflow, modulo: parent.modulo, httpTimeout: parent.httpTimeout}
+	m, err := child.load(name)
+	if err != nil {
+		return value{}, err
+	}
+	m.env.m["input"] = copyValue(input)
+	child.init(m)
+	if e, ok := child.err.(*exitStatus); ok && e.code == 0 {
+		// A task that exits successfully ends early, but doesn't fail.
+		child.err = nil
+	}
+	if child.err != nil {
+		return value{}, fmt.Errorf("task %v: %v", name, child.err)
+	}
+	if !m.exports["result"] {
+		return value{}, nil
+	}
+	return copyValue(m.env.m["result"]), nil
+}
+
+// taskArgs resolves the script named by args[0] and returns it along with
+// the input in args[1], if any.
+func (interp *interp) taskArgs(fn string, args []value) (string, value, bool) {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("%v expects a script and an optional input", fn)
+		return "", value{}, false
+	}
+	name, err := interp.resolveImport(interp.main, args[0].v.(string))
+	if err != nil {
+		interp.err = err
+		return "", value{}, false
+	}
+	var input value
+	if len(args) == 2 {
+		input = args[1]
+	}
+	return name, input, true
+}
+
+func (interp *interp) taskRun(args []value) value {
+	name, input, ok := interp.taskArgs("task.run", args)
+	if !ok {
+		return value{}
+	}
+	v, err := runTask(interp, name, input)
+	if err != nil {
+		interp.err = err
+	}
+	return v
+}
+
+// taskStart is like taskRun, but runs the script in a separate goroutine.
+// It returns a handle to be passed to task.wait.
+func (interp *interp) taskStart(args []value) value {
+	name, input, ok := interp.taskArgs("task.start", args)
+	if !ok {
+		return value{}
+	}
+	t := &task{done: make(chan struct{})}
+	input = copyValue(input)
+	go func() {
+		defer close(t.done)
+		t.result, t.err = runTask(interp, name, input)
+	}()
+	return value{typ: vhandle, v: t}
+}
+
+func (interp *interp) taskWait(args []value) value {
+	if len(args) != 1 || args[0].typ != vhandle {
+		interp.err = fmt.Errorf("task.wait expects a task")
+		return value{}
+	}
+	t, ok := args[0].v.(*task)
+	if !ok {
+		interp.err = fmt.Errorf("task.wait expects a task")
+		return value{}
+	}
+	<-t.done
+	if t.err != nil {
+		interp.err = t.err
+	}
+	return t.result
+}
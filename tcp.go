@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// The tcp module makes and accepts TCP connections. Connections and
+// listeners are handles, and an error from the operating system or the
+// peer results in an error value. None of its functions are allowed in
+// the sandbox.
+func init() {
+	nativeModule("tcp", map[string]builtin{
+		"dial":     (*interp).tcpDial,
+		"listen":   (*interp).tcpListen,
+		"accept":   (*interp).tcpAccept,
+		"addr":     (*interp).tcpAddr,
+		"send":     (*interp).tcpSend,
+		"recv":     (*interp).tcpRecv,
+		"recvline": (*interp).tcpRecvLine,
+		"close":    (*interp).tcpClose,
+	})
+}
+
+// A tcpConn is a connection, read through a buffer so that recv and
+// recvline can be mixed.
+type tcpConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newTCPConn(c net.Conn) value {
+	return value{typ: vhandle, v: &tcpConn{conn: c, r: bufio.NewReader(c)}}
+}
+
+// tcpAddrArg returns the address that is the only argument in args to fn,
+// and whether fn may be called.
+func (interp *interp) tcpAddrArg(fn string, args []value) (string, bool) {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("%v expects an address like \"host:port\"", fn)
+		return "", false
+	}
+	if !interp.allowed(fn) {
+		return "", false
+	}
+	return args[0].v.(string), true
+}
+
+func (interp *interp) tcpConn(fn string, args []value, n int, usage string) *tcpConn {
+	if len(args) != n {
+		interp.err = fmt.Errorf("%v expects %v", fn, usage)
+		return nil
+	}
+	c, ok := args[0].v.(*tcpConn)
+	if !ok || args[0].typ != vhandle {
+		interp.err = fmt.Errorf("%v expects a connection returned by tcp.dial or tcp.accept", fn)
+		return nil
+	}
+	return c
+}
+
+func (interp *interp) tcpListener(fn string, args []value) net.Listener {
+	l, ok := args[0].v.(net.Listener)
+	if !ok || args[0].typ != vhandle {
+		interp.err = fmt.Errorf("%v expects a listener returned by tcp.listen", fn)
+		return nil
+	}
+	return l
+}
+
+// tcpDial connects to an address like "example.com:80".
+func (interp *interp) tcpDial(args []value) value {
+	addr, ok := interp.tcpAddrArg("tcp.dial", args)
+	if !ok {
+		return value{}
+	}
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return interp.failure(err)
+	}
+	return newTCPConn(c)
+}
+
+// tcpListen listens for connections on an address like "localhost:8000".
+// With a port of 0, one is chosen, which tcp.addr reports.
+func (interp *interp) tcpListen(args []value) value {
+	addr, ok := interp.tcpAddrArg("tcp.listen", args)
+	if !ok {
+		return value{}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return interp.failure(err)
+	}
+	return value{typ: vhandle, v: l}
+}
+
+// tcpAccept waits for a connection to a listener and returns it.
+func (interp *interp) tcpAccept(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("tcp.accept expects a listener")
+		return value{}
+	}
+	l := interp.tcpListener("tcp.accept", args)
+	if l == nil {
+		return value{}
+	}
+	c, err := l.Accept()
+	if err != nil {
+		return interp.failure(err)
+	}
+	return newTCPConn(c)
+}
+
+// tcpAddr returns the local address of a listener or a connection.
+func (interp *interp) tcpAddr(args []value) value {
+	if len(args) == 1 {
+		switch h := args[0].v.(type) {
+		case net.Listener:
+			return value{typ: vstring, v: h.Addr().String()}
+		case *tcpConn:
+			return value{typ: vstring, v: h.conn.LocalAddr().String()}
+		}
+	}
+	interp.err = fmt.Errorf("tcp.addr expects a listener or a connection")
+	return value{}
+}
+
+// tcpSend writes a string or bytes to a connection.
+func (interp *interp) tcpSend(args []value) value {
+	c := interp.tcpConn("tcp.send", args, 2, "a connection and a string or bytes")
+	if c == nil {
+		return value{}
+	}
+	b, ok := interp.binaryArg("tcp.send", args[1:])
+	if !ok {
+		return value{}
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		return interp.failure(err)
+	}
+	return value{typ: vnil}
+}
+
+// tcpRecv waits for data on a connection and returns at most an optional
+// number of bytes of it, 4096 by default, as a string. It returns nil once
+// the peer has closed the connection and everything it sent has been
+// read.
+func (interp *interp) tcpRecv(args []value) value {
+	n := 4096
+	if len(args) == 2 {
+		if args[1].typ != vnum || args[1].v.(int) <= 0 {
+			interp.err = fmt.Errorf("tcp.recv expects a connection and an optional positive size")
+			return value{}
+		}
+		n = args[1].v.(int)
+		args = args[:1]
+	}
+	c := interp.tcpConn("tcp.recv", args, 1, "a connection and an optional positive size")
+	if c == nil {
+		return value{}
+	}
+	b := make([]byte, n)
+	n, err := c.r.Read(b)
+	if errors.Is(err, io.EOF) {
+		return value{typ: vnil}
+	}
+	if err != nil {
+		return interp.failure(err)
+	}
+	return value{typ: vstring, v: string(b[:n])}
+}
+
+// tcpRecvLine returns the next line received on a connection, without its
+// line ending. It returns nil once the peer has closed the connection and
+// everything it sent has been read; a final line without a newline is
+// returned first.
+func (interp *interp) tcpRecvLine(args []value) value {
+	c := interp.tcpConn("tcp.recvline", args, 1, "a connection")
+	if c == nil {
+		return value{}
+	}
+	line, err := c.r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line != "" {
+		err = nil
+	}
+	if errors.Is(err, io.EOF) {
+		return value{typ: vnil}
+	}
+	if err != nil {
+		return interp.failure(err)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return value{typ: vstring, v: strings.TrimSuffix(line, "\r")}
+}
+
+// tcpClose closes a connection or a listener.
+func (interp *interp) tcpClose(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("tcp.close expects a listener or a connection")
+		return value{}
+	}
+	var err error
+	switch h := args[0].v.(type) {
+	case net.Listener:
+		err = h.Close()
+	case *tcpConn:
+		err = h.conn.Close()
+	default:
+		interp.err = fmt.Errorf("tcp.close expects a listener or a connection")
+		return value{}
+	}
+	if err != nil {
+		return interp.failure(err)
+	}
+	return value{typ: vnil}
+}
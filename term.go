@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// The term module styles text and moves the cursor with ANSI escape
+// sequences. When standard output isn't a terminal, or NO_COLOR is set,
+// styles are dropped and cursor movements are empty strings, so output
+// that is redirected to a file stays readable.
+
+func init() {
+	m := map[string]builtin{
+		"color":     (*interp).termColor,
+		"bg":        (*interp).termBg,
+		"up":        termMove("up", 'A'),
+		"down":      termMove("down", 'B'),
+		"right":     termMove("right", 'C'),
+		"left":      termMove("left", 'D'),
+		"move":      (*interp).termGoto,
+		"clear":     termSeq("clear", "\x1b[2J\x1b[H"),
+		"clearline": termSeq("clearline", "\x1b[2K\r"),
+		"width":     (*interp).termWidth,
+		"isatty":    (*interp).termIsatty,
+	}
+	for name, code := range map[string]int{"bold": 1, "dim": 2, "italic": 3, "underline": 4, "reverse": 7} {
+		m[name] = termStyle(name, code)
+	}
+	nativeModule("term", m)
+}
+
+var termColors = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// styled reports whether escape sequences should be written to standard
+// output.
+func styled() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return isatty(os.Stdout)
+}
+
+func isatty(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func sgr(code int, s string) string {
+	if !styled() {
+		return s
+	}
+	return "\x1b[" + strconv.Itoa(code) + "m" + s + "\x1b[0m"
+}
+
+func (interp *interp) termColorArgs(fn string, args []value) (int, string, bool) {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vstring {
+		interp.err = fmt.Errorf("term.%v expects a color name and a string", fn)
+		return 0, "", false
+	}
+	c, ok := termColors[args[0].v.(string)]
+	if !ok {
+		interp.err = fmt.Errorf("term.%v: unknown color %q", fn, args[0].v.(string))
+		return 0, "", false
+	}
+	return c, args[1].v.(string), true
+}
+
+func (interp *interp) termColor(args []value) value {
+	c, s, ok := interp.termColorArgs("color", args)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: sgr(30+c, s)}
+}
+
+func (interp *interp) termBg(args []value) value {
+	c, s, ok := interp.termColorArgs("bg", args)
+	if !ok {
+		return value{}
+	}
+	return value{typ: vstring, v: sgr(40+c, s)}
+}
+
+func termStyle(name string, code int) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) != 1 || args[0].typ != vstring {
+			interp.err = fmt.Errorf("term.%v expects a string", name)
+			return value{}
+		}
+		return value{typ: vstring, v: sgr(code, args[0].v.(string))}
+	}
+}
+
+func termSeq(name, seq string) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) != 0 {
+			interp.err = fmt.Errorf("term.%v expects no arguments", name)
+			return value{}
+		}
+		if !styled() {
+			return value{typ: vstring, v: ""}
+		}
+		return value{typ: vstring, v: seq}
+	}
+}
+
+// termMove returns a builtin producing the sequence that moves the cursor
+// a number of cells, 1 by default, in the direction given by final.
+func termMove(name string, final byte) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) > 1 || len(args) == 1 && args[0].typ != vnum {
+			interp.err = fmt.Errorf("term.%v expects an optional count", name)
+			return value{}
+		}
+		n := 1
+		if len(args) == 1 {
+			n = args[0].v.(int)
+		}
+		if !styled() || n <= 0 {
+			return value{typ: vstring, v: ""}
+		}
+		return value{typ: vstring, v: fmt.Sprintf("\x1b[%d%c", n, final)}
+	}
+}
+
+// termGoto returns the sequence that moves the cursor to a row and column,
+// counting from 1.
+func (interp *interp) termGoto(args []value) value {
+	if len(args) != 2 || args[0].typ != vnum || args[1].typ != vnum {
+		interp.err = fmt.Errorf("term.move expects a row and a column")
+		return value{}
+	}
+	if !styled() {
+		return value{typ: vstring, v: ""}
+	}
+	return value{typ: vstring, v: fmt.Sprintf("\x1b[%d;%dH", args[0].v.(int), args[1].v.(int))}
+}
+
+// termWidth returns the number of columns in the terminal, from the
+// terminal itself or the COLUMNS environment variable, or 80 if neither
+// is available.
+func (interp *interp) termWidth(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("term.width expects no arguments")
+		return value{}
+	}
+	if w, ok := ttyWidth(os.Stdout); ok {
+		return value{typ: vnum, v: w}
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return value{typ: vnum, v: w}
+	}
+	return value{typ: vnum, v: 80}
+}
+
+func (interp *interp) termIsatty(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("term.isatty expects no arguments")
+		return value{}
+	}
+	return value{typ: vbool, v: isatty(os.Stdout)}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import "os"
+
+// ttyWidth returns the number of columns in the terminal f, if it is one.
+func ttyWidth(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	nativeModule("text", map[string]builtin{
+		"words": (*interp).textWords,
+		"lines": (*interp).textLines,
+		"freq":  (*interp).textFreq,
+	})
+}
+
+// stringArray returns an array of ss. Since the keys are known to be
+// distinct, it appends entries directly rather than calling set for each.
+func stringArray(ss []string) value {
+	es := make([]struct{ k, v value }, len(ss))
+	for i, s := range ss {
+		es[i].k = value{typ: vnum, v: i}
+		es[i].v = value{typ: vstring, v: s}
+	}
+	return arrayOf(es)
+}
+
+// words splits s into runs of letters and digits, which may contain
+// apostrophes, such as in "don't".
+func words(s string) []string {
+	ws := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\'' && r != '’'
+	})
+	var out []string
+	for _, w := range ws {
+		if w = strings.Trim(w, "'’"); w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func (interp *interp) textWords(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("text.words expects a string")
+		return value{}
+	}
+	return stringArray(words(args[0].v.(string)))
+}
+
+// textLines splits a string into lines, without their line endings. A
+// final line ending doesn't begin another line.
+func (interp *interp) textLines(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("text.lines expects a string")
+		return value{}
+	}
+	s := args[0].v.(string)
+	if s == "" {
+		return newArray()
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return stringArray(lines)
+}
+
+// textFreq counts the occurrences of each string in an array, and returns
+// an array mapping each to its count, most frequent first. Strings with
+// the same count are in the order they first appear.
+func (interp *interp) textFreq(args []value) value {
+	if len(args) != 1 || args[0].typ != varray {
+		interp.err = fmt.Errorf("text.freq expects an array of strings")
+		return value{}
+	}
+	counts := make(map[string]int)
+	var order []string
+	for _, e := range args[0].entries() {
+		if e.v.typ != vstring {
+			interp.err = fmt.Errorf("text.freq expects an array of strings")
+			return value{}
+		}
+		w := e.v.v.(string)
+		if counts[w] == 0 {
+			order = append(order, w)
+		}
+		counts[w]++
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+	es := make([]struct{ k, v value }, len(order))
+	for i, w := range order {
+		es[i].k = value{typ: vstring, v: w}
+		es[i].v = value{typ: vnum, v: counts[w]}
+	}
+	return arrayOf(es)
+}
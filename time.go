@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func init() {
+	builtins["now"] = (*interp).builtinNow
+	builtins["monotonic"] = (*interp).builtinMonotonic
+	builtins["sleep"] = (*interp).builtinSleep
+	builtins["timeformat"] = (*interp).builtinTimeFormat
+	builtins["timeparse"] = (*interp).builtinTimeParse
+	builtins["year"] = timeField("year", func(t time.Time) int { return t.Year() })
+	builtins["month"] = timeField("month", func(t time.Time) int { return int(t.Month()) })
+	builtins["day"] = timeField("day", func(t time.Time) int { return t.Day() })
+	builtins["weekday"] = timeField("weekday", func(t time.Time) int { return int(t.Weekday()) })
+}
+
+// Times are numbers of milliseconds since the Unix epoch, in UTC.
+
+// layouts are names for common layouts of times, which can be given to
+// timeformat and timeparse instead of a layout as in Go's time package,
+// such as "2006-01-02 15:04".
+var layouts = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"rfc1123":  time.RFC1123,
+	"date":     time.DateOnly,
+	"time":     time.TimeOnly,
+	"datetime": time.DateTime,
+}
+
+func layoutOf(s string) string {
+	if l, ok := layouts[s]; ok {
+		return l
+	}
+	return s
+}
+
+func timeOf(ms int) time.Time {
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+// started is when the program started, as a reading of the monotonic
+// clock.
+var started = time.Now()
+
+// builtinNow returns the current time from the wall clock, which may jump
+// if the system's clock is changed.
+func (interp *interp) builtinNow(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("now expects no arguments")
+		return value{}
+	}
+	return value{typ: vnum, v: int(time.Now().UnixMilli())}
+}
+
+// builtinMonotonic returns the number of nanoseconds since the program
+// started, from a clock that only moves forward, for measuring how long
+// something takes.
+func (interp *interp) builtinMonotonic(args []value) value {
+	if len(args) != 0 {
+		interp.err = fmt.Errorf("monotonic expects no arguments")
+		return value{}
+	}
+	return value{typ: vnum, v: int(time.Since(started))}
+}
+
+// builtinSleep pauses the program for a number of milliseconds.
+func (interp *interp) builtinSleep(args []value) value {
+	if len(args) != 1 || args[0].typ != vnum || args[0].v.(int) < 0 {
+		interp.err = fmt.Errorf("sleep expects a non-negative number of milliseconds")
+		return value{}
+	}
+	time.Sleep(time.Duration(args[0].v.(int)) * time.Millisecond)
+	return value{typ: vnil}
+}
+
+// builtinTimeFormat returns a time formatted by a layout.
+func (interp *interp) builtinTimeFormat(args []value) value {
+	if len(args) != 2 || args[0].typ != vnum || args[1].typ != vstring {
+		interp.err = fmt.Errorf("timeformat expects a time and a layout")
+		return value{}
+	}
+	return value{typ: vstring, v: timeOf(args[0].v.(int)).Format(layoutOf(args[1].v.(string)))}
+}
+
+// builtinTimeParse returns the time a string formatted by a layout gives.
+// Times without a zone are in UTC. A string that doesn't match the layout
+// results in an error value.
+func (interp *interp) builtinTimeParse(args []value) value {
+	if len(args) != 2 || args[0].typ != vstring || args[1].typ != vstring {
+		interp.err = fmt.Errorf("timeparse expects a string and a layout")
+		return value{}
+	}
+	t, err := time.Parse(layoutOf(args[1].v.(string)), args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("timeparse: %v", err))
+	}
+	return value{typ: vnum, v: int(t.UnixMilli())}
+}
+
+// timeField returns a builtin named name that returns a component of a
+// time: its year, month from 1 to 12, day of the month, or day of the
+// week from 0 for Sunday to 6.
+func timeField(name string, f func(time.Time) int) builtin {
+	return func(interp *interp, args []value) value {
+		if len(args) != 1 || args[0].typ != vnum {
+			interp.err = fmt.Errorf("%v expects a time", name)
+			return value{}
+		}
+		return value{typ: vnum, v: f(timeOf(args[0].v.(int)))}
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	builtins["tomlparse"] = (*interp).builtinTOMLParse
+}
+
+// builtinTOMLParse returns the TOML document in a string as a map. Tables
+// are maps, arrays are arrays, and dates and times are strings. A string
+// that isn't valid TOML results in an error value.
+func (interp *interp) builtinTOMLParse(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("tomlparse expects a string")
+		return value{}
+	}
+	v, err := parseTOML(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("tomlparse: %v", err))
+	}
+	return v
+}
+
+type tomlParser struct {
+	s    string
+	i    int
+	line int
+	root *hashMap
+	// defined holds the tables defined by headers, which may each be
+	// defined only once.
+	defined map[*hashMap]bool
+}
+
+func parseTOML(s string) (value, error) {
+	p := &tomlParser{s: strings.ReplaceAll(s, "\r\n", "\n"), line: 1, root: newHashMap(), defined: make(map[*hashMap]bool)}
+	table := p.root
+	for {
+		p.skipSpace()
+		if p.i == len(p.s) {
+			break
+		}
+		var err error
+		switch p.s[p.i] {
+		case '#', '\n':
+		case '[':
+			table, err = p.header()
+		default:
+			err = p.keyValue(table)
+		}
+		if err == nil {
+			err = p.endLine()
+		}
+		if err != nil {
+			return value{}, err
+		}
+	}
+	return value{typ: vmap, v: p.root}, nil
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %v: %v", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipBlank skips white space, newlines, and comments.
+func (p *tomlParser) skipBlank() {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t':
+		case '\n':
+			p.line++
+		case '#':
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+			continue
+		default:
+			return
+		}
+		p.i++
+	}
+}
+
+// endLine skips the rest of a line, which may only hold a comment.
+func (p *tomlParser) endLine() error {
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == '#' {
+		for p.i < len(p.s) && p.s[p.i] != '\n' {
+			p.i++
+		}
+	}
+	if p.i == len(p.s) {
+		return nil
+	}
+	if p.s[p.i] != '\n' {
+		return p.errorf("expected the end of the line, found %q", p.s[p.i])
+	}
+	p.i++
+	p.line++
+	return nil
+}
+
+// key parses a key, which is a list of bare or quoted keys separated by
+// dots.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.i == len(p.s) {
+			return nil, p.errorf("missing key")
+		}
+		if c := p.s[p.i]; c == '"' || c == '\'' {
+			k, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		} else {
+			j := p.i
+			for j < len(p.s) && isBareKeyChar(p.s[j]) {
+				j++
+			}
+			if j == p.i {
+				return nil, p.errorf("invalid key starting with %q", c)
+			}
+			keys = append(keys, p.s[p.i:j])
+			p.i = j
+		}
+		p.skipSpace()
+		if p.i == len(p.s) || p.s[p.i] != '.' {
+			return keys, nil
+		}
+		p.i++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// descend returns the table with key k in t, creating it if there isn't
+// one. For an array of tables, it is the last.
+func (p *tomlParser) descend(t *hashMap, k string) (*hashMap, error) {
+	v, ok := t.lookup(value{typ: vstring, v: k})
+	if !ok {
+		m := newHashMap()
+		t.put(value{typ: vstring, v: k}, value{typ: vmap, v: m})
+		return m, nil
+	}
+	if v.typ == varray && len(v.entries()) > 0 {
+		v = v.entries()[len(v.entries())-1].v
+	}
+	if v.typ != vmap {
+		return nil, p.errorf("key %v is not a table", k)
+	}
+	return v.v.(*hashMap), nil
+}
+
+// header parses a table header, [a.b], or an array of tables header,
+// [[a.b]], and returns the table that follows it.
+func (p *tomlParser) header() (*hashMap, error) {
+	array := strings.HasPrefix(p.s[p.i:], "[[")
+	p.i++
+	if array {
+		p.i++
+	}
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	end := "]"
+	if array {
+		end = "]]"
+	}
+	if !strings.HasPrefix(p.s[p.i:], end) {
+		return nil, p.errorf("expected %v after table name", end)
+	}
+	p.i += len(end)
+	t := p.root
+	for _, k := range keys[:len(keys)-1] {
+		if t, err = p.descend(t, k); err != nil {
+			return nil, err
+		}
+	}
+	last := value{typ: vstring, v: keys[len(keys)-1]}
+	if array {
+		v, ok := t.lookup(last)
+		if !ok {
+			v = newArray()
+		} else if v.typ != varray {
+			return nil, p.errorf("key %v is not an array of tables", last.v)
+		}
+		m := newHashMap()
+		v.set(value{typ: vnum, v: len(v.entries())}, value{typ: vmap, v: m})
+		t.put(last, v)
+		p.defined[m] = true
+		return m, nil
+	}
+	if t, err = p.descend(t, last.v.(string)); err != nil {
+		return nil, err
+	}
+	if p.defined[t] {
+		return nil, p.errorf("table %v is defined more than once", strings.Join(keys, "."))
+	}
+	p.defined[t] = true
+	return t, nil
+}
+
+// keyValue parses key = value and sets the key in t.
+func (p *tomlParser) keyValue(t *hashMap) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if p.i == len(p.s) || p.s[p.i] != '=' {
+		return p.errorf("expected = after key")
+	}
+	p.i++
+	p.skipSpace()
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys[:len(keys)-1] {
+		if t, err = p.descend(t, k); err != nil {
+			return err
+		}
+	}
+	last := value{typ: vstring, v: keys[len(keys)-1]}
+	if _, ok := t.lookup(last); ok {
+		return p.errorf("key %v is defined more than once", strings.Join(keys, "."))
+	}
+	t.put(last, v)
+	return nil
+}
+
+func (p *tomlParser) value() (value, error) {
+	if p.i == len(p.s) {
+		return value{}, p.errorf("missing value")
+	}
+	switch p.s[p.i] {
+	case '"', '\'':
+		s, err := p.str()
+		return value{typ: vstring, v: s}, err
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+	// Any other value runs to the next delimiter, except that a date
+	// may be separated from its time by a space.
+	end := func(j int) int {
+		for j < len(p.s) && !strings.ContainsRune(",]}#\n \t", rune(p.s[j])) {
+			j++
+		}
+		return j
+	}
+	j := end(p.i)
+	if j-p.i == 10 && p.s[p.i+4] == '-' && j+1 < len(p.s) && p.s[j] == ' ' && '0' <= p.s[j+1] && p.s[j+1] <= '9' {
+		j = end(j + 1)
+	}
+	tok := p.s[p.i:j]
+	p.i = j
+	v, ok := tomlScalar(tok)
+	if !ok {
+		return value{}, p.errorf("invalid value %q", tok)
+	}
+	return v, nil
+}
+
+// tomlScalar returns the bool, number, float, or date written as tok.
+func tomlScalar(tok string) (value, bool) {
+	switch tok {
+	case "true", "false":
+		return value{typ: vbool, v: tok == "true"}, true
+	case "inf", "+inf", "-inf":
+		return value{typ: vfloat, v: math.Inf(strings.Count(tok, "-")*-2 + 1)}, true
+	case "nan", "+nan", "-nan":
+		return value{typ: vfloat, v: math.NaN()}, true
+	}
+	if len(tok) >= 5 && tok[4] == '-' || len(tok) >= 3 && tok[2] == ':' {
+		return value{typ: vstring, v: tok}, true
+	}
+	t := strings.ReplaceAll(tok, "_", "")
+	if len(t) > 2 && t[0] == '0' && strings.ContainsRune("xob", rune(t[1])) {
+		n, err := strconv.ParseInt(t, 0, 64)
+		return value{typ: vnum, v: int(n)}, err == nil
+	}
+	if digits := strings.TrimLeft(t, "+-"); len(digits) > 1 && digits[0] == '0' && digits[1] != '.' && digits[1] != 'e' && digits[1] != 'E' {
+		return value{}, false
+	}
+	if strings.ContainsAny(t, ".eE") {
+		f, err := strconv.ParseFloat(t, 64)
+		return value{typ: vfloat, v: f}, err == nil
+	}
+	n, err := parseNum(t)
+	return n, err == nil
+}
+
+// str parses a basic or literal string, either of which may span lines
+// if it is delimited by three quotes.
+func (p *tomlParser) str() (string, error) {
+	q := p.s[p.i]
+	delim := strings.Repeat(string(q), 3)
+	if strings.HasPrefix(p.s[p.i:], delim) {
+		p.i += 3
+		// A newline right after the opening quotes isn't part of it.
+		if p.i < len(p.s) && p.s[p.i] == '\n' {
+			p.i++
+			p.line++
+		}
+		end := strings.Index(p.s[p.i:], delim)
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		// Up to two quotes may end the string before the closing ones.
+		for n := 0; n < 2 && p.i+end+3 < len(p.s) && p.s[p.i+end+3] == q; n++ {
+			end++
+		}
+		raw := p.s[p.i : p.i+end]
+		p.line += strings.Count(raw, "\n")
+		p.i += end + 3
+		if q == '\'' {
+			return raw, nil
+		}
+		return p.unescape(raw, true)
+	}
+	p.i++
+	j := p.i
+	for j < len(p.s) && p.s[j] != q && p.s[j] != '\n' {
+		if q == '"' && p.s[j] == '\\' {
+			j++
+		}
+		j++
+	}
+	if j >= len(p.s) || p.s[j] != q {
+		return "", p.errorf("unterminated string")
+	}
+	raw := p.s[p.i:j]
+	p.i = j + 1
+	if q == '\'' {
+		return raw, nil
+	}
+	return p.unescape(raw, false)
+}
+
+// unescape replaces the escape sequences in the body of a basic string.
+// In a multi-line one, a backslash at the end of a line removes the
+// newline and the white space after it.
+func (p *tomlParser) unescape(s string, multiline bool) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", p.errorf("invalid escape at end of string")
+		}
+		switch c := s[i]; c {
+		case 'b':
+			sb.WriteByte('\b')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'e':
+			sb.WriteByte(0x1b)
+		case '"', '\\':
+			sb.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", p.errorf("invalid escape \\%c", c)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", p.errorf("invalid escape \\%c%v", c, s[i+1:i+1+n])
+			}
+			sb.WriteRune(rune(r))
+			i += n
+		default:
+			j := i
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				j++
+			}
+			if !multiline || j == len(s) || s[j] != '\n' {
+				return "", p.errorf("invalid escape \\%c", c)
+			}
+			for j < len(s) && strings.ContainsRune(" \t\n", rune(s[j])) {
+				j++
+			}
+			i = j - 1
+		}
+	}
+	return sb.String(), nil
+}
+
+// array parses an array, which may span lines.
+func (p *tomlParser) array() (value, error) {
+	p.i++
+	r := newArray()
+	for {
+		p.skipBlank()
+		if p.i == len(p.s) {
+			return value{}, p.errorf("unterminated array")
+		}
+		if p.s[p.i] == ']' {
+			p.i++
+			return r, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return value{}, err
+		}
+		r.set(value{typ: vnum, v: len(r.entries())}, v)
+		p.skipBlank()
+		switch {
+		case p.i == len(p.s):
+			return value{}, p.errorf("unterminated array")
+		case p.s[p.i] == ',':
+			p.i++
+		case p.s[p.i] != ']':
+			return value{}, p.errorf("expected , or ] in array, found %q", p.s[p.i])
+		}
+	}
+}
+
+// inlineTable parses a table written as {k = v, ...} on one line.
+func (p *tomlParser) inlineTable() (value, error) {
+	p.i++
+	t := newHashMap()
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == '}' {
+		p.i++
+		return value{typ: vmap, v: t}, nil
+	}
+	for {
+		if err := p.keyValue(t); err != nil {
+			return value{}, err
+		}
+		p.skipSpace()
+		switch {
+		case p.i == len(p.s):
+			return value{}, p.errorf("unterminated inline table")
+		case p.s[p.i] == ',':
+			p.i++
+		case p.s[p.i] == '}':
+			p.i++
+			return value{typ: vmap, v: t}, nil
+		default:
+			return value{}, p.errorf("expected , or } in inline table, found %q", p.s[p.i])
+		}
+	}
+}
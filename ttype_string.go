@@ -16,41 +16,66 @@ func _() {
 	_ = x[tmul-5]
 	_ = x[tquo-6]
 	_ = x[trem-7]
-	_ = x[tassign-8]
-	_ = x[tland-9]
-	_ = x[tlor-10]
-	_ = x[teql-11]
-	_ = x[tlss-12]
-	_ = x[tgtr-13]
-	_ = x[tnot-14]
-	_ = x[tneq-15]
-	_ = x[tleq-16]
-	_ = x[tgeq-17]
-	_ = x[tlparen-18]
-	_ = x[tlbrack-19]
-	_ = x[tlbrace-20]
-	_ = x[tcomma-21]
-	_ = x[tperiod-22]
-	_ = x[trparen-23]
-	_ = x[trbrack-24]
-	_ = x[trbrace-25]
-	_ = x[tsemicolon-26]
-	_ = x[tcolon-27]
-	_ = x[tif-28]
-	_ = x[telse-29]
-	_ = x[tfunc-30]
-	_ = x[treturn-31]
-	_ = x[twhile-32]
-	_ = x[tident-33]
+	_ = x[tpow-8]
+	_ = x[tassign-9]
+	_ = x[taddassign-10]
+	_ = x[tsubassign-11]
+	_ = x[tmulassign-12]
+	_ = x[tquoassign-13]
+	_ = x[tremassign-14]
+	_ = x[tcoalesce-15]
+	_ = x[tland-16]
+	_ = x[tlor-17]
+	_ = x[teql-18]
+	_ = x[tlss-19]
+	_ = x[tgtr-20]
+	_ = x[tnot-21]
+	_ = x[tneq-22]
+	_ = x[tleq-23]
+	_ = x[tgeq-24]
+	_ = x[tlparen-25]
+	_ = x[tlbrack-26]
+	_ = x[tlbrace-27]
+	_ = x[tcomma-28]
+	_ = x[tperiod-29]
+	_ = x[trparen-30]
+	_ = x[trbrack-31]
+	_ = x[trbrace-32]
+	_ = x[tsemicolon-33]
+	_ = x[tcolon-34]
+	_ = x[tarrow-35]
+	_ = x[thash-36]
+	_ = x[tif-37]
+	_ = x[telse-38]
+	_ = x[tfunc-39]
+	_ = x[treturn-40]
+	_ = x[twhile-41]
+	_ = x[timport-42]
+	_ = x[texport-43]
+	_ = x[tfor-44]
+	_ = x[tin-45]
+	_ = x[twhen-46]
+	_ = x[tstruct-47]
+	_ = x[tclass-48]
+	_ = x[tinterface-49]
+	_ = x[tis-50]
+	_ = x[tas-51]
+	_ = x[ttry-52]
+	_ = x[tcatch-53]
+	_ = x[tfinally-54]
+	_ = x[tthrow-55]
+	_ = x[tlet-56]
+	_ = x[tident-57]
 }
 
-const _ttype_name = "tillegaltnumtstringtplustsubtmultquotremtassigntlandtlorteqltlsstgtrtnottneqtleqtgeqtlparentlbracktlbracetcommatperiodtrparentrbracktrbracetsemicolontcolontiftelsetfunctreturntwhiletident"
+const _ttype_name = "tillegaltnumtstringtplustsubtmultquotremtpowtassigntaddassigntsubassigntmulassigntquoassigntremassigntcoalescetlandtlorteqltlsstgtrtnottneqtleqtgeqtlparentlbracktlbracetcommatperiodtrparentrbracktrbracetsemicolontcolontarrowthashtiftelsetfunctreturntwhiletimporttexporttfortintwhentstructtclasstinterfacetistasttrytcatchtfinallytthrowtlettident"
 
-var _ttype_index = [...]uint8{0, 8, 12, 19, 24, 28, 32, 36, 40, 47, 52, 56, 60, 64, 68, 72, 76, 80, 84, 91, 98, 105, 111, 118, 125, 132, 139, 149, 155, 158, 163, 168, 175, 181, 187}
+var _ttype_index = [...]uint16{0, 8, 12, 19, 24, 28, 32, 36, 40, 44, 51, 61, 71, 81, 91, 101, 110, 115, 119, 123, 127, 131, 135, 139, 143, 147, 154, 161, 168, 174, 181, 188, 195, 202, 212, 218, 224, 229, 232, 237, 242, 249, 255, 262, 269, 273, 276, 281, 288, 294, 304, 307, 310, 314, 320, 328, 334, 338, 344}
 
 func (i ttype) String() string {
-	if i < 0 || i >= ttype(len(_ttype_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_ttype_index)-1 {
 		return "ttype(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _ttype_name[_ttype_index[i]:_ttype_index[i+1]]
+	return _ttype_name[_ttype_index[idx]:_ttype_index[idx+1]]
 }
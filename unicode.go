@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+//go:generate go run mknorm.go
+
+func init() {
+	builtins["runes"] = (*interp).builtinRunes
+	builtins["runelen"] = (*interp).builtinRuneLen
+	builtins["ord"] = (*interp).builtinOrd
+	builtins["chr"] = (*interp).builtinChr
+	builtins["nfc"] = (*interp).builtinNFC
+	builtins["nfd"] = (*interp).builtinNFD
+}
+
+// Strings are sequences of Unicode characters, encoded as UTF-8. Indexing
+// a string, len, slice, and iterating over one all count characters (code
+// points), never bytes, and s[i] is the character at position i as a
+// string, so "héllo"[1] is "é" and len("héllo") is 5. Indexing takes time
+// proportional to i, since characters vary in length. To work with the
+// bytes of the encoding instead, convert the string with bytes, whose
+// elements are numbers from 0 to 255, so len(bytes("héllo")) is 6.
+//
+// A character is a code point, not what a reader sees as one letter: é
+// may be the single character U+00E9 or an e followed by the combining
+// accent U+0301, and the two strings differ in length and aren't ==.
+// nfc and nfd convert strings to one form or the other, Unicode's
+// normalization forms C and D, after which they compare as expected.
+
+// stringIndex returns the character of s at i.
+func (interp *interp) stringIndex(s string, i value) value {
+	if i.typ != vnum {
+		interp.err = fmt.Errorf("cannot index a string with %v", i.typ)
+		return value{}
+	}
+	n := i.v.(int)
+	if n >= 0 {
+		for j, r := range s {
+			if n == 0 {
+				return value{typ: vstring, v: s[j : j+utf8.RuneLen(r)]}
+			}
+			n--
+		}
+	}
+	interp.err = fmt.Errorf("index %v out of range for %v characters", i.v, utf8.RuneCountInString(s))
+	return value{}
+}
+
+// builtinRunes returns an array of the characters of a string.
+func (interp *interp) builtinRunes(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("runes expects a string")
+		return value{}
+	}
+	var ss []string
+	for _, r := range args[0].v.(string) {
+		ss = append(ss, string(r))
+	}
+	return stringArray(ss)
+}
+
+// builtinRuneLen returns the number of characters in a string, which is
+// its len, and the len of its runes.
+func (interp *interp) builtinRuneLen(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("runelen expects a string")
+		return value{}
+	}
+	return value{typ: vnum, v: utf8.RuneCountInString(args[0].v.(string))}
+}
+
+// builtinOrd returns the code point of the character in a string of one
+// character.
+func (interp *interp) builtinOrd(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring || utf8.RuneCountInString(args[0].v.(string)) != 1 {
+		interp.err = fmt.Errorf("ord expects a string of one character")
+		return value{}
+	}
+	r, _ := utf8.DecodeRuneInString(args[0].v.(string))
+	return value{typ: vnum, v: int(r)}
+}
+
+// builtinChr returns a string of the character with a code point.
+func (interp *interp) builtinChr(args []value) value {
+	if len(args) != 1 || args[0].typ != vnum {
+		interp.err = fmt.Errorf("chr expects a code point")
+		return value{}
+	}
+	n := args[0].v.(int)
+	if n < 0 || n > utf8.MaxRune || !utf8.ValidRune(rune(n)) {
+		interp.err = fmt.Errorf("chr: %v is not a valid code point", n)
+		return value{}
+	}
+	return value{typ: vstring, v: string(rune(n))}
+}
+
+// builtinNFC returns a string in normalization form C, in which each
+// character and the combining marks that follow it are composed into one
+// character where possible.
+func (interp *interp) builtinNFC(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("nfc expects a string")
+		return value{}
+	}
+	return value{typ: vstring, v: nfc(args[0].v.(string))}
+}
+
+// builtinNFD returns a string in normalization form D, in which each
+// character is decomposed into a base character and combining marks.
+func (interp *interp) builtinNFD(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("nfd expects a string")
+		return value{}
+	}
+	return value{typ: vstring, v: nfd(args[0].v.(string))}
+}
+
+// Hangul syllables are composed and decomposed arithmetically rather than
+// through the tables in normtables.go.
+const (
+	hangulSBase  = 0xac00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11a7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+// decompose appends the full canonical decomposition of r to rs.
+func decompose(rs []rune, r rune) []rune {
+	if s := r - hangulSBase; s >= 0 && s < hangulSCount {
+		rs = append(rs, hangulLBase+s/hangulNCount, hangulVBase+s%hangulNCount/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			rs = append(rs, hangulTBase+t)
+		}
+		return rs
+	}
+	d, ok := canonicalDecomp[r]
+	if !ok {
+		return append(rs, r)
+	}
+	for _, c := range d {
+		rs = decompose(rs, c)
+	}
+	return rs
+}
+
+// decomposeAll returns the characters of s decomposed and in canonical
+// order, in which each run of combining marks is sorted by combining
+// class.
+func decomposeAll(s string) []rune {
+	var rs []rune
+	for _, r := range s {
+		rs = decompose(rs, r)
+	}
+	for i := 0; i < len(rs); i++ {
+		j := i
+		for j < len(rs) && combiningClass[rs[j]] != 0 {
+			j++
+		}
+		if j-i > 1 {
+			slices.SortStableFunc(rs[i:j], func(a, b rune) int {
+				return int(combiningClass[a]) - int(combiningClass[b])
+			})
+		}
+		i = j
+	}
+	return rs
+}
+
+func nfd(s string) string { return string(decomposeAll(s)) }
+
+// compose returns the primary composite of a and b, if there is one.
+func compose(a, b rune) (rune, bool) {
+	if l, v := a-hangulLBase, b-hangulVBase; l >= 0 && l < hangulLCount && v >= 0 && v < hangulVCount {
+		return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+	}
+	if s, t := a-hangulSBase, b-hangulTBase; s >= 0 && s < hangulSCount && s%hangulTCount == 0 && t > 0 && t < hangulTCount {
+		return a + t, true
+	}
+	c, ok := canonicalComp[[2]rune{a, b}]
+	return c, ok
+}
+
+func nfc(s string) string {
+	rs := decomposeAll(s)
+	// Each character is composed with the last starter if nothing between
+	// them blocks it: a starter, or a mark of the same or a higher class.
+	out := rs[:0]
+	starter, last := -1, uint8(0)
+	for _, r := range rs {
+		class := combiningClass[r]
+		if starter >= 0 && (starter == len(out)-1 || last < class) {
+			if c, ok := compose(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		last = class
+		out = append(out, r)
+	}
+	return string(out)
+}
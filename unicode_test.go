@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct{ s, nfc, nfd string }{
+		{"hello", "hello", "hello"},
+		{"\u00e9", "\u00e9", "e\u0301"},
+		{"e\u0301", "\u00e9", "e\u0301"},
+		// Marks are put in canonical order, and composed when not blocked.
+		{"a\u0323\u0302", "\u1ead", "a\u0323\u0302"},
+		{"a\u0302\u0323", "\u1ead", "a\u0323\u0302"},
+		{"D\u0307\u0323", "\u1e0c\u0307", "D\u0323\u0307"},
+		// U+212B ANGSTROM SIGN decomposes to a character that composes to
+		// U+00C5, and U+0344 is excluded from composition.
+		{"\u212b", "\u00c5", "A\u030a"},
+		{"\u0344", "\u0308\u0301", "\u0308\u0301"},
+		{"\uac00\ud7a3", "\uac00\ud7a3", "\u1100\u1161\u1112\u1175\u11c2"},
+		{"\u1100\u1161\u11a8", "\uac01", "\u1100\u1161\u11a8"},
+	}
+	for _, tt := range tests {
+		if got := nfc(tt.s); got != tt.nfc {
+			t.Errorf("nfc(%+q) = %+q, want %+q", tt.s, got, tt.nfc)
+		}
+		if got := nfd(tt.s); got != tt.nfd {
+			t.Errorf("nfd(%+q) = %+q, want %+q", tt.s, got, tt.nfd)
+		}
+	}
+}
+
+func TestStringIndexing(t *testing.T) {
+	wantOutput(t, `
+		s = "héllo";
+		println(len(s), runelen(s), len(runes(s)), s[1], len(bytes(s)));
+		println(nfc("é") == "é", "é" == "é", len(nfd("é")));
+	`, "5 5 5 é 6\ntrue false 2\n")
+}
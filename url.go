@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	builtins["urlparse"] = (*interp).builtinURLParse
+	builtins["urlencode"] = (*interp).builtinURLEncode
+	builtins["urldecode"] = (*interp).builtinURLDecode
+	builtins["queryparse"] = (*interp).builtinQueryParse
+	builtins["querystring"] = (*interp).builtinQueryString
+}
+
+// builtinURLParse returns a map of the parts of a URL, with the keys
+// "scheme", "user", "host" without the port, "port", "path" with escapes
+// decoded, "query" as a map like queryparse returns, "rawquery", and
+// "fragment". Parts the URL doesn't have are empty strings. A string that
+// isn't a valid URL results in an error value.
+func (interp *interp) builtinURLParse(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("urlparse expects a string")
+		return value{}
+	}
+	u, err := url.Parse(args[0].v.(string))
+	if err != nil {
+		return interp.failure(err)
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return interp.failure(fmt.Errorf("urlparse: %v", err))
+	}
+	m := newHashMap()
+	for _, e := range []struct {
+		k string
+		v value
+	}{
+		{"scheme", value{typ: vstring, v: u.Scheme}},
+		{"user", value{typ: vstring, v: u.User.Username()}},
+		{"host", value{typ: vstring, v: u.Hostname()}},
+		{"port", value{typ: vstring, v: u.Port()}},
+		{"path", value{typ: vstring, v: u.Path}},
+		{"query", queryMap(u.RawQuery, q)},
+		{"rawquery", value{typ: vstring, v: u.RawQuery}},
+		{"fragment", value{typ: vstring, v: u.Fragment}},
+	} {
+		m.put(value{typ: vstring, v: e.k}, e.v)
+	}
+	return value{typ: vmap, v: m}
+}
+
+// queryMap returns the parameters q parsed from the query string s as a
+// map from each name to its value, or to an array of its values if it
+// appears more than once. The names are in the order they first appear.
+func queryMap(s string, q url.Values) value {
+	m := newHashMap()
+	for _, kv := range strings.FieldsFunc(s, func(r rune) bool { return r == '&' }) {
+		k, _, _ := strings.Cut(kv, "=")
+		k, err := url.QueryUnescape(k)
+		if err != nil {
+			continue
+		}
+		key := value{typ: vstring, v: k}
+		if _, ok := m.lookup(key); ok {
+			continue
+		}
+		if vs := q[k]; len(vs) == 1 {
+			m.put(key, value{typ: vstring, v: vs[0]})
+		} else {
+			m.put(key, stringArray(vs))
+		}
+	}
+	return value{typ: vmap, v: m}
+}
+
+// builtinQueryParse returns the parameters in a query string, without a
+// leading ?, as a map like the "query" of urlparse. A string with invalid
+// escapes results in an error value.
+func (interp *interp) builtinQueryParse(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("queryparse expects a string")
+		return value{}
+	}
+	s := args[0].v.(string)
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return interp.failure(fmt.Errorf("queryparse: %v", err))
+	}
+	return queryMap(s, q)
+}
+
+// builtinQueryString returns a query string, without a leading ?, of the
+// parameters in a map from names to values. A value that is an array adds
+// the parameter once for each element. Strings are used as they are, and
+// other values as print would show them.
+func (interp *interp) builtinQueryString(args []value) value {
+	if len(args) != 1 || args[0].typ != vmap {
+		interp.err = fmt.Errorf("querystring expects a map")
+		return value{}
+	}
+	var sb strings.Builder
+	param := func(k string, v value) {
+		if sb.Len() > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(k))
+		sb.WriteByte('=')
+		if v.typ == vstring {
+			sb.WriteString(url.QueryEscape(v.v.(string)))
+		} else {
+			sb.WriteString(url.QueryEscape(v.String()))
+		}
+	}
+	for _, e := range args[0].v.(*hashMap).items() {
+		if e.k.typ != vstring {
+			interp.err = fmt.Errorf("querystring expects a map with string keys")
+			return value{}
+		}
+		if e.v.typ != varray {
+			param(e.k.v.(string), e.v)
+			continue
+		}
+		for _, ae := range e.v.entries() {
+			param(e.k.v.(string), ae.v)
+		}
+	}
+	return value{typ: vstring, v: sb.String()}
+}
+
+// builtinURLEncode escapes a string so that it can be placed in a query
+// string, as a name or a value.
+func (interp *interp) builtinURLEncode(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("urlencode expects a string")
+		return value{}
+	}
+	return value{typ: vstring, v: url.QueryEscape(args[0].v.(string))}
+}
+
+// builtinURLDecode reverses urlencode, decoding + as a space. A string
+// with invalid escapes results in an error value.
+func (interp *interp) builtinURLDecode(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("urldecode expects a string")
+		return value{}
+	}
+	s, err := url.QueryUnescape(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("urldecode: %v", err))
+	}
+	return value{typ: vstring, v: s}
+}
@@ -9,20 +9,39 @@ func _() {
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
 	_ = x[verr-0]
-	_ = x[vnum-1]
-	_ = x[vstring-2]
-	_ = x[vbool-3]
-	_ = x[varray-4]
-	_ = x[vfunc-5]
+	_ = x[vnil-1]
+	_ = x[vnum-2]
+	_ = x[vstring-3]
+	_ = x[vbool-4]
+	_ = x[varray-5]
+	_ = x[vfunc-6]
+	_ = x[vmodule-7]
+	_ = x[vhandle-8]
+	_ = x[vtuple-9]
+	_ = x[vbitset-10]
+	_ = x[vsortedmap-11]
+	_ = x[vstruct-12]
+	_ = x[vrecord-13]
+	_ = x[vclass-14]
+	_ = x[vobject-15]
+	_ = x[vinterface-16]
+	_ = x[verror-17]
+	_ = x[vmap-18]
+	_ = x[vset-19]
+	_ = x[vbytes-20]
+	_ = x[vbig-21]
+	_ = x[vdecimal-22]
+	_ = x[vfloat-23]
 }
 
-const _vtype_name = "verrvnumvstringvboolvarrayvfunc"
+const _vtype_name = "verrvnilvnumvstringvboolvarrayvfuncvmodulevhandlevtuplevbitsetvsortedmapvstructvrecordvclassvobjectvinterfaceverrorvmapvsetvbytesvbigvdecimalvfloat"
 
-var _vtype_index = [...]uint8{0, 4, 8, 15, 20, 26, 31}
+var _vtype_index = [...]uint8{0, 4, 8, 12, 19, 24, 30, 35, 42, 49, 55, 62, 72, 79, 86, 92, 99, 109, 115, 119, 123, 129, 133, 141, 147}
 
 func (i vtype) String() string {
-	if i < 0 || i >= vtype(len(_vtype_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_vtype_index)-1 {
 		return "vtype(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _vtype_name[_vtype_index[i]:_vtype_index[i+1]]
+	return _vtype_name[_vtype_index[idx]:_vtype_index[idx+1]]
 }
@@ -0,0 +1,53 @@
+package main
+
+import "fmt"
+
+func init() {
+	builtins["watch"] = (*interp).builtinWatch
+}
+
+// watchers are the functions notified when entries of an array or sorted
+// map change.
+type watchers struct {
+	fns []value
+}
+
+func watchersOf(m value) *watchers {
+	switch m.typ {
+	case varray:
+		return m.v.(*array).watchers
+	case vsortedmap:
+		return m.v.(*sortedMap).watchers
+	}
+	return nil
+}
+
+// notify calls each watcher with the key whose value changed, its old
+// value, and its new value. A key that was added has the old value nil.
+func (w *watchers) notify(interp *interp, k, old, new value) {
+	for _, fn := range w.fns {
+		interp.call(fn, []value{k, old, new})
+	}
+}
+
+// builtinWatch registers a function to be called as fn(key, old, new)
+// when an entry of an array or sorted map is assigned a different value.
+func (interp *interp) builtinWatch(args []value) value {
+	if len(args) != 2 || args[0].typ != varray && args[0].typ != vsortedmap || args[1].typ != vfunc {
+		interp.err = fmt.Errorf("watch expects an array or sorted map and a function")
+		return value{}
+	}
+	if isFrozen(args[0]) {
+		interp.err = fmt.Errorf("cannot watch a frozen %v, which never changes", args[0].typ)
+		return value{}
+	}
+	switch w := watchersOf(args[0]); {
+	case w != nil:
+		w.fns = append(w.fns, args[1])
+	case args[0].typ == varray:
+		args[0].v.(*array).watchers = &watchers{fns: []value{args[1]}}
+	default:
+		args[0].v.(*sortedMap).watchers = &watchers{fns: []value{args[1]}}
+	}
+	return value{typ: vnil}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+func init() {
+	nativeModule("ws", map[string]builtin{
+		"connect": (*interp).wsConnect,
+		"send":    (*interp).wsSend,
+		"recv":    (*interp).wsRecv,
+		"close":   (*interp).wsClose,
+	})
+}
+
+// WebSocket opcodes from RFC 6455.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessage is the largest message that may be received, in bytes. A
+// server that sends a larger frame or message has the connection closed
+// with status 1009 (message too big), and recv fails.
+const wsMaxMessage = 16 << 20
+
+var errWSTooBig = fmt.Errorf("message larger than %v bytes", wsMaxMessage)
+
+// A wsConn is a client WebSocket connection. Messages are read by a
+// separate goroutine so that recv can time out, and so that pings are
+// answered while the script is busy.
+type wsConn struct {
+	conn net.Conn
+	msgs chan string
+	err  error // why msgs was closed
+
+	mu     sync.Mutex // guards writes to conn and closed
+	closed bool
+}
+
+func wsDial(rawurl string, header http.Header) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method:     "GET",
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	h := sha1.Sum([]byte(key + wsGUID))
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(h[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("handshake failed: %v", resp.Status)
+	}
+	c := &wsConn{conn: conn, msgs: make(chan string, 16)}
+	go c.readLoop(br)
+	return c, nil
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errors.New("use of closed connection")
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+// writeFrameLocked writes a single, final, masked frame.
+func (c *wsConn) writeFrameLocked(op byte, payload []byte) error {
+	hdr := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	hdr[1] |= 0x80
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	hdr = append(hdr, mask[:]...)
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	_, err := c.conn.Write(append(hdr, masked...))
+	return err
+}
+
+func (c *wsConn) readFrame(r *bufio.Reader) (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	fin, op = hdr[0]&0x80 != 0, hdr[0]&0xf
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > wsMaxMessage {
+		err = errWSTooBig
+		return
+	}
+	var mask [4]byte
+	masked := hdr[1]&0x80 != 0
+	if masked {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+func (c *wsConn) readLoop(r *bufio.Reader) {
+	defer close(c.msgs)
+	var msg []byte
+	for {
+		fin, op, payload, err := c.readFrame(r)
+		if err == nil && op <= wsBinary && len(msg)+len(payload) > wsMaxMessage {
+			err = errWSTooBig
+		}
+		if err == errWSTooBig {
+			c.closeStatus(1009)
+		}
+		if err != nil {
+			c.err = err
+			return
+		}
+		switch op {
+		case wsPing:
+			c.writeFrame(wsPong, payload)
+		case wsPong:
+		case wsClose:
+			c.mu.Lock()
+			if !c.closed {
+				c.writeFrameLocked(wsClose, payload)
+				c.closed = true
+			}
+			c.mu.Unlock()
+			c.conn.Close()
+			c.err = io.EOF
+			return
+		case wsText, wsBinary, wsContinuation:
+			msg = append(msg, payload...)
+			if fin {
+				c.msgs <- string(msg)
+				msg = nil
+			}
+		}
+	}
+}
+
+func (c *wsConn) close() error {
+	return c.closeStatus(1000) // normal closure
+}
+
+// closeStatus sends a close frame with a status code from RFC 6455 and
+// closes the connection, unless it is already closed.
+func (c *wsConn) closeStatus(code uint16) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.writeFrameLocked(wsClose, binary.BigEndian.AppendUint16(nil, code))
+	return c.conn.Close()
+}
+
+func (interp *interp) wsConn(fn string, v value) *wsConn {
+	c, ok := v.v.(*wsConn)
+	if !ok || v.typ != vhandle {
+		interp.err = fmt.Errorf("%v expects a connection returned by ws.connect", fn)
+		return nil
+	}
+	return c
+}
+
+// wsConnect opens a WebSocket connection to a ws:// or wss:// URL. Its
+// optional second argument is an array of extra handshake headers. If the
+// connection can't be made, the result is an error value. So it is for
+// ws.send, ws.recv, and ws.close if the connection fails.
+func (interp *interp) wsConnect(args []value) value {
+	if len(args) < 1 || len(args) > 2 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("ws.connect expects a URL and optional headers")
+		return value{}
+	}
+	if !interp.allowed("ws.connect") {
+		return value{}
+	}
+	header := make(http.Header)
+	if len(args) == 2 {
+		for _, e := range args[1].entries() {
+			header.Add(e.k.String(), e.v.String())
+		}
+	}
+	c, err := wsDial(args[0].v.(string), header)
+	if err != nil {
+		return interp.failure(fmt.Errorf("ws.connect: %v", err))
+	}
+	return value{typ: vhandle, v: c}
+}
+
+func (interp *interp) wsSend(args []value) value {
+	if len(args) != 2 || args[1].typ != vstring {
+		interp.err = fmt.Errorf("ws.send expects a connection and a string")
+		return value{}
+	}
+	c := interp.wsConn("ws.send", args[0])
+	if c == nil {
+		return value{}
+	}
+	if err := c.writeFrame(wsText, []byte(args[1].v.(string))); err != nil {
+		return interp.failure(fmt.Errorf("ws.send: %v", err))
+	}
+	return value{}
+}
+
+// wsRecv returns the next message received on a connection, waiting at most
+// the optional number of milliseconds. It returns false if the wait timed
+// out or the connection was closed by the server.
+func (interp *interp) wsRecv(args []value) value {
+	if len(args) < 1 || len(args) > 2 || len(args) == 2 && args[1].typ != vnum {
+		interp.err = fmt.Errorf("ws.recv expects a connection and an optional timeout")
+		return value{}
+	}
+	c := interp.wsConn("ws.recv", args[0])
+	if c == nil {
+		return value{}
+	}
+	var timeout <-chan time.Time
+	if len(args) == 2 {
+		timeout = time.After(time.Duration(args[1].v.(int)) * time.Millisecond)
+	}
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if c.err == errWSTooBig || !closed && c.err != io.EOF {
+				return interp.failure(fmt.Errorf("ws.recv: %v", c.err))
+			}
+			return value{typ: vbool, v: false}
+		}
+		return value{typ: vstring, v: msg}
+	case <-timeout:
+		return value{typ: vbool, v: false}
+	}
+}
+
+func (interp *interp) wsClose(args []value) value {
+	if len(args) != 1 {
+		interp.err = fmt.Errorf("ws.close expects a connection")
+		return value{}
+	}
+	c := interp.wsConn("ws.close", args[0])
+	if c == nil {
+		return value{}
+	}
+	if err := c.close(); err != nil {
+		return interp.failure(fmt.Errorf("ws.close: %v", err))
+	}
+	return value{}
+}
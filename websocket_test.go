@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// wsServer accepts one WebSocket connection on a new listener, and calls
+// serve with it once the handshake is done.
+func wsServer(t *testing.T, serve func(conn net.Conn, r *bufio.Reader)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return
+		}
+		h := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+base64.StdEncoding.EncodeToString(h[:])+"\r\n\r\n")
+		serve(conn, r)
+	}()
+	return "ws://" + l.Addr().String() + "/"
+}
+
+func TestWSMessageTooBig(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		frames func(conn net.Conn)
+	}{
+		{"frame", func(conn net.Conn) {
+			hdr := binary.BigEndian.AppendUint64([]byte{0x80 | wsText, 127}, wsMaxMessage+1)
+			conn.Write(hdr)
+		}},
+		{"message", func(conn net.Conn) {
+			payload := make([]byte, wsMaxMessage/2+1)
+			hdr := binary.BigEndian.AppendUint64([]byte{wsText, 127}, uint64(len(payload)))
+			conn.Write(append(hdr, payload...))
+			hdr[0] = wsContinuation
+			conn.Write(append(hdr, payload...))
+		}},
+	} {
+		status := make(chan uint16, 1)
+		url := wsServer(t, func(conn net.Conn, r *bufio.Reader) {
+			tt.frames(conn)
+			// The client's close frame is masked, with a two-byte status.
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil || b[0] != 0x80|wsClose {
+				status <- 0
+				return
+			}
+			status <- binary.BigEndian.Uint16([]byte{b[6] ^ b[2], b[7] ^ b[3]})
+		})
+		interp := &interp{}
+		c := lookupBuiltin(t, "ws.connect")(interp, []value{str(url)})
+		if interp.err != nil {
+			t.Fatal(interp.err)
+		}
+		v := lookupBuiltin(t, "ws.recv")(interp, []value{c})
+		if v.typ != verror || !strings.Contains(v.v.(*errorValue).msg, "message larger than") {
+			t.Errorf("%v too large: recv returned %v", tt.name, v)
+		}
+		if s := <-status; s != 1009 {
+			t.Errorf("%v too large: closed with status %v, want 1009", tt.name, s)
+		}
+	}
+}
+
+func TestWSConnectFailure(t *testing.T) {
+	// A connection that can't be made is an error value, not a failure
+	// of the program.
+	wantOutput(t, `
+		c = ws.connect("ws://127.0.0.1:1/");
+		println(iserror(c));
+	`, "true\n")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// whenFacts are the names that may appear in the condition of a when
+// statement.
+var whenFacts = map[string]value{
+	"os":   {typ: vstring, v: runtime.GOOS},
+	"arch": {typ: vstring, v: runtime.GOARCH},
+}
+
+// resolveWhen replaces each when statement in the tree rooted at n with
+// the statements of the block chosen by its condition. Since this happens
+// when a file is loaded, statements for other platforms are never
+// evaluated, and the imports in them are never resolved. The chosen
+// statements are in the enclosing scope, not a block of their own.
+func resolveWhen(n *node) (*node, error) {
+	if n == nil {
+		return nil, nil
+	}
+	var list []*node
+	for _, c := range n.list {
+		if c == nil || c.kind != kwhenstmt {
+			c, err := resolveWhen(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, c)
+			continue
+		}
+		for c != nil && c.kind == kwhenstmt {
+			v, err := whenCond(c.list[0])
+			if err != nil {
+				return nil, err
+			}
+			if v.typ != vbool {
+				return nil, fmt.Errorf("%v: when condition is %v, not bool", c.pos, v.typ)
+			}
+			switch {
+			case v.v.(bool):
+				c = c.list[1]
+			case len(c.list) == 3:
+				c = c.list[2]
+			default:
+				c = nil
+			}
+		}
+		if c == nil {
+			continue
+		}
+		c, err := resolveWhen(c)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c.list...)
+	}
+	n.list = list
+	return n, nil
+}
+
+// whenCond evaluates the condition of a when statement, which may only
+// compare literals and the names in whenFacts.
+func whenCond(n *node) (value, error) {
+	switch n.kind {
+	case kstringlit:
+		s, err := strconv.Unquote(n.value.text)
+		return value{typ: vstring, v: s}, err
+	case kident:
+		switch n.value.text {
+		case "true":
+			return value{typ: vbool, v: true}, nil
+		case "false":
+			return value{typ: vbool, v: false}, nil
+		}
+		if v, ok := whenFacts[n.value.text]; ok {
+			return v, nil
+		}
+		return value{}, fmt.Errorf("%v: %v cannot be used in a when condition", n.pos, n.value.text)
+	case kparenexpr:
+		return whenCond(n.list[0])
+	case kunaryexpr:
+		if n.value.ttype == tnot {
+			x, err := whenCond(n.list[0])
+			if err != nil {
+				return value{}, err
+			}
+			if x.typ == vbool {
+				return value{typ: vbool, v: !x.v.(bool)}, nil
+			}
+		}
+	case kbinaryexpr:
+		x, err := whenCond(n.list[0])
+		if err != nil {
+			return value{}, err
+		}
+		y, err := whenCond(n.list[1])
+		if err != nil {
+			return value{}, err
+		}
+		switch op := n.value.ttype; {
+		case x.typ != y.typ:
+		case op == teql || op == tneq:
+			return value{typ: vbool, v: x.eq(y) == (op == teql)}, nil
+		case x.typ == vbool && op == tland:
+			return value{typ: vbool, v: x.v.(bool) && y.v.(bool)}, nil
+		case x.typ == vbool && op == tlor:
+			return value{typ: vbool, v: x.v.(bool) || y.v.(bool)}, nil
+		}
+	}
+	return value{}, fmt.Errorf("%v: invalid when condition", n.pos)
+}
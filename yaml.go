@@ -0,0 +1,458 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	builtins["yamlparse"] = (*interp).builtinYAMLParse
+}
+
+// builtinYAMLParse returns the YAML document in a string as a value.
+// Mappings are maps, sequences are arrays, and scalars are nil, bools,
+// numbers, floats, or strings. Only block and single-line flow styles are
+// supported, without anchors, aliases, tags, or multiple documents. A
+// string that isn't supported YAML results in an error value.
+func (interp *interp) builtinYAMLParse(args []value) value {
+	if len(args) != 1 || args[0].typ != vstring {
+		interp.err = fmt.Errorf("yamlparse expects a string")
+		return value{}
+	}
+	v, err := parseYAML(args[0].v.(string))
+	if err != nil {
+		return interp.failure(fmt.Errorf("yamlparse: %v", err))
+	}
+	return v
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	// text is the line without its indentation or a trailing comment.
+	text string
+	// raw is the whole line, for block scalars.
+	raw string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func parseYAML(s string) (value, error) {
+	p := &yamlParser{}
+	for n, raw := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+		if strings.HasPrefix(text, "\t") && strings.TrimSpace(text) != "" {
+			return value{}, fmt.Errorf("line %v: tabs can't be used for indentation", n+1)
+		}
+		text = strings.TrimRight(stripYAMLComment(text), " \t")
+		p.lines = append(p.lines, yamlLine{num: n + 1, indent: indent, text: text, raw: raw})
+	}
+	for p.skipBlank(); p.i < len(p.lines) && strings.HasPrefix(p.lines[p.i].text, "%"); p.skipBlank() {
+		p.i++
+	}
+	if p.i < len(p.lines) && p.lines[p.i].text == "---" {
+		p.i++
+	}
+	v, err := p.node(0)
+	if err != nil {
+		return value{}, err
+	}
+	p.skipBlank()
+	if p.i < len(p.lines) {
+		switch l := p.lines[p.i]; l.text {
+		case "...":
+		case "---":
+			return value{}, fmt.Errorf("line %v: multiple documents aren't supported", l.num)
+		default:
+			return value{}, fmt.Errorf("line %v: unexpected %q", l.num, l.text)
+		}
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes a comment from the end of s, which starts with
+// a # that begins a word outside of quotes.
+func stripYAMLComment(s string) string {
+	var q byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case q == '"' && c == '\\':
+			i++
+		case q == '\'' && c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,", s[i-1]) >= 0):
+			q = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.i < len(p.lines) && p.lines[p.i].text == "" {
+		p.i++
+	}
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKeyEnd returns the index of the colon that ends the key at the start
+// of s, or -1 if s doesn't start with a key.
+func yamlKeyEnd(s string) int {
+	if s == "" || s[0] == '[' || s[0] == '{' {
+		return -1
+	}
+	i := 0
+	if q := s[0]; q == '"' || q == '\'' {
+		for i = 1; i < len(s) && s[i] != q; i++ {
+			if q == '"' && s[i] == '\\' {
+				i++
+			}
+		}
+		i++
+	}
+	for ; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// node parses the block node at the current line, if it is indented by at
+// least min, or returns nil if it isn't.
+func (p *yamlParser) node(min int) (value, error) {
+	p.skipBlank()
+	if p.i == len(p.lines) || p.lines[p.i].indent < min {
+		return value{typ: vnil}, nil
+	}
+	l := p.lines[p.i]
+	switch {
+	case isYAMLItem(l.text):
+		return p.sequence(l.indent)
+	case yamlKeyEnd(l.text) >= 0:
+		return p.mapping(l.indent)
+	}
+	p.i++
+	return p.value(l.text, l.indent-1, l.num)
+}
+
+// inline parses the node that starts after a key or dash on the current
+// line, with the rest of the line being rest, and starting at column col.
+// The node belongs to the mapping or sequence with the indentation parent.
+func (p *yamlParser) inline(rest string, col, parent int) (value, error) {
+	l := &p.lines[p.i]
+	if isYAMLItem(rest) || yamlKeyEnd(rest) >= 0 {
+		// A compact nested collection is treated as if it started on
+		// its own line.
+		l.indent, l.text = col, rest
+		return p.node(col)
+	}
+	p.i++
+	return p.value(rest, parent, l.num)
+}
+
+func (p *yamlParser) sequence(indent int) (value, error) {
+	r := newArray()
+	for {
+		p.skipBlank()
+		if p.i == len(p.lines) || p.lines[p.i].indent != indent || !isYAMLItem(p.lines[p.i].text) {
+			return r, nil
+		}
+		l := p.lines[p.i]
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var v value
+		var err error
+		if rest == "" {
+			p.i++
+			v, err = p.node(indent + 1)
+		} else {
+			v, err = p.inline(rest, indent+len(l.text)-len(rest), indent)
+		}
+		if err != nil {
+			return value{}, err
+		}
+		r.set(value{typ: vnum, v: len(r.entries())}, v)
+	}
+}
+
+func (p *yamlParser) mapping(indent int) (value, error) {
+	m := newHashMap()
+	for {
+		p.skipBlank()
+		if p.i == len(p.lines) || p.lines[p.i].indent != indent || isYAMLItem(p.lines[p.i].text) {
+			return value{typ: vmap, v: m}, nil
+		}
+		l := p.lines[p.i]
+		end := yamlKeyEnd(l.text)
+		if end < 0 {
+			return value{}, fmt.Errorf("line %v: expected a key", l.num)
+		}
+		k, err := yamlScalar(strings.TrimSpace(l.text[:end]))
+		if err != nil {
+			return value{}, fmt.Errorf("line %v: %v", l.num, err)
+		}
+		if _, ok := hashKey(k); !ok {
+			return value{}, fmt.Errorf("line %v: invalid key %q", l.num, l.text[:end])
+		}
+		if _, ok := m.lookup(k); ok {
+			return value{}, fmt.Errorf("line %v: key %v is defined more than once", l.num, l.text[:end])
+		}
+		rest := strings.TrimLeft(l.text[end+1:], " ")
+		var v value
+		if rest == "" {
+			p.i++
+			p.skipBlank()
+			// A sequence may be indented as much as its key.
+			if p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+				v, err = p.sequence(indent)
+			} else {
+				v, err = p.node(indent + 1)
+			}
+		} else {
+			v, err = p.inline(rest, indent+len(l.text)-len(rest), indent)
+		}
+		if err != nil {
+			return value{}, err
+		}
+		m.put(k, v)
+	}
+}
+
+// value parses the scalar or flow collection s, or the block scalar s
+// introduces, which is on line num.
+func (p *yamlParser) value(s string, parent, num int) (value, error) {
+	var v value
+	var err error
+	switch s[0] {
+	case '|', '>':
+		v, err = p.blockScalar(s, parent)
+	case '[', '{':
+		f := &yamlFlow{s: s}
+		if v, err = f.value(); err == nil {
+			if f.skipSpace(); f.i < len(f.s) {
+				err = fmt.Errorf("unexpected %q after flow collection", f.s[f.i:])
+			}
+		}
+	case '&', '*', '!':
+		err = fmt.Errorf("anchors, aliases, and tags aren't supported")
+	default:
+		v, err = yamlScalar(s)
+	}
+	if err != nil {
+		return value{}, fmt.Errorf("line %v: %v", num, err)
+	}
+	return v, nil
+}
+
+// blockScalar parses a literal (|) or folded (>) block scalar, the lines
+// of which follow the current one and are indented more than parent.
+func (p *yamlParser) blockScalar(header string, parent int) (value, error) {
+	chomp := byte(0)
+	indent := -1
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case '1' <= c && c <= '9':
+			indent = parent + 1 + int(c-'1')
+		default:
+			return value{}, fmt.Errorf("invalid block scalar header %q", header)
+		}
+	}
+	var lines []string
+	for ; p.i < len(p.lines); p.i++ {
+		l := p.lines[p.i]
+		if strings.TrimSpace(l.raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if l.indent <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = l.indent
+		}
+		if l.indent < indent {
+			break
+		}
+		lines = append(lines, l.raw[indent:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var sb strings.Builder
+	for i, l := range lines {
+		switch {
+		case i == 0:
+		case header[0] == '|' || l == "":
+			sb.WriteByte('\n')
+		case lines[i-1] != "":
+			// A folded line break between two lines becomes a space.
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(l)
+	}
+	s := sb.String()
+	switch {
+	case chomp == '+':
+		s += strings.Repeat("\n", trailing+1)
+	case chomp == 0 && s != "":
+		s += "\n"
+	}
+	return value{typ: vstring, v: s}, nil
+}
+
+var yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// yamlScalar returns the value of a quoted or plain scalar.
+func yamlScalar(s string) (value, error) {
+	if s == "" {
+		return value{typ: vnil}, nil
+	}
+	switch q := s[0]; q {
+	case '"':
+		if u, err := strconv.Unquote(s); err == nil {
+			return value{typ: vstring, v: u}, nil
+		}
+		return value{}, fmt.Errorf("invalid string %v", s)
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return value{}, fmt.Errorf("invalid string %v", s)
+		}
+		return value{typ: vstring, v: strings.ReplaceAll(s[1:len(s)-1], "''", "'")}, nil
+	}
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return value{typ: vnil}, nil
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return value{typ: vbool, v: s[0] == 't' || s[0] == 'T'}, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return value{typ: vfloat, v: math.Inf(1)}, nil
+	case "-.inf", "-.Inf", "-.INF":
+		return value{typ: vfloat, v: math.Inf(-1)}, nil
+	case ".nan", ".NaN", ".NAN":
+		return value{typ: vfloat, v: math.NaN()}, nil
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return value{typ: vnum, v: int(n)}, nil
+		}
+	}
+	if v, err := parseNum(s); err == nil {
+		return v, nil
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return value{typ: vfloat, v: f}, nil
+		}
+	}
+	return value{typ: vstring, v: s}, nil
+}
+
+// yamlFlow parses a flow collection, [a, b] or {k: v}, on a single line.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (value, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return value{}, fmt.Errorf("unterminated flow collection")
+	}
+	switch c := f.s[f.i]; c {
+	case '[', '{':
+		f.i++
+		var r value
+		if c == '[' {
+			r = newArray()
+		} else {
+			r = value{typ: vmap, v: newHashMap()}
+		}
+		for {
+			f.skipSpace()
+			if f.i == len(f.s) {
+				return value{}, fmt.Errorf("unterminated flow collection")
+			}
+			if f.s[f.i] == ']' && c == '[' || f.s[f.i] == '}' && c == '{' {
+				f.i++
+				return r, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return value{}, err
+			}
+			if c == '[' {
+				r.set(value{typ: vnum, v: len(r.entries())}, v)
+			} else {
+				if f.skipSpace(); f.i == len(f.s) || f.s[f.i] != ':' {
+					return value{}, fmt.Errorf("expected : after key in flow mapping")
+				}
+				f.i++
+				k := v
+				if _, ok := hashKey(k); !ok {
+					return value{}, fmt.Errorf("invalid key in flow mapping")
+				}
+				if v, err = f.value(); err != nil {
+					return value{}, err
+				}
+				r.v.(*hashMap).put(k, v)
+			}
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == ',' {
+				f.i++
+			}
+		}
+	case '"', '\'':
+		j := f.i + 1
+		for ; j < len(f.s); j++ {
+			if c == '"' && f.s[j] == '\\' {
+				j++
+			} else if f.s[j] == c {
+				if c == '\'' && j+1 < len(f.s) && f.s[j+1] == '\'' {
+					j++
+					continue
+				}
+				break
+			}
+		}
+		if j >= len(f.s) {
+			return value{}, fmt.Errorf("unterminated string")
+		}
+		s := f.s[f.i : j+1]
+		f.i = j + 1
+		return yamlScalar(s)
+	}
+	// A plain scalar ends at a flow indicator or a colon that ends a key.
+	j := f.i
+	for j < len(f.s) && !strings.ContainsRune(",[]{}", rune(f.s[j])) && !(f.s[j] == ':' && (j+1 == len(f.s) || f.s[j+1] == ' ')) {
+		j++
+	}
+	s := strings.TrimSpace(f.s[f.i:j])
+	f.i = j
+	return yamlScalar(s)
+}